@@ -10,6 +10,8 @@ import (
 	"github.com/spezifisch/rueder3/backend/internal/common"
 	"github.com/spezifisch/rueder3/backend/pkg/api/controller"
 	ruederHTTP "github.com/spezifisch/rueder3/backend/pkg/api/http"
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/health"
 	mockRepository "github.com/spezifisch/rueder3/backend/pkg/repository/mock"
 	apiPopRepository "github.com/spezifisch/rueder3/backend/pkg/repository/pop/api"
 	rabbitMQRepository "github.com/spezifisch/rueder3/backend/pkg/repository/rabbitmq"
@@ -59,13 +61,20 @@ func main() {
 			go mqRepo.HandleEvents()
 			defer mqRepo.Close()
 
+			readinessChecks := health.Checks{
+				"rabbitmq": mqRepo.Ping,
+			}
+
 			// setup SQL db
 			db := common.RequireString("db")
 			log.Infof("api: using pop db \"%s\"", db)
 
 			var c *controller.Controller
+			var apiTokens fibertools.APITokenValidator
 			if isDevelopmentMode && db == "mock" { // allow mock sqldb only in dev mode
-				c = controller.NewController(mockRepository.NewMockRepository(), mqRepo)
+				mockRepo := mockRepository.NewMockRepository()
+				c = controller.NewController(mockRepo, mqRepo)
+				apiTokens = mockRepo
 			} else {
 				r := apiPopRepository.NewAPIPopRepository(db)
 				if r == nil {
@@ -73,12 +82,20 @@ func main() {
 				}
 
 				c = controller.NewController(r, mqRepo)
+				apiTokens = r
+				readinessChecks["db"] = r.Ping
+				defer r.Close()
 			}
 
 			// start http server
-			s := ruederHTTP.NewServer(c, jwtSecretKey, isDevelopmentMode, trustedProxies)
+			s := ruederHTTP.NewServer(c, jwtSecretKey, apiTokens, isDevelopmentMode, trustedProxies, readinessChecks)
+			common.RunMetricsServer()
+
+			// stop gracefully on SIGTERM
+			ctx, stop := common.ShutdownContext()
+			defer stop()
 			log.Info("🚀 api ready!")
-			s.Run()
+			s.Run(ctx, common.ShutdownTimeout())
 
 			if isDevelopmentMode {
 				log.Info("❌ api quit!")
@@ -86,7 +103,7 @@ func main() {
 		},
 	}
 
-	common.InitConfig(cmd)
+	common.InitConfig(cmd, ":9090")
 
 	var err error
 	cmd.PersistentFlags().String("jwt", "", "JWT secret key")
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/apex/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -8,6 +10,8 @@ import (
 	"github.com/spezifisch/rueder3/backend/internal/common"
 	"github.com/spezifisch/rueder3/backend/pkg/authbackend/controller"
 	authBackendHTTP "github.com/spezifisch/rueder3/backend/pkg/authbackend/http"
+	"github.com/spezifisch/rueder3/backend/pkg/authbackend/oidc"
+	"github.com/spezifisch/rueder3/backend/pkg/health"
 	authBackendPopRepository "github.com/spezifisch/rueder3/backend/pkg/repository/pop/authbackend"
 )
 
@@ -15,7 +19,7 @@ func main() {
 	cmd := &cobra.Command{
 		Use:   "authbackend",
 		Short: "Authentication Backend",
-		Long:  `Rueder Authentication Backend provides auth claims for loginsrv.`,
+		Long:  `Rueder Authentication Backend provides auth claims for loginsrv, local accounts and OpenID Connect login.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			db := common.RequireString("db")
 			log.Infof("using pop db \"%s\"", db)
@@ -26,13 +30,65 @@ func main() {
 			if r == nil {
 				return
 			}
+			defer r.Close()
 			bind := common.RequireString("bind")
 			log.Infof("authbackend: binding to %s", bind)
 
+			readinessChecks := health.Checks{
+				"db": r.Ping,
+			}
+
 			c := controller.NewController(r)
-			s := authBackendHTTP.NewServer(c, bind, isDevelopmentMode)
+			admins := viper.GetStringSlice("admin")
+			if len(admins) > 0 {
+				log.WithField("admins", admins).Info("authbackend: admins configured")
+			}
+			c.SetAdmins(admins)
+
+			// local accounts are optional, without a JWT secret only loginsrv is supported
+			jwtSecretKey := viper.GetString("jwt")
+			if jwtSecretKey != "" {
+				if !isDevelopmentMode && (jwtSecretKey == "secret" || len(jwtSecretKey) < 32) {
+					panic("use a JWT secret with 32 or more characters!")
+				}
+				c.SetJWT(jwtSecretKey, viper.GetDuration("jwt-expiry"))
+				c.SetRegistration(viper.GetBool("registration"))
+			}
+
+			// OpenID Connect login is optional too, users get the issuer as their origin
+			oidcIssuer := viper.GetString("oidc-issuer")
+			if oidcIssuer != "" {
+				if jwtSecretKey == "" {
+					panic("OIDC login needs a JWT secret!")
+				}
+				provider, err := oidc.NewProvider(oidcIssuer, viper.GetString("oidc-client-id"),
+					viper.GetString("oidc-client-secret"), viper.GetString("oidc-redirect-url"),
+					viper.GetStringSlice("oidc-scopes"))
+				if err != nil {
+					panic(err)
+				}
+				c.SetOIDC(provider, common.RequireString("oidc-frontend-url"))
+				log.WithField("issuer", provider.Issuer()).Info("authbackend: OIDC login enabled")
+			}
+
+			// the routes for the browser are served separately from the claims for loginsrv
+			publicBind := ""
+			if jwtSecretKey != "" {
+				publicBind = common.RequireString("public-bind")
+				if publicBind == bind {
+					panic("public-bind must differ from bind!")
+				}
+				log.Infof("authbackend: binding local account and OIDC routes to %s", publicBind)
+			}
+			trustedProxies := viper.GetStringSlice("trusted-proxy")
+			s := authBackendHTTP.NewServer(c, bind, publicBind, jwtSecretKey, isDevelopmentMode, trustedProxies, readinessChecks)
+			common.RunMetricsServer()
+
+			// stop gracefully on SIGTERM
+			ctx, stop := common.ShutdownContext()
+			defer stop()
 			log.Info("🚀 authbackend ready!")
-			s.Run()
+			s.Run(ctx, common.ShutdownTimeout())
 
 			if isDevelopmentMode {
 				log.Info("❌ authbackend quit!")
@@ -40,7 +96,7 @@ func main() {
 		},
 	}
 
-	common.InitConfig(cmd)
+	common.InitConfig(cmd, ":9092")
 
 	var err error
 	cmd.PersistentFlags().Bool("dev", false, "development mode")
@@ -56,6 +112,78 @@ func main() {
 	}
 	viper.SetDefault("bind", ":8080")
 
+	cmd.PersistentFlags().String("public-bind", ":8081", "bind the local account and OIDC routes to ip:port")
+	err = viper.BindPFlag("public-bind", cmd.PersistentFlags().Lookup("public-bind"))
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.PersistentFlags().StringSlice("trusted-proxy", []string{}, "trusted proxy IP or IP range in front of public-bind")
+	err = viper.BindPFlag("trusted-proxy", cmd.PersistentFlags().Lookup("trusted-proxy"))
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.PersistentFlags().StringSlice("admin", []string{}, "users that get the admin role as origin:subject")
+	err = viper.BindPFlag("admin", cmd.PersistentFlags().Lookup("admin"))
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.PersistentFlags().String("jwt", "", "JWT secret key, enables local accounts")
+	err = viper.BindPFlag("jwt", cmd.PersistentFlags().Lookup("jwt"))
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.PersistentFlags().Duration("jwt-expiry", 24*time.Hour, "lifetime of JWTs for local accounts")
+	err = viper.BindPFlag("jwt-expiry", cmd.PersistentFlags().Lookup("jwt-expiry"))
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.PersistentFlags().Bool("registration", false, "allow registering local accounts")
+	err = viper.BindPFlag("registration", cmd.PersistentFlags().Lookup("registration"))
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.PersistentFlags().String("oidc-issuer", "", "OpenID Connect issuer URL, enables OIDC login")
+	err = viper.BindPFlag("oidc-issuer", cmd.PersistentFlags().Lookup("oidc-issuer"))
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.PersistentFlags().String("oidc-client-id", "", "OpenID Connect client ID")
+	err = viper.BindPFlag("oidc-client-id", cmd.PersistentFlags().Lookup("oidc-client-id"))
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.PersistentFlags().String("oidc-client-secret", "", "OpenID Connect client secret, empty for public clients")
+	err = viper.BindPFlag("oidc-client-secret", cmd.PersistentFlags().Lookup("oidc-client-secret"))
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.PersistentFlags().String("oidc-redirect-url", "", "public URL of the authbackend's /oidc/callback route")
+	err = viper.BindPFlag("oidc-redirect-url", cmd.PersistentFlags().Lookup("oidc-redirect-url"))
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.PersistentFlags().String("oidc-frontend-url", "", "frontend URL that gets the JWT after OIDC login")
+	err = viper.BindPFlag("oidc-frontend-url", cmd.PersistentFlags().Lookup("oidc-frontend-url"))
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.PersistentFlags().StringSlice("oidc-scopes", oidc.DefaultScopes, "OpenID Connect scopes")
+	err = viper.BindPFlag("oidc-scopes", cmd.PersistentFlags().Lookup("oidc-scopes"))
+	if err != nil {
+		panic(err)
+	}
+
 	err = cmd.Execute()
 	if err != nil {
 		log.WithError(err).Error("command failed")
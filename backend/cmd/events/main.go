@@ -8,6 +8,8 @@ import (
 	"github.com/spezifisch/rueder3/backend/internal/common"
 	"github.com/spezifisch/rueder3/backend/pkg/events/controller"
 	eventsHTTP "github.com/spezifisch/rueder3/backend/pkg/events/http"
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/health"
 	mockRepository "github.com/spezifisch/rueder3/backend/pkg/repository/mock"
 	apiPopRepository "github.com/spezifisch/rueder3/backend/pkg/repository/pop/api"
 	rabbitMQRepository "github.com/spezifisch/rueder3/backend/pkg/repository/rabbitmq"
@@ -38,12 +40,20 @@ func main() {
 			if mqRepo == nil {
 				panic("can't connect to mq")
 			}
+			defer mqRepo.Close()
+			readinessChecks := health.Checks{
+				"rabbitmq": mqRepo.Ping,
+			}
+
 			bind := common.RequireString("bind")
 			log.Infof("events: binding to %s", bind)
 
 			var c *controller.Controller
+			var apiTokens fibertools.APITokenValidator
 			if isDevelopmentMode && db == "mock" {
-				c = controller.NewController(mockRepository.NewMockRepository(), mqRepo)
+				mockRepo := mockRepository.NewMockRepository()
+				c = controller.NewController(mockRepo, mqRepo)
+				apiTokens = mockRepo
 			} else {
 				r := apiPopRepository.NewAPIPopRepository(db)
 				if r == nil {
@@ -51,12 +61,27 @@ func main() {
 				}
 
 				c = controller.NewController(r, mqRepo)
+				apiTokens = r
+				readinessChecks["db"] = r.Ping
+				defer r.Close()
 			}
 
+			// forward new articles and fetch errors of the workers to the subscribers
+			go func() {
+				if err := c.RunFeedEventFanout(); err != nil {
+					log.WithError(err).Error("couldn't consume feed events")
+				}
+			}()
+
 			// http server
-			s := eventsHTTP.NewServer(c, bind, jwtSecretKey, isDevelopmentMode, trustedProxies)
+			s := eventsHTTP.NewServer(c, bind, jwtSecretKey, apiTokens, isDevelopmentMode, trustedProxies, readinessChecks)
+			common.RunMetricsServer()
+
+			// stop gracefully on SIGTERM
+			ctx, stop := common.ShutdownContext()
+			defer stop()
 			log.Info("🚀 events ready!")
-			s.Run()
+			s.Run(ctx, common.ShutdownTimeout())
 
 			if isDevelopmentMode {
 				log.Info("❌ events quit!")
@@ -64,7 +89,7 @@ func main() {
 		},
 	}
 
-	common.InitConfig(cmd)
+	common.InitConfig(cmd, ":9093")
 
 	var err error
 	cmd.PersistentFlags().String("jwt", "", "JWT secret key")
@@ -28,9 +28,14 @@ func main() {
 			log.Infof("feedfinder: binding to %s", bind)
 
 			c := controller.NewController()
-			s := feedfinderHTTP.NewServer(c, bind, jwtSecretKey, isDevelopmentMode, trustedProxies)
+			s := feedfinderHTTP.NewServer(c, bind, jwtSecretKey, isDevelopmentMode, trustedProxies, nil)
+			common.RunMetricsServer()
+
+			// stop gracefully on SIGTERM
+			ctx, stop := common.ShutdownContext()
+			defer stop()
 			log.Info("🚀 feedfinder ready!")
-			s.Run()
+			s.Run(ctx, common.ShutdownTimeout())
 
 			if isDevelopmentMode {
 				log.Info("❌ feedfinder quit!")
@@ -38,7 +43,7 @@ func main() {
 		},
 	}
 
-	common.InitConfig(cmd)
+	common.InitConfig(cmd, ":9091")
 
 	var err error
 	cmd.PersistentFlags().Bool("dev", false, "development mode")
@@ -1,6 +1,9 @@
 package common
 
 import (
+	"strings"
+	"time"
+
 	"github.com/apex/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -11,8 +14,10 @@ var (
 	ConfigFile string
 )
 
-// InitConfig reads configuration from a file or environment
-func InitConfig(cmd *cobra.Command) {
+// InitConfig reads configuration from a file or environment.
+// metricsBind is the default address of the metrics endpoint, every service has its own so that they can run
+// side by side.
+func InitConfig(cmd *cobra.Command, metricsBind string) {
 	var err error
 
 	// config env/file
@@ -37,6 +42,20 @@ func InitConfig(cmd *cobra.Command) {
 	}
 	viper.SetDefault("log", "info")
 
+	// metrics (-> "metrics-bind: foo" in yaml or "--metrics-bind foo")
+	cmd.PersistentFlags().String("metrics-bind", metricsBind, "bind prometheus metrics endpoint to ip:port (empty to disable)")
+	err = viper.BindPFlag("metrics-bind", cmd.PersistentFlags().Lookup("metrics-bind"))
+	if err != nil {
+		panic("BindPFlag metrics-bind failed")
+	}
+
+	// graceful shutdown (-> "shutdown-timeout: 30s" in yaml or "--shutdown-timeout 30s")
+	cmd.PersistentFlags().Duration("shutdown-timeout", 25*time.Second, "time to finish requests and jobs in progress on SIGTERM")
+	err = viper.BindPFlag("shutdown-timeout", cmd.PersistentFlags().Lookup("shutdown-timeout"))
+	if err != nil {
+		panic("BindPFlag shutdown-timeout failed")
+	}
+
 	logLevel := viper.GetString("log")
 	log.SetLevel(log.MustParseLevel(logLevel))
 }
@@ -52,8 +71,9 @@ func cobraOnInitialize() {
 		viper.AddConfigPath(".")
 	}
 
-	// env vars
+	// env vars, e.g. RUEDER_OIDC_ISSUER for oidc-issuer
 	viper.SetEnvPrefix("RUEDER")
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	viper.AutomaticEnv()
 
 	// read config
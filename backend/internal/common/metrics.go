@@ -0,0 +1,45 @@
+package common
+
+import (
+	"net/http"
+
+	"github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/viper"
+
+	"github.com/spezifisch/rueder3/backend/pkg/health"
+)
+
+// RunMetricsServer serves the prometheus metrics at /metrics in the background.
+// The address is taken from the metrics-bind option, it's disabled if that's empty.
+func RunMetricsServer() {
+	bind := viper.GetString("metrics-bind")
+	if bind == "" {
+		log.Info("metrics: disabled")
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	go func() {
+		log.Infof("metrics: binding to %s", bind)
+		if err := http.ListenAndServe(bind, mux); err != nil {
+			log.WithError(err).Error("metrics server failed")
+		}
+	}()
+}
+
+// RunHealthServer serves /healthz and /readyz with the given checks in the background, for services without their
+// own http server. It's independent of the metrics so the orchestrator can check the service without them.
+func RunHealthServer(bind string, readinessChecks health.Checks) {
+	mux := http.NewServeMux()
+	health.AddHTTPRoutes(mux, readinessChecks)
+
+	go func() {
+		log.Infof("health: binding to %s", bind)
+		if err := http.ListenAndServe(bind, mux); err != nil {
+			log.WithError(err).Fatal("health server failed")
+		}
+	}()
+}
@@ -1,7 +1,15 @@
 package common
 
+import "github.com/gofrs/uuid"
+
 // UserEventMessage is the message type passed from backend api to event api using EventRepository
 type UserEventMessage struct {
 	Type string            `json:"type"`
 	Data map[string]string `json:"data,omitempty"`
 }
+
+// FeedEventMessage is passed from the worker to the event api which forwards the payload to all subscribers of the feed
+type FeedEventMessage struct {
+	FeedID  uuid.UUID        `json:"feed_id"`
+	Payload UserEventMessage `json:"payload"`
+}
@@ -0,0 +1,27 @@
+package common
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/spf13/viper"
+)
+
+// ShutdownContext returns a context that is cancelled when the process receives SIGINT or SIGTERM
+func ShutdownContext() (ctx context.Context, stop context.CancelFunc) {
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		log.Info("shutting down")
+	}()
+	return
+}
+
+// ShutdownTimeout is the time the services have to finish what they are doing when shutting down
+func ShutdownTimeout() time.Duration {
+	return viper.GetDuration("shutdown-timeout")
+}
@@ -4,4 +4,14 @@ package common
 // these need to be in sync with frontend/src/rueder/api/sse.ts
 const (
 	MessageTypeFolderUpdate = "folder_update"
+	MessageTypeStateUpdate  = "state_update"
+	MessageTypeLabelUpdate  = "label_update"
+	// result of a refresh requested by the user, data: feed_id, working, message
+	MessageTypeFeedRefreshed = "feed_refreshed"
+	// new articles were added to a subscribed feed, data: feed_id, count, max_seq
+	MessageTypeNewArticles = "new_articles"
+	// fetching a subscribed feed started failing, data: feed_id, message
+	MessageTypeFeedError = "feed_error"
+	// the account was deleted, clients should log out
+	MessageTypeLogout = "logout"
 )
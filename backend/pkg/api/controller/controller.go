@@ -5,6 +5,7 @@ type Controller struct {
 	repository          Repository
 	userEventRepository UserEventRepository
 	articlesPerPage     int
+	refreshLimiter      *refreshLimiter
 }
 
 // NewController for API v1
@@ -13,5 +14,6 @@ func NewController(repository Repository, userEventRepository UserEventRepositor
 		repository:          repository,
 		userEventRepository: userEventRepository,
 		articlesPerPage:     40,
+		refreshLimiter:      newRefreshLimiter(),
 	}
 }
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+)
+
+// testEventRepository records the published user events
+type testEventRepository struct {
+	UserEventRepository
+
+	envelopes []UserEventEnvelope
+}
+
+func (r *testEventRepository) Publish(envelope *UserEventEnvelope) {
+	r.envelopes = append(r.envelopes, *envelope)
+}
+
+// newTestApp returns an app with the routes used in the controller tests where requests are authenticated as a user
+// with the role. The role middlewares are the same as in the reader and admin APIs.
+func newTestApp(c *Controller, userID uuid.UUID, role string) *fiber.App {
+	app := fiber.New()
+	app.Use(func(ctx *fiber.Ctx) error {
+		ctx.Locals("user", &jwt.Token{Claims: jwt.MapClaims{
+			"uid":    userID.String(),
+			"origin": "somewhere",
+			"sub":    "someone",
+			"role":   role,
+		}})
+		return ctx.Next()
+	})
+
+	app.Patch("/feed/:feed_id", c.ChangeFeed)
+	app.Post("/feed/:feed_id/refresh", c.RefreshFeed)
+	app.Delete("/feed/:feed_id", fibertools.NewFiberRoleMiddleware(helpers.RoleAdmin), c.DeleteFeed)
+	app.Get("/me/export", c.ExportAccount)
+	app.Delete("/me", c.DeleteAccount)
+
+	admin := app.Group("/admin", fibertools.NewFiberRoleMiddleware(helpers.RoleAdmin))
+	admin.Get("/feeds", c.AdminFeeds)
+	admin.Delete("/user/:user_id", c.AdminDeleteUser)
+	admin.Post("/feed/:feed_id/reset", c.AdminResetFeed)
+	return app
+}
@@ -10,15 +10,58 @@ import (
 type Repository interface {
 	GetArticle(id uuid.UUID) (Article, error)
 	GetArticles(feedID uuid.UUID, limit int, offset int) ([]ArticlePreview, error)
+	GetUserArticles(claims *helpers.AuthClaims, feedIDs []uuid.UUID, limit int, offset int) ([]ArticlePreview, error)
+	SearchArticles(claims *helpers.AuthClaims, query string, feedIDs []uuid.UUID, limit int, offset int) ([]ArticlePreview, error)
 
-	Feeds() ([]Feed, error)
 	GetFeed(id uuid.UUID) (Feed, error)
 	GetFeedByURL(url string) (Feed, error)
 	AddFeed(url string) (feedID uuid.UUID, err error)
+	// DeleteFeed deletes the feed with its articles and unsubscribes all users. It returns the former subscribers.
+	DeleteFeed(feedID uuid.UUID) (subscriberIDs []uuid.UUID, err error)
+
+	// feeds tied to the user:
+	Feeds(*helpers.AuthClaims) ([]Feed, error)
+	ChangeFeed(claims *helpers.AuthClaims, feedID uuid.UUID, changes FeedChanges) error
+	RefreshFeed(claims *helpers.AuthClaims, feedID uuid.UUID) error
 
 	// tied to the user:
 	Folders(*helpers.AuthClaims) ([]Folder, error)
 	ChangeFolders(*helpers.AuthClaims, []Folder) error
+	// ImportFolders adds the new feeds and changes the folders in one transaction
+	ImportFolders(claims *helpers.AuthClaims, folders []Folder, newFeeds []NewFeed) error
+	FolderFeedIDs(claims *helpers.AuthClaims, folderID uuid.UUID) ([]uuid.UUID, error)
+	FolderLimits() (folderCount int, folderFeedCount int)
+
+	// read state, tied to the user:
+	UserState(*helpers.AuthClaims) (UserState, error)
+	MarkArticles(claims *helpers.AuthClaims, feedIDs []uuid.UUID, seqs []int, read bool) (UserState, error)
+	MarkAllRead(claims *helpers.AuthClaims, feedIDs []uuid.UUID, untilSeq int) (UserState, error)
+
+	// labels, tied to the user:
+	Labels(*helpers.AuthClaims) ([]Label, error)
+	AddLabel(claims *helpers.AuthClaims, name string, color string) (Label, error)
+	ChangeLabel(claims *helpers.AuthClaims, labelID uuid.UUID, name string, color string) (Label, error)
+	DeleteLabel(claims *helpers.AuthClaims, labelID uuid.UUID) error
+	AssignLabel(claims *helpers.AuthClaims, labelID uuid.UUID, articleID uuid.UUID) error
+	UnassignLabel(claims *helpers.AuthClaims, labelID uuid.UUID, articleID uuid.UUID) error
+	GetLabelArticles(claims *helpers.AuthClaims, labelID uuid.UUID, limit int, offset int) ([]ArticlePreview, error)
+	// LabelledArticles returns all articles with at least one of the user's labels, including their content
+	LabelledArticles(*helpers.AuthClaims) ([]LabelledArticle, error)
+
+	// DeleteUser deletes the user with subscriptions, read state and labels.
+	// It's used by the user to delete their own account and by admins.
+	DeleteUser(userID uuid.UUID) error
+
+	// admin only:
+	Users() ([]AdminUser, error)
+	// FeedHealth returns all feeds with their fetcher state, only the ones that don't work if brokenOnly is set
+	FeedHealth(brokenOnly bool) ([]AdminFeed, error)
+	// ForceRefreshFeed is RefreshFeed for any feed
+	ForceRefreshFeed(claims *helpers.AuthClaims, feedID uuid.UUID) error
+	// ResetFeed forgets the fetch failures and the backoff of the feed and fetches it again
+	ResetFeed(claims *helpers.AuthClaims, feedID uuid.UUID) error
+	// ChangeFeedSettings changes the fetcher options of the feed, they are shared by all subscribers
+	ChangeFeedSettings(claims *helpers.AuthClaims, feedID uuid.UUID, settings FeedSettings) error
 }
 
 // UserEventRepository can send live events to users
@@ -64,14 +64,34 @@ type Feed struct {
 	URL          string `json:"url,omitempty"`
 	SiteURL      string `json:"site_url,omitempty"`
 	ArticleCount int    `json:"article_count,omitempty"`
+	UnreadCount  int    `json:"unread_count,omitempty"`
 
-	FetcherState *FetcherState `json:"fetcher_state,omitempty"`
+	// the article pages are downloaded because of the feed settings or because a subscriber asked for them
+	FetchFullArticle bool          `json:"fetch_full_article,omitempty"`
+	FetcherState     *FetcherState `json:"fetcher_state,omitempty"`
 
 	Articles []ArticlePreview `json:"articles,omitempty"`
 }
 
+// FeedSettings are the options for fetching a feed
+type FeedSettings struct {
+	// download the linked article page instead of using the feed content
+	FetchFullArticle bool `json:"fetch_full_article"`
+}
+
+// FeedChanges are the user's changes to a subscribed feed. Omitted fields are left unchanged.
+// The fetcher options of the feed itself are changed by admins with FeedSettings.
+type FeedChanges struct {
+	// custom title in the user's folders, empty to use the feed's own title
+	Title *string `json:"title,omitempty"`
+	// the user wants the linked article pages instead of the feed content. They are downloaded if the feed
+	// settings or any subscriber asks for them.
+	FetchFullArticle *bool `json:"fetch_full_article,omitempty"`
+}
+
 // FetcherState for feed detail view
 type FetcherState struct {
+	Dead        bool      `json:"dead,omitempty"`
 	Working     bool      `json:"working"`
 	LastSuccess time.Time `json:"last_success,omitempty"`
 	LastError   time.Time `json:"last_error,omitempty"`
@@ -82,6 +102,56 @@ type FetcherState struct {
 type Folder struct {
 	ID uuid.UUID `json:"id"`
 
-	Title string `json:"title,omitempty"`
-	Feeds []Feed `json:"feeds,omitempty"`
+	Title       string `json:"title,omitempty"`
+	UnreadCount int    `json:"unread_count,omitempty"`
+	Feeds       []Feed `json:"feeds,omitempty"`
+}
+
+// NewFeed is a feed that's created together with the subscription to it
+type NewFeed struct {
+	ID  uuid.UUID
+	URL string
+}
+
+// UserState contains the read state of the user's feeds
+type UserState struct {
+	FeedStates map[uuid.UUID]UserFeedState `json:"feed_states"`
+}
+
+// UserFeedState is the read state of a single feed. Articles are identified by their seq.
+type UserFeedState struct {
+	// all articles with a seq up to and including this one are read
+	ReadAllUntil int `json:"read_all_until"`
+	// additionally read articles newer than ReadAllUntil
+	ReadArticles []int `json:"read_articles,omitempty"`
+}
+
+// Label is a user-defined label that can be assigned to articles
+type Label struct {
+	ID uuid.UUID `json:"id"`
+
+	Name         string `json:"name"`
+	Color        string `json:"color,omitempty"`
+	ArticleCount int    `json:"article_count"`
+}
+
+// LabelledArticle is an article with the IDs of the user's labels assigned to it
+type LabelledArticle struct {
+	Article
+
+	LabelIDs []uuid.UUID `json:"label_ids"`
+}
+
+// UserExport is everything stored for a user, it's the JSON part of the account export
+type UserExport struct {
+	ExportedAt time.Time `json:"exported_at"`
+
+	UserID uuid.UUID `json:"user_id"`
+	Origin string    `json:"origin"`
+	Name   string    `json:"name"`
+
+	Folders          []Folder          `json:"folders"`
+	State            UserState         `json:"state"`
+	Labels           []Label           `json:"labels"`
+	LabelledArticles []LabelledArticle `json:"labelled_articles"`
 }
@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// AdminUser is a user in the admin user list
+type AdminUser struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	AuthOrigin  string `json:"auth_origin"`
+	AuthSubject string `json:"auth_subject"`
+	FeedCount   int    `json:"feed_count"`
+}
+
+// AdminFeed is a feed with the fetcher details for the admin feed list
+type AdminFeed struct {
+	ID    uuid.UUID `json:"id"`
+	Title string    `json:"title,omitempty"`
+	URL   string    `json:"url"`
+
+	SubscriberCount int               `json:"subscriber_count"`
+	FetchedAt       time.Time         `json:"fetched_at"`
+	FetcherState    AdminFetcherState `json:"fetcher_state"`
+}
+
+// AdminFetcherState is the FetcherState with the backoff details
+type AdminFetcherState struct {
+	FetcherState
+
+	FailureCount int       `json:"failure_count,omitempty"`
+	BackoffUntil time.Time `json:"backoff_until,omitempty"`
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// opmlImportFolder is the folder for feeds that aren't in a folder in the imported file
+const opmlImportFolder = "Imported"
+
+// opmlDocument is an OPML 2.0 subscription list, see http://opml.org/spec2.opml
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    opmlHead `xml:"head"`
+	Body    opmlBody `xml:"body"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type opmlBody struct {
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// opmlEntry is a single feed of an imported OPML file
+type opmlEntry struct {
+	Folder string
+	Title  string
+	URL    string
+}
+
+// name returns the title of the outline, falling back to its text
+func (o *opmlOutline) name() string {
+	if title := strings.TrimSpace(o.Title); title != "" {
+		return title
+	}
+	return strings.TrimSpace(o.Text)
+}
+
+// buildOPML exports the folders as OPML 2.0 with one outline per folder
+func buildOPML(folders []Folder, now time.Time) ([]byte, error) {
+	doc := opmlDocument{
+		Version: "2.0",
+		Head: opmlHead{
+			Title:       "rueder3 subscriptions",
+			DateCreated: now.UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	doc.Body.Outlines = make([]opmlOutline, len(folders))
+	for i, folder := range folders {
+		folderOutline := &doc.Body.Outlines[i]
+		folderOutline.Text = folder.Title
+		folderOutline.Title = folder.Title
+		folderOutline.Outlines = make([]opmlOutline, len(folder.Feeds))
+
+		for j, feed := range folder.Feeds {
+			folderOutline.Outlines[j] = opmlOutline{
+				Text:    feed.Title,
+				Title:   feed.Title,
+				Type:    "rss",
+				XMLURL:  feed.URL,
+				HTMLURL: feed.SiteURL,
+			}
+		}
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// parseOPML returns all feeds of the OPML file with the folder they belong to.
+// Nested folders are flattened into their top level folder because we only have one level of folders.
+func parseOPML(data []byte) (entries []opmlEntry, err error) {
+	doc := opmlDocument{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err = decoder.Decode(&doc); err != nil {
+		return
+	}
+
+	for _, outline := range doc.Body.Outlines {
+		if outline.XMLURL != "" {
+			entries = append(entries, opmlEntry{
+				Folder: opmlImportFolder,
+				Title:  outline.name(),
+				URL:    strings.TrimSpace(outline.XMLURL),
+			})
+			continue
+		}
+
+		folder := outline.name()
+		if folder == "" {
+			folder = opmlImportFolder
+		}
+		entries = appendOPMLFolderEntries(entries, folder, outline.Outlines)
+	}
+	return
+}
+
+func appendOPMLFolderEntries(entries []opmlEntry, folder string, outlines []opmlOutline) []opmlEntry {
+	for _, outline := range outlines {
+		if outline.XMLURL != "" {
+			entries = append(entries, opmlEntry{
+				Folder: folder,
+				Title:  outline.name(),
+				URL:    strings.TrimSpace(outline.XMLURL),
+			})
+		}
+		entries = appendOPMLFolderEntries(entries, folder, outline.Outlines)
+	}
+	return entries
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseOPML(t *testing.T) {
+	opml := `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="1.0">
+<head><title>Subscriptions</title></head>
+<body>
+  <outline text="Loose Feed" type="rss" xmlUrl="https://example.com/loose.xml"/>
+  <outline text="News" title="News">
+    <outline text="heise" type="rss" xmlUrl="https://www.heise.de/rss/heise-atom.xml" htmlUrl="https://www.heise.de/"/>
+    <outline text="Sub Folder">
+      <outline title="Nested" text="ignored" type="rss" xmlUrl=" https://example.com/nested.xml "/>
+    </outline>
+  </outline>
+  <outline text="Empty Folder"/>
+</body>
+</opml>`
+
+	entries, err := parseOPML([]byte(opml))
+	assert.NoError(t, err)
+	assert.Equal(t, []opmlEntry{
+		{Folder: opmlImportFolder, Title: "Loose Feed", URL: "https://example.com/loose.xml"},
+		{Folder: "News", Title: "heise", URL: "https://www.heise.de/rss/heise-atom.xml"},
+		{Folder: "News", Title: "Nested", URL: "https://example.com/nested.xml"},
+	}, entries)
+}
+
+func Test_parseOPML_Charset(t *testing.T) {
+	opml := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+		"<opml version=\"2.0\"><body><outline text=\"Gr\xfc\xdfe\"><outline text=\"B\xe4r\" xmlUrl=\"https://example.com/\"/></outline></body></opml>"
+
+	entries, err := parseOPML([]byte(opml))
+	assert.NoError(t, err)
+	assert.Equal(t, []opmlEntry{{Folder: "Grüße", Title: "Bär", URL: "https://example.com/"}}, entries)
+}
+
+func Test_parseOPML_Malformed(t *testing.T) {
+	_, err := parseOPML([]byte("<html><body>not opml</body></html>"))
+	assert.Error(t, err)
+}
+
+func Test_buildOPML(t *testing.T) {
+	folders := []Folder{
+		{
+			Title: "News & Stuff",
+			Feeds: []Feed{
+				{Title: "heise", URL: "https://www.heise.de/rss/heise-atom.xml", SiteURL: "https://www.heise.de/"},
+			},
+		},
+		{Title: "Empty"},
+	}
+
+	out, err := buildOPML(folders, time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `<dateCreated>Mon, 31 Jan 2022 00:00:00 +0000</dateCreated>`)
+	assert.Contains(t, string(out), `<outline text="News &amp; Stuff" title="News &amp; Stuff">`)
+
+	// export and import should give the same feeds
+	entries, err := parseOPML(out)
+	assert.NoError(t, err)
+	assert.Equal(t, []opmlEntry{{Folder: "News & Stuff", Title: "heise", URL: "https://www.heise.de/rss/heise-atom.xml"}}, entries)
+}
+
+// opmlTestRepository knows some feeds by URL
+type opmlTestRepository struct {
+	Repository
+
+	feeds map[string]uuid.UUID
+}
+
+func (r *opmlTestRepository) GetFeedByURL(url string) (Feed, error) {
+	if id, ok := r.feeds[url]; ok {
+		return Feed{ID: id}, nil
+	}
+	return Feed{}, errors.New("not found")
+}
+
+func (r *opmlTestRepository) FolderLimits() (int, int) {
+	return 2, 2
+}
+
+func TestController_mergeOPMLEntries(t *testing.T) {
+	subscribedID := uuid.Must(uuid.NewV4())
+	httpsID := uuid.Must(uuid.NewV4())
+	repo := &opmlTestRepository{
+		feeds: map[string]uuid.UUID{
+			"https://example.com/subscribed.xml": subscribedID,
+			"https://example.com/https.xml":      httpsID,
+		},
+	}
+	c := &Controller{repository: repo}
+
+	folders := []Folder{
+		{ID: uuid.Must(uuid.NewV4()), Title: "News", Feeds: []Feed{{ID: subscribedID}}},
+	}
+	entries := []opmlEntry{
+		{Folder: "Other", Title: "Subscribed", URL: "https://example.com/subscribed.xml"},
+		{Folder: "News", Title: "HTTP", URL: "http://example.com/https.xml"},
+		{Folder: "News", Title: "Full", URL: "https://example.com/full.xml"},
+		{Folder: "New", Title: "New", URL: "https://example.com/new.xml"},
+		{Folder: "Another", Title: "Too Many Folders", URL: "https://example.com/another.xml"},
+		{Folder: "New", Title: "Invalid", URL: "not a url"},
+		{Folder: "New", Title: "New Again", URL: "https://example.com/new.xml"},
+	}
+
+	report, newFeeds, err := c.mergeOPMLEntries(&folders, entries)
+	assert.NoError(t, err)
+
+	statuses := make([]string, len(report.Entries))
+	for i, entry := range report.Entries {
+		statuses[i] = entry.Status
+	}
+	assert.Equal(t, []string{
+		OPMLImportStatusExists,
+		OPMLImportStatusAdded,
+		OPMLImportStatusSkipped,
+		OPMLImportStatusAdded,
+		OPMLImportStatusSkipped,
+		OPMLImportStatusFailed,
+		OPMLImportStatusExists,
+	}, statuses)
+
+	// the http URL was deduplicated to the existing https feed
+	assert.Equal(t, httpsID, *report.Entries[1].FeedID)
+	// only the new feed has to be created, once, and skipped entries don't create feeds
+	if assert.Len(t, newFeeds, 1) {
+		assert.Equal(t, "https://example.com/new.xml", newFeeds[0].URL)
+		assert.Equal(t, newFeeds[0].ID, *report.Entries[6].FeedID)
+	}
+
+	assert.Len(t, folders, 2)
+	assert.Equal(t, []Feed{{ID: subscribedID}, {ID: httpsID, Title: "HTTP"}}, folders[0].Feeds)
+	assert.Equal(t, "New", folders[1].Title)
+	assert.Equal(t, []Feed{{ID: newFeeds[0].ID, Title: "New"}}, folders[1].Feeds)
+}
+
+func Test_readOPMLUpload(t *testing.T) {
+	app := fiber.New()
+	app.Post("/opml", func(ctx *fiber.Ctx) error {
+		data, err := readOPMLUpload(ctx)
+		if err != nil {
+			return err
+		}
+		return ctx.Send(data)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/opml", strings.NewReader("<opml/>")))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	// plain bodies are limited like multipart uploads
+	tooLarge := strings.Repeat(" ", opmlMaxFileSize+1)
+	resp, err = app.Test(httptest.NewRequest(http.MethodPost, "/opml", strings.NewReader(tooLarge)))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
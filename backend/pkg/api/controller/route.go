@@ -10,7 +10,6 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofrs/uuid"
 
-	"github.com/spezifisch/rueder3/backend/internal/common"
 	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
 	"github.com/spezifisch/rueder3/backend/pkg/helpers"
 	"github.com/spezifisch/rueder3/backend/pkg/httputil"
@@ -77,6 +76,63 @@ func (c *Controller) Articles(ctx *fiber.Ctx) error {
 	return ctx.JSON(articles)
 }
 
+// AllArticles godoc
+// @Summary Get article list of all subscribed feeds
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Param start query int false "Start Token"
+// @Success 200 {object} []ArticlePreview
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /articles [get]
+func (c *Controller) AllArticles(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	return c.userArticles(ctx, claims, nil)
+}
+
+// FolderArticles godoc
+// @Summary Get article list of all feeds in a folder
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Param folder_id path  string true  "Folder ID"
+// @Param start     query int    false "Start Token"
+// @Success 200 {object} []ArticlePreview
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /folder/{folder_id}/articles [get]
+func (c *Controller) FolderArticles(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	_, feedIDs, err := c.folderFeedIDs(ctx, claims)
+	if err != nil {
+		return err
+	}
+
+	return c.userArticles(ctx, claims, feedIDs)
+}
+
+func (c *Controller) userArticles(ctx *fiber.Ctx, claims *helpers.AuthClaims, feedIDs []uuid.UUID) error {
+	limit := c.articlesPerPage
+	offset, err := strconv.Atoi(ctx.Query("start"))
+	if err != nil {
+		offset = 0
+	}
+
+	articles, err := c.repository.GetUserArticles(claims, feedIDs, limit, offset)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "articles not found")
+	}
+
+	return ctx.JSON(articles)
+}
+
 // Folders godoc
 // @Summary Get folder list
 // @Tags feed
@@ -124,8 +180,45 @@ func (c *Controller) GetFeed(ctx *fiber.Ctx) error {
 	return ctx.JSON(feed)
 }
 
+// ChangeFeedSettings godoc
+// @Summary Change fetcher options of a feed (admin only)
+// @Description The options are shared by all subscribers of the feed.
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Param feed_id path string       true "Feed ID"
+// @Param request body FeedSettings true "Feed Settings"
+// @Success 200 {object} httputil.HTTPStatus
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /feed/{feed_id}/settings [post]
+func (c *Controller) ChangeFeedSettings(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	feedID, err := uuid.FromString(ctx.Params("feed_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid feed_id")
+	}
+
+	var json FeedSettings
+	if err := ctx.BodyParser(&json); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "malformed JSON body")
+	}
+
+	if err := c.repository.ChangeFeedSettings(claims, feedID, json); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+
+	return ctx.JSON(httputil.HTTPStatus{
+		Status: "ok",
+		FeedID: feedID,
+	})
+}
+
 // Feeds godoc
-// @Summary Get all feeds
+// @Summary Get the feeds the user is subscribed to
 // @Tags feed
 // @Accept json
 // @Produce json
@@ -136,7 +229,8 @@ func (c *Controller) GetFeed(ctx *fiber.Ctx) error {
 // @Security ApiKeyAuth
 // @Router /feeds [get]
 func (c *Controller) Feeds(ctx *fiber.Ctx) error {
-	feeds, err := c.repository.Feeds()
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	feeds, err := c.repository.Feeds(claims)
 	if err != nil {
 		return fiber.NewError(fiber.StatusNotFound, "feeds not found")
 	}
@@ -163,24 +257,11 @@ func (c *Controller) AddFeed(ctx *fiber.Ctx) error {
 	if !helpers.IsURL(json.URL) {
 		return fiber.NewError(fiber.StatusBadRequest, "not a valid URL")
 	}
-	if helpers.IsHTTPURL(json.URL) {
-		// it's a http URL, so first try looking up if there's a https version in the db
-		httpsURL := helpers.RewriteToHTTPS(json.URL)
-		if feed, err := c.repository.GetFeedByURL(httpsURL); err == nil {
-			// found https version
-			return ctx.JSON(httputil.HTTPStatus{
-				Status: "ok",
-				FeedID: feed.ID,
-			})
-		}
-		// try the http version next
-	}
-	// look if a feed with this URL already exists
-	if feed, err := c.repository.GetFeedByURL(json.URL); err == nil {
+	if feedID, ok := c.findFeedByURL(json.URL); ok {
 		// return the existing feed id
 		return ctx.JSON(httputil.HTTPStatus{
 			Status: "ok",
-			FeedID: feed.ID,
+			FeedID: feedID,
 		})
 	}
 
@@ -196,6 +277,25 @@ func (c *Controller) AddFeed(ctx *fiber.Ctx) error {
 	})
 }
 
+// findFeedByURL returns the id of an existing feed with the given URL.
+// For http URLs an existing https version of the feed is preferred.
+func (c *Controller) findFeedByURL(url string) (feedID uuid.UUID, ok bool) {
+	if helpers.IsHTTPURL(url) {
+		// it's a http URL, so first try looking up if there's a https version in the db
+		httpsURL := helpers.RewriteToHTTPS(url)
+		if feed, err := c.repository.GetFeedByURL(httpsURL); err == nil {
+			// found https version
+			return feed.ID, true
+		}
+		// try the http version next
+	}
+	// look if a feed with this URL already exists
+	if feed, err := c.repository.GetFeedByURL(url); err == nil {
+		return feed.ID, true
+	}
+	return
+}
+
 // AddFeedRequest is the POST body for AddFeed
 type AddFeedRequest struct {
 	URL string `json:"url"`
@@ -227,13 +327,7 @@ func (c *Controller) ChangeFolders(ctx *fiber.Ctx) error {
 	}
 
 	// send event
-	c.userEventRepository.Publish(&UserEventEnvelope{
-		UserID: claims.ID,
-		Payload: common.UserEventMessage{
-			Type: common.MessageTypeFolderUpdate,
-			Data: nil,
-		},
-	})
+	c.publishFolderUpdate(claims.ID)
 
 	return ctx.JSON(httputil.HTTPStatus{
 		Status: "ok",
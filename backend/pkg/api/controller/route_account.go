@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: 2022 spezifisch <spezifisch23@proton.me>
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package controller
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/internal/common"
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/httputil"
+)
+
+// file names in the account export archive
+const (
+	accountExportJSONFile = "rueder-export.json"
+	accountExportOPMLFile = "rueder-subscriptions.opml"
+)
+
+// ExportAccount godoc
+// @Summary Export all data of the user
+// @Description The zip archive contains folders, read state, labels and labelled articles as JSON
+// @Description and the subscriptions as OPML.
+// @Tags user
+// @Produce application/zip
+// @Success 200 {file} file "zip archive"
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /me/export [get]
+func (c *Controller) ExportAccount(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	if claims == nil {
+		return fiber.NewError(fiber.StatusForbidden, "invalid claims")
+	}
+
+	export := UserExport{
+		ExportedAt: time.Now().UTC(),
+		UserID:     claims.ID,
+		Origin:     claims.Origin,
+		Name:       claims.Name,
+	}
+	var err error
+	if export.Folders, err = c.repository.Folders(claims); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "folders not found")
+	}
+	if export.State, err = c.repository.UserState(claims); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "state not found")
+	}
+	if export.Labels, err = c.repository.Labels(claims); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "labels not found")
+	}
+	if export.LabelledArticles, err = c.repository.LabelledArticles(claims); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "labelled articles not found")
+	}
+
+	archive, err := buildAccountExport(&export)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "creating export failed")
+	}
+
+	ctx.Attachment("rueder-export.zip")
+	ctx.Set(fiber.HeaderContentType, "application/zip")
+	return ctx.Send(archive)
+}
+
+// DeleteAccount godoc
+// @Summary Delete the user with subscriptions, read state and labels
+// @Description All clients of the user get logged out.
+// @Tags user
+// @Produce json
+// @Success 200 {object} httputil.HTTPStatus
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /me [delete]
+func (c *Controller) DeleteAccount(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	if claims == nil {
+		return fiber.NewError(fiber.StatusForbidden, "invalid claims")
+	}
+
+	if err := c.repository.DeleteUser(claims.ID); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	c.publishLogout(claims.ID)
+	return ctx.JSON(httputil.HTTPStatus{
+		Status: "ok",
+	})
+}
+
+// buildAccountExport returns a zip archive with the export as JSON and the subscriptions as OPML
+func buildAccountExport(export *UserExport) ([]byte, error) {
+	exportJSON, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	opml, err := buildOPML(export.Folders, export.ExportedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	archive := zip.NewWriter(buf)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{accountExportJSONFile, exportJSON},
+		{accountExportOPMLFile, opml},
+	}
+	for _, file := range files {
+		w, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write(file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err = archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// publishLogout tells all clients of the user to log out
+func (c *Controller) publishLogout(userID uuid.UUID) {
+	c.userEventRepository.Publish(&UserEventEnvelope{
+		UserID: userID,
+		Payload: common.UserEventMessage{
+			Type: common.MessageTypeLogout,
+			Data: nil,
+		},
+	})
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spezifisch/rueder3/backend/internal/common"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+)
+
+type accountTestRepository struct {
+	Repository
+
+	folders        []Folder
+	labels         []Label
+	articles       []LabelledArticle
+	deletedUserIDs []uuid.UUID
+}
+
+func (r *accountTestRepository) Folders(claims *helpers.AuthClaims) ([]Folder, error) {
+	return r.folders, nil
+}
+
+func (r *accountTestRepository) UserState(claims *helpers.AuthClaims) (UserState, error) {
+	return UserState{FeedStates: map[uuid.UUID]UserFeedState{}}, nil
+}
+
+func (r *accountTestRepository) Labels(claims *helpers.AuthClaims) ([]Label, error) {
+	return r.labels, nil
+}
+
+func (r *accountTestRepository) LabelledArticles(claims *helpers.AuthClaims) ([]LabelledArticle, error) {
+	return r.articles, nil
+}
+
+func (r *accountTestRepository) DeleteUser(userID uuid.UUID) error {
+	r.deletedUserIDs = append(r.deletedUserIDs, userID)
+	return nil
+}
+
+func TestController_ExportAccount(t *testing.T) {
+	feedID := uuid.Must(uuid.NewV4())
+	labelID := uuid.Must(uuid.NewV4())
+	repo := &accountTestRepository{
+		folders: []Folder{{
+			Title: "News",
+			Feeds: []Feed{{ID: feedID, Title: "A Feed", URL: "https://example.com/feed.xml"}},
+		}},
+		labels: []Label{{ID: labelID, Name: "keep", ArticleCount: 1}},
+		articles: []LabelledArticle{{
+			Article: Article{
+				ID:      uuid.Must(uuid.NewV4()),
+				FeedID:  feedID,
+				Title:   "An Article",
+				Content: ArticleContent{Text: "<p>content</p>"},
+			},
+			LabelIDs: []uuid.UUID{labelID},
+		}},
+	}
+	c := NewController(repo, &testEventRepository{})
+	userID := uuid.Must(uuid.NewV4())
+	app := newTestApp(c, userID, helpers.RoleUser)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/me/export", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/zip", resp.Header.Get(fiber.HeaderContentType))
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if !assert.NoError(t, err) || !assert.Len(t, archive.File, 2) {
+		return
+	}
+	assert.Equal(t, accountExportJSONFile, archive.File[0].Name)
+	assert.Equal(t, accountExportOPMLFile, archive.File[1].Name)
+
+	f, err := archive.File[0].Open()
+	assert.NoError(t, err)
+	defer f.Close()
+	var export UserExport
+	assert.NoError(t, json.NewDecoder(f).Decode(&export))
+	assert.Equal(t, userID, export.UserID)
+	assert.Equal(t, repo.folders, export.Folders)
+	assert.Equal(t, repo.labels, export.Labels)
+	if assert.Len(t, export.LabelledArticles, 1) {
+		assert.Equal(t, "<p>content</p>", export.LabelledArticles[0].Content.Text)
+		assert.Equal(t, []uuid.UUID{labelID}, export.LabelledArticles[0].LabelIDs)
+	}
+
+	opml, err := archive.File[1].Open()
+	assert.NoError(t, err)
+	defer opml.Close()
+	opmlData, err := io.ReadAll(opml)
+	assert.NoError(t, err)
+	entries, err := parseOPML(opmlData)
+	assert.NoError(t, err)
+	assert.Equal(t, []opmlEntry{{Folder: "News", Title: "A Feed", URL: "https://example.com/feed.xml"}}, entries)
+}
+
+func TestController_DeleteAccount(t *testing.T) {
+	repo := &accountTestRepository{}
+	events := &testEventRepository{}
+	c := NewController(repo, events)
+	userID := uuid.Must(uuid.NewV4())
+	app := newTestApp(c, userID, helpers.RoleUser)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodDelete, "/me", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, []uuid.UUID{userID}, repo.deletedUserIDs)
+
+	// all clients of the user log out
+	if assert.Len(t, events.envelopes, 1) {
+		assert.Equal(t, userID, events.envelopes[0].UserID)
+		assert.Equal(t, common.MessageTypeLogout, events.envelopes[0].Payload.Type)
+	}
+}
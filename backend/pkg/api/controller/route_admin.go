@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2022 spezifisch <spezifisch23@proton.me>
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+	"github.com/spezifisch/rueder3/backend/pkg/httputil"
+)
+
+// the admin role is checked by the middleware of the admin route group
+
+// AdminUsers godoc
+// @Summary Get all users
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} []AdminUser
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /users [get]
+func (c *Controller) AdminUsers(ctx *fiber.Ctx) error {
+	users, err := c.repository.Users()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed fetching users")
+	}
+
+	return ctx.JSON(users)
+}
+
+// AdminDeleteUser godoc
+// @Summary Delete a user with subscriptions, read state and labels
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Success 200 {object} httputil.HTTPStatus
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /user/{user_id} [delete]
+func (c *Controller) AdminDeleteUser(ctx *fiber.Ctx) error {
+	userID, err := uuid.FromString(ctx.Params("user_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid user_id")
+	}
+	if claims := fibertools.GetFiberAuthClaims(ctx); claims.ID == userID {
+		return fiber.NewError(fiber.StatusBadRequest, "can't delete yourself")
+	}
+
+	if err := c.repository.DeleteUser(userID); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+
+	c.publishLogout(userID)
+	return ctx.JSON(httputil.HTTPStatus{
+		Status: "ok",
+	})
+}
+
+// AdminFeeds godoc
+// @Summary Get all feeds with their fetcher state
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param broken query bool false "Only feeds that don't work"
+// @Success 200 {object} []AdminFeed
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /feeds [get]
+func (c *Controller) AdminFeeds(ctx *fiber.Ctx) error {
+	brokenOnly := ctx.QueryBool("broken")
+
+	feeds, err := c.repository.FeedHealth(brokenOnly)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed fetching feeds")
+	}
+
+	return ctx.JSON(feeds)
+}
+
+// AdminRefreshFeed godoc
+// @Summary Fetch a feed now, regardless of subscriptions and rate limits
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param feed_id path string true "Feed ID"
+// @Success 200 {object} httputil.HTTPStatus
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /feed/{feed_id}/refresh [post]
+func (c *Controller) AdminRefreshFeed(ctx *fiber.Ctx) error {
+	return c.adminChangeFeed(ctx, c.repository.ForceRefreshFeed)
+}
+
+// AdminResetFeed godoc
+// @Summary Reset the failure count and backoff of a broken or dead feed and fetch it now
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param feed_id path string true "Feed ID"
+// @Success 200 {object} httputil.HTTPStatus
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /feed/{feed_id}/reset [post]
+func (c *Controller) AdminResetFeed(ctx *fiber.Ctx) error {
+	return c.adminChangeFeed(ctx, c.repository.ResetFeed)
+}
+
+// adminChangeFeed calls change with the feed from the route
+func (c *Controller) adminChangeFeed(ctx *fiber.Ctx, change func(*helpers.AuthClaims, uuid.UUID) error) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	feedID, err := uuid.FromString(ctx.Params("feed_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid feed_id")
+	}
+
+	if err := change(claims, feedID); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+
+	return ctx.JSON(httputil.HTTPStatus{
+		Status: "ok",
+		FeedID: feedID,
+	})
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spezifisch/rueder3/backend/internal/common"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+)
+
+type adminTestRepository struct {
+	Repository
+
+	feeds          []AdminFeed
+	brokenOnly     []bool
+	deletedUserIDs []uuid.UUID
+	resetFeedIDs   []uuid.UUID
+}
+
+func (r *adminTestRepository) FeedHealth(brokenOnly bool) ([]AdminFeed, error) {
+	r.brokenOnly = append(r.brokenOnly, brokenOnly)
+	return r.feeds, nil
+}
+
+func (r *adminTestRepository) DeleteUser(userID uuid.UUID) error {
+	r.deletedUserIDs = append(r.deletedUserIDs, userID)
+	return nil
+}
+
+func (r *adminTestRepository) ResetFeed(claims *helpers.AuthClaims, feedID uuid.UUID) error {
+	r.resetFeedIDs = append(r.resetFeedIDs, feedID)
+	return nil
+}
+
+func TestController_AdminRole(t *testing.T) {
+	repo := &adminTestRepository{}
+	events := &testEventRepository{}
+	c := NewController(repo, events)
+	feedID := uuid.Must(uuid.NewV4())
+	userID := uuid.Must(uuid.NewV4())
+
+	// normal users and tokens without a role claim can't use the admin routes
+	for _, role := range []string{helpers.RoleUser, ""} {
+		app := newTestApp(c, uuid.Must(uuid.NewV4()), role)
+		for _, req := range []*http.Request{
+			httptest.NewRequest(http.MethodGet, "/admin/feeds", nil),
+			httptest.NewRequest(http.MethodDelete, "/admin/user/"+userID.String(), nil),
+			httptest.NewRequest(http.MethodPost, "/admin/feed/"+feedID.String()+"/reset", nil),
+		} {
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+		}
+	}
+	assert.Empty(t, repo.brokenOnly)
+	assert.Empty(t, repo.deletedUserIDs)
+	assert.Empty(t, repo.resetFeedIDs)
+
+	// admins can
+	adminID := uuid.Must(uuid.NewV4())
+	app := newTestApp(c, adminID, helpers.RoleAdmin)
+	resp, err := app.Test(httptest.NewRequest(http.MethodDelete, "/admin/user/"+userID.String(), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, []uuid.UUID{userID}, repo.deletedUserIDs)
+	// the deleted user's clients log out
+	if assert.Len(t, events.envelopes, 1) {
+		assert.Equal(t, userID, events.envelopes[0].UserID)
+		assert.Equal(t, common.MessageTypeLogout, events.envelopes[0].Payload.Type)
+	}
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodPost, "/admin/feed/"+feedID.String()+"/reset", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, []uuid.UUID{feedID}, repo.resetFeedIDs)
+
+	// but not themselves
+	resp, err = app.Test(httptest.NewRequest(http.MethodDelete, "/admin/user/"+adminID.String(), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	assert.Len(t, repo.deletedUserIDs, 1)
+}
+
+func TestController_AdminFeeds(t *testing.T) {
+	feedID := uuid.Must(uuid.NewV4())
+	repo := &adminTestRepository{
+		feeds: []AdminFeed{{
+			ID:           feedID,
+			FetcherState: AdminFetcherState{FailureCount: 3},
+		}},
+	}
+	c := NewController(repo, nil)
+	app := newTestApp(c, uuid.Must(uuid.NewV4()), helpers.RoleAdmin)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/admin/feeds", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/admin/feeds?broken=true", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, []bool{false, true}, repo.brokenOnly)
+
+	var feeds []AdminFeed
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&feeds))
+	if assert.Len(t, feeds, 1) {
+		assert.Equal(t, feedID, feeds[0].ID)
+		assert.Equal(t, 3, feeds[0].FetcherState.FailureCount)
+	}
+}
@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: 2022 spezifisch <spezifisch23@proton.me>
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package controller
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/internal/common"
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/httputil"
+)
+
+// feedTitleMaxLength limits the length of custom feed titles in characters
+const feedTitleMaxLength = 1024
+
+// ChangeFeed godoc
+// @Summary Change the custom title and the fetch preferences of a subscribed feed
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Param feed_id path string      true "Feed ID"
+// @Param request body FeedChanges true "Feed Changes"
+// @Success 200 {object} httputil.HTTPStatus
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /feed/{feed_id} [patch]
+func (c *Controller) ChangeFeed(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	feedID, err := uuid.FromString(ctx.Params("feed_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid feed_id")
+	}
+
+	var json FeedChanges
+	if err := ctx.BodyParser(&json); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "malformed JSON body")
+	}
+	if json.Title != nil {
+		title := strings.TrimSpace(*json.Title)
+		if utf8.RuneCountInString(title) > feedTitleMaxLength {
+			return fiber.NewError(fiber.StatusBadRequest, "feed title too long")
+		}
+		json.Title = &title
+	}
+
+	if err := c.repository.ChangeFeed(claims, feedID, json); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if json.Title != nil {
+		c.publishFolderUpdate(claims.ID)
+	}
+	return ctx.JSON(httputil.HTTPStatus{
+		Status: "ok",
+		FeedID: feedID,
+	})
+}
+
+// DeleteFeed godoc
+// @Summary Delete a feed with all its articles for all users (admin only)
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Param feed_id path string true "Feed ID"
+// @Success 200 {object} httputil.HTTPStatus
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /feed/{feed_id} [delete]
+func (c *Controller) DeleteFeed(ctx *fiber.Ctx) error {
+	// the admin role is checked by the route's middleware
+	feedID, err := uuid.FromString(ctx.Params("feed_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid feed_id")
+	}
+
+	subscriberIDs, err := c.repository.DeleteFeed(feedID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+
+	// the feed is gone from the folders of its subscribers
+	for _, userID := range subscriberIDs {
+		c.publishFolderUpdate(userID)
+	}
+	return ctx.JSON(httputil.HTTPStatus{
+		Status: "ok",
+		FeedID: feedID,
+	})
+}
+
+// publishFolderUpdate tells all clients of the user to reload the folders
+func (c *Controller) publishFolderUpdate(userID uuid.UUID) {
+	c.userEventRepository.Publish(&UserEventEnvelope{
+		UserID: userID,
+		Payload: common.UserEventMessage{
+			Type: common.MessageTypeFolderUpdate,
+			Data: nil,
+		},
+	})
+}
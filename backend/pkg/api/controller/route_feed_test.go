@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spezifisch/rueder3/backend/internal/common"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+)
+
+type feedTestRepository struct {
+	Repository
+
+	subscriberIDs     []uuid.UUID
+	subscribedFeedIDs []uuid.UUID
+	deletedFeedIDs    []uuid.UUID
+	refreshedFeedIDs  []uuid.UUID
+	changes           []FeedChanges
+}
+
+func (r *feedTestRepository) DeleteFeed(feedID uuid.UUID) ([]uuid.UUID, error) {
+	r.deletedFeedIDs = append(r.deletedFeedIDs, feedID)
+	return r.subscriberIDs, nil
+}
+
+func (r *feedTestRepository) ChangeFeed(claims *helpers.AuthClaims, feedID uuid.UUID, changes FeedChanges) error {
+	r.changes = append(r.changes, changes)
+	return nil
+}
+
+func (r *feedTestRepository) RefreshFeed(claims *helpers.AuthClaims, feedID uuid.UUID) error {
+	for _, subscribedFeedID := range r.subscribedFeedIDs {
+		if subscribedFeedID == feedID {
+			r.refreshedFeedIDs = append(r.refreshedFeedIDs, feedID)
+			return nil
+		}
+	}
+	return errors.New("not subscribed to feed")
+}
+
+func TestController_DeleteFeed(t *testing.T) {
+	subscriberIDs := []uuid.UUID{uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())}
+	repo := &feedTestRepository{subscriberIDs: subscriberIDs}
+	events := &testEventRepository{}
+	c := NewController(repo, events)
+	feedID := uuid.Must(uuid.NewV4())
+
+	// normal users can't delete feeds
+	app := newTestApp(c, uuid.Must(uuid.NewV4()), helpers.RoleUser)
+	resp, err := app.Test(httptest.NewRequest(http.MethodDelete, "/feed/"+feedID.String(), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	assert.Empty(t, repo.deletedFeedIDs)
+
+	// admins can
+	app = newTestApp(c, uuid.Must(uuid.NewV4()), helpers.RoleAdmin)
+	resp, err = app.Test(httptest.NewRequest(http.MethodDelete, "/feed/"+feedID.String(), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, []uuid.UUID{feedID}, repo.deletedFeedIDs)
+
+	// the subscribers reload their folders
+	if assert.Len(t, events.envelopes, 2) {
+		for i, envelope := range events.envelopes {
+			assert.Equal(t, subscriberIDs[i], envelope.UserID)
+			assert.Equal(t, common.MessageTypeFolderUpdate, envelope.Payload.Type)
+		}
+	}
+}
+
+func TestController_ChangeFeed(t *testing.T) {
+	repo := &feedTestRepository{}
+	events := &testEventRepository{}
+	c := NewController(repo, events)
+	userID := uuid.Must(uuid.NewV4())
+	app := newTestApp(c, userID, helpers.RoleUser)
+	feedURL := "/feed/" + uuid.Must(uuid.NewV4()).String()
+
+	patch := func(body string) int {
+		req := httptest.NewRequest(http.MethodPatch, feedURL, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	// nothing changes, the folders stay the same
+	assert.Equal(t, fiber.StatusOK, patch(`{}`))
+	if assert.Len(t, repo.changes, 1) {
+		assert.Nil(t, repo.changes[0].Title)
+	}
+	assert.Empty(t, events.envelopes)
+
+	// a new title is trimmed and the folders are reloaded
+	assert.Equal(t, fiber.StatusOK, patch(`{"title": "  My Feed  "}`))
+	if assert.Len(t, repo.changes, 2) && assert.NotNil(t, repo.changes[1].Title) {
+		assert.Equal(t, "My Feed", *repo.changes[1].Title)
+	}
+	if assert.Len(t, events.envelopes, 1) {
+		assert.Equal(t, userID, events.envelopes[0].UserID)
+	}
+
+	// the fetch preference doesn't change the folders
+	assert.Equal(t, fiber.StatusOK, patch(`{"fetch_full_article": true}`))
+	if assert.Len(t, repo.changes, 3) && assert.NotNil(t, repo.changes[2].FetchFullArticle) {
+		assert.True(t, *repo.changes[2].FetchFullArticle)
+		assert.Nil(t, repo.changes[2].Title)
+	}
+	assert.Len(t, events.envelopes, 1)
+
+	assert.Equal(t, fiber.StatusBadRequest, patch(`{"title": "`+strings.Repeat("x", feedTitleMaxLength+1)+`"}`))
+	assert.Equal(t, fiber.StatusBadRequest, patch(`{"title": `))
+	assert.Len(t, repo.changes, 3)
+}
@@ -0,0 +1,244 @@
+// SPDX-FileCopyrightText: 2022 spezifisch <spezifisch23@proton.me>
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/internal/common"
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+	"github.com/spezifisch/rueder3/backend/pkg/httputil"
+)
+
+// Labels godoc
+// @Summary Get label list
+// @Tags label
+// @Accept json
+// @Produce json
+// @Success 200 {object} []Label
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /labels [get]
+func (c *Controller) Labels(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	labels, err := c.repository.Labels(claims)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "labels not found")
+	}
+
+	return ctx.JSON(labels)
+}
+
+// AddLabel godoc
+// @Summary Add label
+// @Tags label
+// @Accept json
+// @Produce json
+// @Param request body LabelRequest true "Label name and color"
+// @Success 200 {object} Label
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /label [post]
+func (c *Controller) AddLabel(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+
+	var json LabelRequest
+	if err := ctx.BodyParser(&json); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "malformed JSON body")
+	}
+
+	label, err := c.repository.AddLabel(claims, json.Name, json.Color)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	c.publishLabelUpdate(claims, label.ID)
+	return ctx.JSON(label)
+}
+
+// ChangeLabel godoc
+// @Summary Change label name and color
+// @Tags label
+// @Accept json
+// @Produce json
+// @Param label_id path string true "Label ID"
+// @Param request body LabelRequest true "Label name and color"
+// @Success 200 {object} Label
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /label/{label_id} [put]
+func (c *Controller) ChangeLabel(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	labelID, err := uuid.FromString(ctx.Params("label_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid label_id")
+	}
+
+	var json LabelRequest
+	if err := ctx.BodyParser(&json); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "malformed JSON body")
+	}
+
+	label, err := c.repository.ChangeLabel(claims, labelID, json.Name, json.Color)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	c.publishLabelUpdate(claims, labelID)
+	return ctx.JSON(label)
+}
+
+// DeleteLabel godoc
+// @Summary Delete label
+// @Tags label
+// @Accept json
+// @Produce json
+// @Param label_id path string true "Label ID"
+// @Success 200 {object} httputil.HTTPStatus
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /label/{label_id} [delete]
+func (c *Controller) DeleteLabel(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	labelID, err := uuid.FromString(ctx.Params("label_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid label_id")
+	}
+
+	if err := c.repository.DeleteLabel(claims, labelID); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+
+	c.publishLabelUpdate(claims, labelID)
+	return ctx.JSON(httputil.HTTPStatus{
+		Status: "ok",
+	})
+}
+
+// LabelArticles godoc
+// @Summary Get list of articles with the label
+// @Tags label
+// @Accept json
+// @Produce json
+// @Param label_id path  string true  "Label ID"
+// @Param start    query int    false "Start Token"
+// @Success 200 {object} []ArticlePreview
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /label/{label_id}/articles [get]
+func (c *Controller) LabelArticles(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	labelID, err := uuid.FromString(ctx.Params("label_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid label_id")
+	}
+
+	limit := c.articlesPerPage
+	offset, err := strconv.Atoi(ctx.Query("start"))
+	if err != nil {
+		offset = 0
+	}
+
+	articles, err := c.repository.GetLabelArticles(claims, labelID, limit, offset)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "articles not found")
+	}
+
+	return ctx.JSON(articles)
+}
+
+// AssignLabel godoc
+// @Summary Assign label to article
+// @Tags label
+// @Accept json
+// @Produce json
+// @Param label_id   path string true "Label ID"
+// @Param article_id path string true "Article ID"
+// @Success 200 {object} httputil.HTTPStatus
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /label/{label_id}/article/{article_id} [post]
+func (c *Controller) AssignLabel(ctx *fiber.Ctx) error {
+	return c.changeLabelAssignment(ctx, true)
+}
+
+// UnassignLabel godoc
+// @Summary Remove label from article
+// @Tags label
+// @Accept json
+// @Produce json
+// @Param label_id   path string true "Label ID"
+// @Param article_id path string true "Article ID"
+// @Success 200 {object} httputil.HTTPStatus
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /label/{label_id}/article/{article_id} [delete]
+func (c *Controller) UnassignLabel(ctx *fiber.Ctx) error {
+	return c.changeLabelAssignment(ctx, false)
+}
+
+// LabelRequest is the body for AddLabel and ChangeLabel
+type LabelRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+func (c *Controller) changeLabelAssignment(ctx *fiber.Ctx, assign bool) (err error) {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	labelID, err := uuid.FromString(ctx.Params("label_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid label_id")
+	}
+	articleID, err := uuid.FromString(ctx.Params("article_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid article_id")
+	}
+
+	if assign {
+		err = c.repository.AssignLabel(claims, labelID, articleID)
+	} else {
+		err = c.repository.UnassignLabel(claims, labelID, articleID)
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	c.publishLabelUpdate(claims, labelID)
+	return ctx.JSON(httputil.HTTPStatus{
+		Status: "ok",
+	})
+}
+
+// publishLabelUpdate tells the user's other clients to reload the label
+func (c *Controller) publishLabelUpdate(claims *helpers.AuthClaims, labelID uuid.UUID) {
+	c.userEventRepository.Publish(&UserEventEnvelope{
+		UserID: claims.ID,
+		Payload: common.UserEventMessage{
+			Type: common.MessageTypeLabelUpdate,
+			Data: map[string]string{
+				"label_id": labelID.String(),
+			},
+		},
+	})
+}
@@ -0,0 +1,241 @@
+// SPDX-FileCopyrightText: 2022 spezifisch <spezifisch23@proton.me>
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package controller
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/internal/common"
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+)
+
+// OPML import entry states
+const (
+	OPMLImportStatusAdded   = "added"   // feed was subscribed
+	OPMLImportStatusExists  = "exists"  // feed was already subscribed
+	OPMLImportStatusSkipped = "skipped" // feed wasn't subscribed because of a limit
+	OPMLImportStatusFailed  = "failed"  // feed couldn't be added
+)
+
+// opmlMaxFileSize limits the size of uploaded OPML files
+const opmlMaxFileSize = 2 * 1024 * 1024
+
+// ExportOPML godoc
+// @Summary Export folders and feeds as OPML
+// @Tags feed
+// @Produce xml
+// @Success 200 {string} string "OPML 2.0 document"
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /opml [get]
+func (c *Controller) ExportOPML(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	folders, err := c.repository.Folders(claims)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "folders not found")
+	}
+
+	opml, err := buildOPML(folders, time.Now())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "creating OPML failed")
+	}
+
+	ctx.Attachment("rueder-subscriptions.opml")
+	ctx.Set(fiber.HeaderContentType, "text/x-opml; charset=utf-8")
+	return ctx.Send(opml)
+}
+
+// ImportOPML godoc
+// @Summary Import folders and feeds from OPML
+// @Description The OPML file can be sent as request body or as multipart form field "file".
+// @Description Feeds are merged into existing folders with the same title.
+// @Tags feed
+// @Accept xml
+// @Accept mpfd
+// @Produce json
+// @Param file formData file false "OPML file"
+// @Success 200 {object} OPMLImportResponse
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /opml [post]
+func (c *Controller) ImportOPML(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+
+	data, err := readOPMLUpload(ctx)
+	if err != nil {
+		return err
+	}
+	entries, err := parseOPML(data)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "malformed OPML file")
+	}
+
+	folders, err := c.repository.Folders(claims)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "folders not found")
+	}
+
+	report, newFeeds, err := c.mergeOPMLEntries(&folders, entries)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "importing feeds failed")
+	}
+
+	// nothing is created if the folders can't be saved
+	err = c.repository.ImportFolders(claims, folders, newFeeds)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	// send event
+	c.userEventRepository.Publish(&UserEventEnvelope{
+		UserID: claims.ID,
+		Payload: common.UserEventMessage{
+			Type: common.MessageTypeFolderUpdate,
+			Data: nil,
+		},
+	})
+
+	return ctx.JSON(report)
+}
+
+// OPMLImportResponse is the report of an OPML import with one entry per feed in the file
+type OPMLImportResponse struct {
+	Entries []OPMLImportEntry `json:"entries"`
+}
+
+// OPMLImportEntry is the import result of a single feed
+type OPMLImportEntry struct {
+	Folder  string     `json:"folder"`
+	Title   string     `json:"title,omitempty"`
+	URL     string     `json:"url"`
+	FeedID  *uuid.UUID `json:"feed_id,omitempty"`
+	Status  string     `json:"status"`
+	Message string     `json:"message,omitempty"`
+}
+
+func readOPMLUpload(ctx *fiber.Ctx) ([]byte, error) {
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		// not a multipart upload, use the plain body
+		if len(ctx.Body()) == 0 {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "no OPML file given")
+		}
+		if len(ctx.Body()) > opmlMaxFileSize {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "OPML file too large")
+		}
+		return ctx.Body(), nil
+	}
+	if fileHeader.Size > opmlMaxFileSize {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "OPML file too large")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "can't read OPML file")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, opmlMaxFileSize))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "can't read OPML file")
+	}
+	return data, nil
+}
+
+// mergeOPMLEntries adds the feeds of the OPML file to the folders, creating folders as needed.
+// Feeds that don't exist yet get a new id and are returned as newFeeds, they have to be created with the folders.
+func (c *Controller) mergeOPMLEntries(folders *[]Folder, entries []opmlEntry) (report OPMLImportResponse, newFeeds []NewFeed, err error) {
+	folderCountLimit, folderFeedCountLimit := c.repository.FolderLimits()
+
+	// new feeds by URL, so that a feed that's in the file twice is only created once
+	newFeedIDs := make(map[string]uuid.UUID)
+
+	// existing folders by title and already subscribed feeds
+	folderIndex := make(map[string]int)
+	subscribed := make(map[uuid.UUID]bool)
+	for i, folder := range *folders {
+		title := strings.TrimSpace(folder.Title)
+		if _, ok := folderIndex[title]; !ok {
+			folderIndex[title] = i
+		}
+		for _, feed := range folder.Feeds {
+			subscribed[feed.ID] = true
+		}
+	}
+
+	report.Entries = make([]OPMLImportEntry, len(entries))
+	for i, entry := range entries {
+		result := &report.Entries[i]
+		result.Folder = entry.Folder
+		result.Title = entry.Title
+		result.URL = entry.URL
+
+		if !helpers.IsURL(entry.URL) {
+			result.Status = OPMLImportStatusFailed
+			result.Message = "not a valid URL"
+			continue
+		}
+
+		feedID, exists := newFeedIDs[entry.URL]
+		if !exists {
+			feedID, exists = c.findFeedByURL(entry.URL)
+		}
+		if exists && subscribed[feedID] {
+			result.FeedID = &feedID
+			result.Status = OPMLImportStatusExists
+			continue
+		}
+
+		// check limits before creating anything
+		idx, ok := folderIndex[entry.Folder]
+		if !ok && len(*folders) >= folderCountLimit {
+			result.Status = OPMLImportStatusSkipped
+			result.Message = "too many folders"
+			continue
+		}
+		if ok && len((*folders)[idx].Feeds) >= folderFeedCountLimit {
+			result.Status = OPMLImportStatusSkipped
+			result.Message = "too many feeds in folder"
+			continue
+		}
+
+		if !exists {
+			feedID, err = uuid.NewV4()
+			if err != nil {
+				return
+			}
+			newFeedIDs[entry.URL] = feedID
+			newFeeds = append(newFeeds, NewFeed{
+				ID:  feedID,
+				URL: entry.URL,
+			})
+		}
+
+		if !ok {
+			// new folder, the id is generated when saving
+			*folders = append(*folders, Folder{Title: entry.Folder})
+			idx = len(*folders) - 1
+			folderIndex[entry.Folder] = idx
+		}
+		(*folders)[idx].Feeds = append((*folders)[idx].Feeds, Feed{
+			ID:    feedID,
+			Title: entry.Title,
+		})
+		subscribed[feedID] = true
+
+		result.FeedID = &feedID
+		result.Status = OPMLImportStatusAdded
+	}
+	return
+}
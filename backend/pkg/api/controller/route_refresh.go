@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2022 spezifisch <spezifisch23@proton.me>
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package controller
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/httputil"
+)
+
+const (
+	// refreshUserInterval is the minimum time between two refresh requests of a user
+	refreshUserInterval = 10 * time.Second
+	// refreshFeedInterval is the minimum time between two refresh requests for the same feed by anyone
+	refreshFeedInterval = time.Minute
+	// refreshLimiterPruneSize is the map size from which expired entries are removed
+	refreshLimiterPruneSize = 1024
+)
+
+// refreshLimiter limits the refresh requests per user and per feed
+type refreshLimiter struct {
+	mutex    sync.Mutex
+	lastUser map[uuid.UUID]time.Time
+	lastFeed map[uuid.UUID]time.Time
+}
+
+func newRefreshLimiter() *refreshLimiter {
+	return &refreshLimiter{
+		lastUser: make(map[uuid.UUID]time.Time),
+		lastFeed: make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Wait returns how long the user has to wait until they may refresh the feed, 0 if they may refresh it now
+func (l *refreshLimiter) Wait(userID uuid.UUID, feedID uuid.UUID, now time.Time) (retryAfter time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if last, ok := l.lastUser[userID]; ok {
+		retryAfter = last.Add(refreshUserInterval).Sub(now)
+	}
+	if last, ok := l.lastFeed[feedID]; ok {
+		if wait := last.Add(refreshFeedInterval).Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+	return
+}
+
+// Record remembers the refresh for the limits
+func (l *refreshLimiter) Record(userID uuid.UUID, feedID uuid.UUID, now time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	pruneRefreshTimes(l.lastUser, now.Add(-refreshUserInterval))
+	pruneRefreshTimes(l.lastFeed, now.Add(-refreshFeedInterval))
+	l.lastUser[userID] = now
+	l.lastFeed[feedID] = now
+}
+
+// pruneRefreshTimes removes the entries before expiry once the map has grown big enough
+func pruneRefreshTimes(lastRefresh map[uuid.UUID]time.Time, expiry time.Time) {
+	if len(lastRefresh) < refreshLimiterPruneSize {
+		return
+	}
+	for id, last := range lastRefresh {
+		if last.Before(expiry) {
+			delete(lastRefresh, id)
+		}
+	}
+}
+
+// RefreshFeed godoc
+// @Summary Fetch a subscribed feed now
+// @Description The feed is fetched as soon as a worker is free. The result is sent as feed_refreshed event.
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Param feed_id path string true "Feed ID"
+// @Success 200 {object} httputil.HTTPStatus
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 429 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /feed/{feed_id}/refresh [post]
+func (c *Controller) RefreshFeed(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	feedID, err := uuid.FromString(ctx.Params("feed_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid feed_id")
+	}
+
+	if retryAfter := c.refreshLimiter.Wait(claims.ID, feedID, time.Now()); retryAfter > 0 {
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		return fiber.NewError(fiber.StatusTooManyRequests, "feed was refreshed recently")
+	}
+
+	// the repository checks the subscription, only refreshes that were requested count for the limits
+	if err := c.repository.RefreshFeed(claims, feedID); err != nil {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	c.refreshLimiter.Record(claims.ID, feedID, time.Now())
+
+	return ctx.JSON(httputil.HTTPStatus{
+		Status: "ok",
+		FeedID: feedID,
+	})
+}
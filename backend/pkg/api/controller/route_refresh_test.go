@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+)
+
+func TestRefreshLimiter_Wait(t *testing.T) {
+	l := newRefreshLimiter()
+	now := time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC)
+	userA := uuid.Must(uuid.NewV4())
+	userB := uuid.Must(uuid.NewV4())
+	feedA := uuid.Must(uuid.NewV4())
+	feedB := uuid.Must(uuid.NewV4())
+
+	assert.Zero(t, l.Wait(userA, feedA, now))
+	assert.Zero(t, l.Wait(userA, feedA, now), "only recorded refreshes count")
+	l.Record(userA, feedA, now)
+	assert.Equal(t, refreshUserInterval, l.Wait(userA, feedB, now), "user limit")
+	assert.Equal(t, refreshFeedInterval, l.Wait(userB, feedA, now), "feed limit")
+	assert.Zero(t, l.Wait(userB, feedB, now))
+
+	now = now.Add(refreshUserInterval)
+	assert.Equal(t, refreshFeedInterval-refreshUserInterval, l.Wait(userA, feedA, now), "feed limit applies to the same user too")
+	assert.Zero(t, l.Wait(userA, feedB, now.Add(refreshFeedInterval)))
+}
+
+func TestController_RefreshFeed(t *testing.T) {
+	feedID := uuid.Must(uuid.NewV4())
+	repo := &feedTestRepository{subscribedFeedIDs: []uuid.UUID{feedID}}
+	c := NewController(repo, &testEventRepository{})
+	app := newTestApp(c, uuid.Must(uuid.NewV4()), helpers.RoleUser)
+
+	refresh := func(feedID uuid.UUID) int {
+		resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/feed/"+feedID.String()+"/refresh", nil))
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	// feeds the user doesn't subscribe to can't be refreshed and don't count for the limits
+	assert.Equal(t, fiber.StatusForbidden, refresh(uuid.Must(uuid.NewV4())))
+	assert.Equal(t, fiber.StatusOK, refresh(feedID))
+	assert.Equal(t, []uuid.UUID{feedID}, repo.refreshedFeedIDs)
+
+	assert.Equal(t, fiber.StatusTooManyRequests, refresh(feedID))
+	assert.Equal(t, []uuid.UUID{feedID}, repo.refreshedFeedIDs)
+}
@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2022 spezifisch <spezifisch23@proton.me>
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package controller
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+)
+
+// searchQueryMaxLength limits the length of search queries in characters
+const searchQueryMaxLength = 256
+
+// Search godoc
+// @Summary Search articles of subscribed feeds
+// @Description Searches title, teaser and text of the articles. Supports quoted phrases, "or" and "-" to exclude words.
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Param q         query string true  "Search Query"
+// @Param folder_id query string false "Only search feeds in this folder"
+// @Param feed_id   query string false "Only search this feed"
+// @Param start     query int    false "Start Token"
+// @Success 200 {object} []ArticlePreview
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /search [get]
+func (c *Controller) Search(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+
+	query := strings.TrimSpace(ctx.Query("q"))
+	if query == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "empty search query")
+	}
+	if utf8.RuneCountInString(query) > searchQueryMaxLength {
+		return fiber.NewError(fiber.StatusBadRequest, "search query too long")
+	}
+
+	// nil means all subscribed feeds
+	var feedIDs []uuid.UUID
+	if folderIDParam := ctx.Query("folder_id"); folderIDParam != "" {
+		folderID, err := uuid.FromString(folderIDParam)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid folder_id")
+		}
+		feedIDs, err = c.repository.FolderFeedIDs(claims, folderID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "folder not found")
+		}
+		if feedIDs == nil {
+			feedIDs = []uuid.UUID{}
+		}
+	} else if feedIDParam := ctx.Query("feed_id"); feedIDParam != "" {
+		feedID, err := uuid.FromString(feedIDParam)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid feed_id")
+		}
+		feedIDs = []uuid.UUID{feedID}
+	}
+
+	limit := c.articlesPerPage
+	offset, err := strconv.Atoi(ctx.Query("start"))
+	if err != nil {
+		offset = 0
+	}
+
+	articles, err := c.repository.SearchArticles(claims, query, feedIDs, limit, offset)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "articles not found")
+	}
+
+	return ctx.JSON(articles)
+}
@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: 2022 spezifisch <spezifisch23@proton.me>
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/internal/common"
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+)
+
+// State godoc
+// @Summary Get read state of all feeds
+// @Tags state
+// @Accept json
+// @Produce json
+// @Success 200 {object} UserState
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /state [get]
+func (c *Controller) State(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	state, err := c.repository.UserState(claims)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "state not found")
+	}
+
+	return ctx.JSON(state)
+}
+
+// MarkFeedArticlesRead godoc
+// @Summary Mark articles of a feed as read
+// @Tags state
+// @Accept json
+// @Produce json
+// @Param feed_id path string true "Feed ID"
+// @Param request body MarkArticlesRequest true "Article seqs"
+// @Success 200 {object} UserState
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /state/feed/{feed_id}/read [post]
+func (c *Controller) MarkFeedArticlesRead(ctx *fiber.Ctx) error {
+	return c.markFeedArticles(ctx, true)
+}
+
+// MarkFeedArticlesUnread godoc
+// @Summary Mark articles of a feed as unread
+// @Tags state
+// @Accept json
+// @Produce json
+// @Param feed_id path string true "Feed ID"
+// @Param request body MarkArticlesRequest true "Article seqs"
+// @Success 200 {object} UserState
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /state/feed/{feed_id}/unread [post]
+func (c *Controller) MarkFeedArticlesUnread(ctx *fiber.Ctx) error {
+	return c.markFeedArticles(ctx, false)
+}
+
+// MarkFeedAllRead godoc
+// @Summary Mark all articles of a feed as read
+// @Tags state
+// @Accept json
+// @Produce json
+// @Param feed_id path string true "Feed ID"
+// @Param request body MarkAllReadRequest false "Newest seq to mark"
+// @Success 200 {object} UserState
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /state/feed/{feed_id}/read_all [post]
+func (c *Controller) MarkFeedAllRead(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	feedID, err := uuid.FromString(ctx.Params("feed_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid feed_id")
+	}
+
+	return c.markAllRead(ctx, claims, []uuid.UUID{feedID}, "feed_id", feedID)
+}
+
+// MarkFolderArticlesRead godoc
+// @Summary Mark articles of any feeds in a folder as read
+// @Tags state
+// @Accept json
+// @Produce json
+// @Param folder_id path string true "Folder ID"
+// @Param request body MarkArticlesRequest true "Article seqs"
+// @Success 200 {object} UserState
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /state/folder/{folder_id}/read [post]
+func (c *Controller) MarkFolderArticlesRead(ctx *fiber.Ctx) error {
+	return c.markFolderArticles(ctx, true)
+}
+
+// MarkFolderArticlesUnread godoc
+// @Summary Mark articles of any feeds in a folder as unread
+// @Tags state
+// @Accept json
+// @Produce json
+// @Param folder_id path string true "Folder ID"
+// @Param request body MarkArticlesRequest true "Article seqs"
+// @Success 200 {object} UserState
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /state/folder/{folder_id}/unread [post]
+func (c *Controller) MarkFolderArticlesUnread(ctx *fiber.Ctx) error {
+	return c.markFolderArticles(ctx, false)
+}
+
+// MarkFolderAllRead godoc
+// @Summary Mark all articles of all feeds in a folder as read
+// @Tags state
+// @Accept json
+// @Produce json
+// @Param folder_id path string true "Folder ID"
+// @Param request body MarkAllReadRequest false "Newest seq to mark"
+// @Success 200 {object} UserState
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /state/folder/{folder_id}/read_all [post]
+func (c *Controller) MarkFolderAllRead(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	folderID, feedIDs, err := c.folderFeedIDs(ctx, claims)
+	if err != nil {
+		return err
+	}
+
+	return c.markAllRead(ctx, claims, feedIDs, "folder_id", folderID)
+}
+
+// MarkArticlesRequest is the POST body for marking articles as read or unread
+type MarkArticlesRequest struct {
+	Seqs []int `json:"seqs"`
+}
+
+// MarkAllReadRequest is the optional POST body for marking all articles as read.
+// Until should be the newest seq the client has seen so articles that arrived in the meantime stay unread.
+type MarkAllReadRequest struct {
+	Until int `json:"until,omitempty"`
+}
+
+func (c *Controller) markFeedArticles(ctx *fiber.Ctx, read bool) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	feedID, err := uuid.FromString(ctx.Params("feed_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid feed_id")
+	}
+
+	return c.markArticles(ctx, claims, []uuid.UUID{feedID}, read, "feed_id", feedID)
+}
+
+func (c *Controller) markFolderArticles(ctx *fiber.Ctx, read bool) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	folderID, feedIDs, err := c.folderFeedIDs(ctx, claims)
+	if err != nil {
+		return err
+	}
+
+	return c.markArticles(ctx, claims, feedIDs, read, "folder_id", folderID)
+}
+
+func (c *Controller) folderFeedIDs(ctx *fiber.Ctx, claims *helpers.AuthClaims) (folderID uuid.UUID, feedIDs []uuid.UUID, err error) {
+	folderID, err = uuid.FromString(ctx.Params("folder_id"))
+	if err != nil {
+		err = fiber.NewError(fiber.StatusBadRequest, "invalid folder_id")
+		return
+	}
+
+	feedIDs, err = c.repository.FolderFeedIDs(claims, folderID)
+	if err != nil {
+		err = fiber.NewError(fiber.StatusNotFound, "folder not found")
+	}
+	return
+}
+
+func (c *Controller) markArticles(ctx *fiber.Ctx, claims *helpers.AuthClaims, feedIDs []uuid.UUID, read bool, scope string, scopeID uuid.UUID) error {
+	var json MarkArticlesRequest
+	if err := ctx.BodyParser(&json); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "malformed JSON body")
+	}
+
+	state, err := c.repository.MarkArticles(claims, feedIDs, json.Seqs, read)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	c.publishStateUpdate(claims, scope, scopeID)
+	return ctx.JSON(state)
+}
+
+func (c *Controller) markAllRead(ctx *fiber.Ctx, claims *helpers.AuthClaims, feedIDs []uuid.UUID, scope string, scopeID uuid.UUID) error {
+	var json MarkAllReadRequest
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&json); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "malformed JSON body")
+		}
+	}
+
+	state, err := c.repository.MarkAllRead(claims, feedIDs, json.Until)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	c.publishStateUpdate(claims, scope, scopeID)
+	return ctx.JSON(state)
+}
+
+// publishStateUpdate tells the user's other clients to reload the read state
+func (c *Controller) publishStateUpdate(claims *helpers.AuthClaims, scope string, scopeID uuid.UUID) {
+	c.userEventRepository.Publish(&UserEventEnvelope{
+		UserID: claims.ID,
+		Payload: common.UserEventMessage{
+			Type: common.MessageTypeStateUpdate,
+			Data: map[string]string{
+				scope: scopeID.String(),
+			},
+		},
+	})
+}
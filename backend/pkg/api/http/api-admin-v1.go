@@ -0,0 +1,18 @@
+package http
+
+import (
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+)
+
+// @BasePath /api/admin/v1
+func (s *Server) addRoutesApiAdminV1() {
+	v1 := s.app.Group("/api/admin/v1", fibertools.NewFiberRoleMiddleware(helpers.RoleAdmin))
+	{
+		v1.Get("/users", s.controller.AdminUsers)
+		v1.Delete("/user/:user_id", s.controller.AdminDeleteUser)
+		v1.Get("/feeds", s.controller.AdminFeeds)
+		v1.Post("/feed/:feed_id/refresh", s.controller.AdminRefreshFeed)
+		v1.Post("/feed/:feed_id/reset", s.controller.AdminResetFeed)
+	}
+}
@@ -1,5 +1,10 @@
 package http
 
+import (
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+)
+
 // @title rueder3 API
 // @version 1.0
 // @description Feed Reader API
@@ -23,11 +28,37 @@ func (s *Server) addRoutesApiReaderV1() {
 		// not tied so the user:
 		v1.Get("/article/:id", s.controller.Article)
 		v1.Get("/articles/:feed_id", s.controller.Articles)
-		v1.Get("/feeds", s.controller.Feeds)
 		v1.Get("/feed/:feed_id", s.controller.GetFeed)
 		v1.Post("/feed", s.controller.AddFeed)
+		// admin only:
+		v1.Delete("/feed/:feed_id", fibertools.NewFiberRoleMiddleware(helpers.RoleAdmin), s.controller.DeleteFeed)
+		v1.Post("/feed/:feed_id/settings", fibertools.NewFiberRoleMiddleware(helpers.RoleAdmin), s.controller.ChangeFeedSettings)
 		// tied to the user:
+		v1.Get("/feeds", s.controller.Feeds)
+		v1.Patch("/feed/:feed_id", s.controller.ChangeFeed)
+		v1.Get("/articles", s.controller.AllArticles)
+		v1.Get("/folder/:folder_id/articles", s.controller.FolderArticles)
+		v1.Post("/feed/:feed_id/refresh", s.controller.RefreshFeed)
 		v1.Get("/folders", s.controller.Folders)
 		v1.Post("/folders", s.controller.ChangeFolders)
+		v1.Get("/opml", s.controller.ExportOPML)
+		v1.Post("/opml", s.controller.ImportOPML)
+		v1.Get("/search", s.controller.Search)
+		v1.Get("/state", s.controller.State)
+		v1.Post("/state/feed/:feed_id/read", s.controller.MarkFeedArticlesRead)
+		v1.Post("/state/feed/:feed_id/unread", s.controller.MarkFeedArticlesUnread)
+		v1.Post("/state/feed/:feed_id/read_all", s.controller.MarkFeedAllRead)
+		v1.Post("/state/folder/:folder_id/read", s.controller.MarkFolderArticlesRead)
+		v1.Post("/state/folder/:folder_id/unread", s.controller.MarkFolderArticlesUnread)
+		v1.Post("/state/folder/:folder_id/read_all", s.controller.MarkFolderAllRead)
+		v1.Get("/labels", s.controller.Labels)
+		v1.Post("/label", s.controller.AddLabel)
+		v1.Put("/label/:label_id", s.controller.ChangeLabel)
+		v1.Delete("/label/:label_id", s.controller.DeleteLabel)
+		v1.Get("/label/:label_id/articles", s.controller.LabelArticles)
+		v1.Post("/label/:label_id/article/:article_id", s.controller.AssignLabel)
+		v1.Delete("/label/:label_id/article/:article_id", s.controller.UnassignLabel)
+		v1.Get("/me/export", s.controller.ExportAccount)
+		v1.Delete("/me", s.controller.DeleteAccount)
 	}
 }
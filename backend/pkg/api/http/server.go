@@ -1,11 +1,15 @@
 package http
 
 import (
+	"context"
+	"time"
+
 	"github.com/apex/log"
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/spezifisch/rueder3/backend/pkg/api/controller"
 	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/health"
 )
 
 // Server is a http server
@@ -15,12 +19,14 @@ type Server struct {
 	app               *fiber.App
 	controller        *controller.Controller
 	jwtSecretKey      string
+	apiTokens         fibertools.APITokenValidator
 	isDevelopmentMode bool
+	readinessChecks   health.Checks
 	trustedProxies    []string
 }
 
 // NewServer creates a default http backend
-func NewServer(controller *controller.Controller, jwtSecretKey string, isDevelopmentMode bool, trustedProxies []string) *Server {
+func NewServer(controller *controller.Controller, jwtSecretKey string, apiTokens fibertools.APITokenValidator, isDevelopmentMode bool, trustedProxies []string, readinessChecks health.Checks) *Server {
 	if controller == nil {
 		panic("controller is nil")
 	}
@@ -29,7 +35,9 @@ func NewServer(controller *controller.Controller, jwtSecretKey string, isDevelop
 		Bind:              ":8080",
 		controller:        controller,
 		jwtSecretKey:      jwtSecretKey,
+		apiTokens:         apiTokens,
 		isDevelopmentMode: isDevelopmentMode,
+		readinessChecks:   readinessChecks,
 		trustedProxies:    trustedProxies,
 	}
 	s.init()
@@ -46,8 +54,14 @@ func (s *Server) init() {
 	enableTrustedProxyCheck := !s.isDevelopmentMode
 	s.app = fibertools.NewFiberRuederApp(appName, s.isDevelopmentMode, enableTrustedProxyCheck, nil)
 
+	// request latency for prometheus
+	s.app.Use(fibertools.NewFiberMetricsMiddleware())
+
+	// health checks for the orchestrator don't require auth
+	fibertools.AddFiberHealthRoutes(s.app, s.readinessChecks)
+
 	// add auth middleware, all following routes require auth
-	authMiddleware, err := fibertools.NewFiberAuthMiddleware(s.jwtSecretKey)
+	authMiddleware, err := fibertools.NewFiberAuthMiddleware(s.jwtSecretKey, s.apiTokens)
 	if err != nil {
 		log.WithError(err).Error("couldn't setup jwt auth middleware")
 		return
@@ -56,12 +70,25 @@ func (s *Server) init() {
 
 	// add routes
 	s.addRoutesApiReaderV1()
+	s.addRoutesApiAdminV1()
 }
 
-// Run starts the server
-func (s *Server) Run() {
+// Run starts the server. When ctx is cancelled it stops accepting connections and returns
+// after the open ones are finished, waiting at most shutdownTimeout for them.
+func (s *Server) Run(ctx context.Context, shutdownTimeout time.Duration) {
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+
+		<-ctx.Done()
+		if err := s.app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+			log.WithError(err).Error("http server shutdown failed")
+		}
+	}()
+
 	err := s.app.Listen(s.Bind)
 	if err != nil {
 		log.WithError(err).Fatal("http server failed")
 	}
+	<-shutdownDone
 }
@@ -1,13 +1,72 @@
 package controller
 
+import (
+	"strings"
+	"time"
+
+	"github.com/spezifisch/rueder3/backend/pkg/authbackend/oidc"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+)
+
 // Controller for API v1
 type Controller struct {
 	repository Repository
+	// users that get the admin role by origin:subject
+	admins map[string]bool
+
+	// JWTs for local accounts are only issued with a secret key
+	jwtSecretKey string
+	jwtExpiry    time.Duration
+	// new local accounts can be registered
+	registration bool
+	// limits password guesses and hashing per client
+	loginLimiter *loginLimiter
+
+	// optional OpenID Connect login, the frontend gets the JWT from the callback
+	oidc            *oidc.Provider
+	oidcFrontendURL string
 }
 
 // NewController for API v1
 func NewController(repository Repository) *Controller {
 	return &Controller{
-		repository: repository,
+		repository:   repository,
+		admins:       make(map[string]bool),
+		jwtExpiry:    24 * time.Hour,
+		loginLimiter: newLoginLimiter(),
+	}
+}
+
+// SetAdmins sets the users that get the admin role, given as "origin:subject"
+func (c *Controller) SetAdmins(originSubjects []string) {
+	c.admins = make(map[string]bool, len(originSubjects))
+	for _, originSubject := range originSubjects {
+		c.admins[originSubject] = true
+	}
+}
+
+// SetJWT enables local accounts with JWTs signed by the secret key that are valid for expiry
+func (c *Controller) SetJWT(jwtSecretKey string, expiry time.Duration) {
+	c.jwtSecretKey = jwtSecretKey
+	c.jwtExpiry = expiry
+}
+
+// SetRegistration allows or forbids registering new local accounts
+func (c *Controller) SetRegistration(allowed bool) {
+	c.registration = allowed
+}
+
+// SetOIDC enables the login with the OpenID Connect provider. The callback redirects to the frontend URL.
+// It needs the JWT secret key from SetJWT.
+func (c *Controller) SetOIDC(provider *oidc.Provider, frontendURL string) {
+	c.oidc = provider
+	c.oidcFrontendURL = strings.SplitN(frontendURL, "#", 2)[0]
+}
+
+// role returns the role claim for the user
+func (c *Controller) role(user User) string {
+	if c.admins[user.AuthOrigin+":"+user.AuthSubject] {
+		return helpers.RoleAdmin
 	}
+	return helpers.RoleUser
 }
@@ -1,6 +1,20 @@
 package controller
 
+import "github.com/gofrs/uuid"
+
 // Repository stores everything for the frontend API
 type Repository interface {
 	GetOrCreateUser(authOrigin, authSubject string) (ret User, err error)
+
+	// local accounts:
+	// CreateLocalUser creates a user with the LocalAuthOrigin, it fails if the username is taken
+	CreateLocalUser(username string, passwordHash string) (ret User, err error)
+	// LocalUser returns the user with the LocalAuthOrigin and its password hash
+	LocalUser(username string) (ret User, passwordHash string, err error)
+	ChangePasswordHash(userID uuid.UUID, passwordHash string) error
+
+	// personal API tokens:
+	APITokens(userID uuid.UUID) ([]APIToken, error)
+	AddAPIToken(userID uuid.UUID, name string, tokenHash string) (APIToken, error)
+	DeleteAPIToken(userID uuid.UUID, tokenID uuid.UUID) error
 }
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// loginLimiterWindow is the time in which the attempts are counted
+	loginLimiterWindow = 15 * time.Minute
+	// loginUserMaxFailures is the number of wrong passwords per username in a window
+	loginUserMaxFailures = 10
+	// loginIPMaxAttempts is the number of wrong passwords and registrations per client IP in a window
+	loginIPMaxAttempts = 30
+	// loginLimiterPruneSize is the map size from which expired entries are removed
+	loginLimiterPruneSize = 1024
+)
+
+// loginAttempts counts the attempts since the start of a window
+type loginAttempts struct {
+	count       int
+	windowStart time.Time
+}
+
+// loginLimiter limits the password guesses per username and the password hashes per client IP
+type loginLimiter struct {
+	mutex    sync.Mutex
+	username map[string]*loginAttempts
+	ip       map[string]*loginAttempts
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{
+		username: make(map[string]*loginAttempts),
+		ip:       make(map[string]*loginAttempts),
+	}
+}
+
+// Wait returns how long the client has to wait until it may try again, 0 if it may try now.
+// An empty username or IP isn't checked.
+func (l *loginLimiter) Wait(username string, ip string, now time.Time) (retryAfter time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if attempts, ok := l.username[strings.ToLower(username)]; ok && attempts.count >= loginUserMaxFailures {
+		retryAfter = attempts.windowStart.Add(loginLimiterWindow).Sub(now)
+	}
+	if attempts, ok := l.ip[ip]; ok && attempts.count >= loginIPMaxAttempts {
+		if wait := attempts.windowStart.Add(loginLimiterWindow).Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+	return
+}
+
+// Record counts a failed attempt for the username and IP, an empty username or IP isn't counted
+func (l *loginLimiter) Record(username string, ip string, now time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if username != "" {
+		countLoginAttempt(l.username, strings.ToLower(username), now)
+	}
+	if ip != "" {
+		countLoginAttempt(l.ip, ip, now)
+	}
+}
+
+// Reset forgets the failed attempts for the username after a successful login
+func (l *loginLimiter) Reset(username string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.username, strings.ToLower(username))
+}
+
+// countLoginAttempt counts the attempt in the current window of the key and removes expired windows
+// once the map has grown big enough
+func countLoginAttempt(attemptsByKey map[string]*loginAttempts, key string, now time.Time) {
+	expiry := now.Add(-loginLimiterWindow)
+	if len(attemptsByKey) >= loginLimiterPruneSize {
+		for k, attempts := range attemptsByKey {
+			if !attempts.windowStart.After(expiry) {
+				delete(attemptsByKey, k)
+			}
+		}
+	}
+
+	attempts, ok := attemptsByKey[key]
+	if !ok || !attempts.windowStart.After(expiry) {
+		attempts = &loginAttempts{windowStart: now}
+		attemptsByKey[key] = attempts
+	}
+	attempts.count++
+}
+
+// tooManyAttempts returns a 429 error if the client has to wait
+func (c *Controller) tooManyAttempts(ctx *fiber.Ctx, username string) error {
+	retryAfter := c.loginLimiter.Wait(username, ctx.IP(), time.Now())
+	if retryAfter <= 0 {
+		return nil
+	}
+	ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	return fiber.NewError(fiber.StatusTooManyRequests, "too many attempts, try again later")
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginLimiter_Wait(t *testing.T) {
+	l := newLoginLimiter()
+	now := time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < loginUserMaxFailures; i++ {
+		assert.Zero(t, l.Wait("bob", "192.0.2.1", now))
+		l.Record("bob", "192.0.2.1", now.Add(time.Duration(i)*time.Second))
+	}
+	assert.Equal(t, loginLimiterWindow, l.Wait("bob", "192.0.2.2", now), "username limit")
+	assert.Equal(t, loginLimiterWindow, l.Wait("Bob", "192.0.2.2", now), "usernames aren't case sensitive")
+	assert.Zero(t, l.Wait("alice", "192.0.2.1", now))
+	assert.Zero(t, l.Wait("bob", "192.0.2.1", now.Add(loginLimiterWindow)), "the window expired")
+
+	// registrations only count for the IP
+	for i := 0; i < loginIPMaxAttempts-loginUserMaxFailures; i++ {
+		l.Record("", "192.0.2.1", now)
+	}
+	assert.Equal(t, loginLimiterWindow, l.Wait("alice", "192.0.2.1", now), "IP limit")
+	assert.Zero(t, l.Wait("alice", "192.0.2.2", now))
+
+	// a successful login resets the username but not the IP
+	l.Reset("bob")
+	assert.Zero(t, l.Wait("bob", "192.0.2.2", now))
+	assert.Equal(t, loginLimiterWindow, l.Wait("bob", "192.0.2.1", now))
+}
+
+func TestController_LoginLimit(t *testing.T) {
+	repo := newLocalTestRepository()
+	c := NewController(repo)
+	c.SetJWT(testJWTSecretKey, time.Hour)
+	c.SetRegistration(true)
+	app := newLocalTestApp(t, c)
+
+	status, _ := postJSON(t, app, "/register", "", `{"username": "bob", "password": "secret123"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	for i := 0; i < loginUserMaxFailures; i++ {
+		status, _ = postJSON(t, app, "/login", "", `{"username": "bob", "password": "wrong password"}`)
+		assert.Equal(t, fiber.StatusForbidden, status)
+	}
+	// the right password doesn't help anymore
+	status, _ = postJSON(t, app, "/login", "", `{"username": "bob", "password": "secret123"}`)
+	assert.Equal(t, fiber.StatusTooManyRequests, status)
+}
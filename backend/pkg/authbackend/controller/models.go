@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"time"
+
 	"github.com/gofrs/uuid"
 )
 
@@ -11,3 +13,10 @@ type User struct {
 	AuthOrigin  string `json:"auth_origin"`
 	AuthSubject string `json:"auth_subject"`
 }
+
+// APIToken is a personal API token without the secret token itself
+type APIToken struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
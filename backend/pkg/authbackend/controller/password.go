@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id parameters for new password hashes, see RFC 9106 section 4.
+// Existing hashes keep the parameters they were created with.
+const (
+	argon2Time    = 1
+	argon2Memory  = 64 * 1024 // KiB
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+	// argon2MaxConcurrent limits the hashes computed at the same time, each one needs argon2Memory
+	argon2MaxConcurrent = 4
+)
+
+// argon2Semaphore has a slot for each hash that's computed at the moment
+var argon2Semaphore = make(chan struct{}, argon2MaxConcurrent)
+
+// dummyPasswordHash is checked for unknown users, so that they take as long as wrong passwords
+var dummyPasswordHash, _ = hashPassword("not a password")
+
+// hashPassword returns the argon2id hash of the password in PHC string format
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// checkPassword returns true if the password matches the hash
+func checkPassword(password string, passwordHash string) (bool, error) {
+	parts := strings.Split(passwordHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, errors.New("unsupported password hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, errors.New("unsupported argon2 version")
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, errors.New("malformed argon2 parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, errors.New("malformed salt")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, errors.New("malformed key")
+	}
+
+	otherKey := argon2IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+// argon2IDKey is argon2.IDKey but waits while argon2MaxConcurrent other hashes are computed, so that parallel
+// requests can't use up all memory
+func argon2IDKey(password, salt []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+	argon2Semaphore <- struct{}{}
+	defer func() { <-argon2Semaphore }()
+
+	return argon2.IDKey(password, salt, time, memory, threads, keyLen)
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_hashPassword(t *testing.T) {
+	hash, err := hashPassword("correct horse battery staple")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=1,p=4$"))
+
+	ok, err := checkPassword("correct horse battery staple", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = checkPassword("Correct horse battery staple", hash)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// salted
+	otherHash, err := hashPassword("correct horse battery staple")
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, otherHash)
+}
+
+func Test_checkPassword(t *testing.T) {
+	// hashes with other parameters still work
+	hash := "$argon2id$v=19$m=1024,t=2,p=1$c29tZXNhbHRzb21lc2FsdA$CKGe5/bX9YnCq2rxjW5yQXKxn31v1GKzhDCrMc6r6vA"
+	ok, err := checkPassword("password", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		"$argon2i$v=19$m=1024,t=2,p=1$c29tZXNhbHRzb21lc2FsdA$CKGe5/bX9YnCq2rxjW5yQXKxn31v1GKzhDCrMc6r6vA",
+		"$argon2id$v=16$m=1024,t=2,p=1$c29tZXNhbHRzb21lc2FsdA$CKGe5/bX9YnCq2rxjW5yQXKxn31v1GKzhDCrMc6r6vA",
+		"$argon2id$v=19$m=1024$c29tZXNhbHRzb21lc2FsdA$CKGe5/bX9YnCq2rxjW5yQXKxn31v1GKzhDCrMc6r6vA",
+		"$argon2id$v=19$m=1024,t=2,p=1$!!!$CKGe5/bX9YnCq2rxjW5yQXKxn31v1GKzhDCrMc6r6vA",
+	} {
+		ok, err := checkPassword("password", hash)
+		assert.Error(t, err, hash)
+		assert.False(t, ok, hash)
+	}
+}
+
+func Test_argon2IDKey(t *testing.T) {
+	// all slots are taken
+	for i := 0; i < argon2MaxConcurrent; i++ {
+		argon2Semaphore <- struct{}{}
+	}
+
+	done := make(chan []byte)
+	go func() {
+		done <- argon2IDKey([]byte("password"), []byte("somesalt"), 1, 8, 1, argon2KeyLen)
+	}()
+	select {
+	case <-done:
+		t.Fatal("hash was computed without a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-argon2Semaphore
+	select {
+	case key := <-done:
+		assert.Len(t, key, argon2KeyLen)
+	case <-time.After(5 * time.Second):
+		t.Fatal("hash wasn't computed after a slot was freed")
+	}
+
+	for i := 1; i < argon2MaxConcurrent; i++ {
+		<-argon2Semaphore
+	}
+}
@@ -36,6 +36,7 @@ func (c *Controller) Claims(ctx *fiber.Ctx) error {
 		Subject: user.AuthSubject,
 		Origin:  user.AuthOrigin,
 		UserID:  user.ID.String(),
+		Role:    c.role(user),
 	}
 	return ctx.JSON(resp)
 }
@@ -49,4 +50,6 @@ type ClaimsResponse struct {
 
 	// extra fields
 	UserID string `json:"uid"`
+	// helpers.RoleUser or helpers.RoleAdmin
+	Role string `json:"role"`
 }
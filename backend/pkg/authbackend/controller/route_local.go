@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/httputil"
+)
+
+// LocalAuthOrigin is the auth origin of accounts with a password stored by the authbackend
+const LocalAuthOrigin = "local"
+
+// password length limits in bytes, argon2 doesn't need an upper limit but we don't want to hash megabytes
+const (
+	passwordMinLength = 8
+	passwordMaxLength = 1024
+)
+
+// usernameRegexp limits usernames to something that's safe to show everywhere
+var usernameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._@-]{2,63}$`)
+
+// ErrUsernameTaken is returned by the repository if a local account with the username exists
+var ErrUsernameTaken = errors.New("username taken")
+
+// Register godoc
+// @Summary Register a local account and log in
+// @Tags local
+// @Accept json
+// @Produce plain
+// @Param request body LoginRequest true "Username and Password"
+// @Success 200 {string} string "JWT"
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 409 {object} httputil.HTTPError
+// @Failure 429 {object} httputil.HTTPError
+// @Failure 500 {object} httputil.HTTPError
+// @Router /register [post]
+func (c *Controller) Register(ctx *fiber.Ctx) error {
+	if !c.registration {
+		return fiber.NewError(fiber.StatusForbidden, "registration is disabled")
+	}
+
+	var json LoginRequest
+	if err := ctx.BodyParser(&json); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "malformed JSON body")
+	}
+	if !usernameRegexp.MatchString(json.Username) {
+		return fiber.NewError(fiber.StatusBadRequest, "username must have 3 to 64 letters, digits or ._@-")
+	}
+	if err := checkPasswordLength(json.Password); err != nil {
+		return err
+	}
+
+	// every registration computes a hash, so they count like failed logins for the client IP
+	if err := c.tooManyAttempts(ctx, ""); err != nil {
+		return err
+	}
+	c.loginLimiter.Record("", ctx.IP(), time.Now())
+
+	passwordHash, err := hashPassword(json.Password)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "hashing password failed")
+	}
+	user, err := c.repository.CreateLocalUser(json.Username, passwordHash)
+	if errors.Is(err, ErrUsernameTaken) {
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	} else if err != nil {
+		log.WithError(err).Error("failed creating local user")
+		return fiber.NewError(fiber.StatusInternalServerError, "creating user failed")
+	}
+
+	return c.sendJWT(ctx, user)
+}
+
+// Login godoc
+// @Summary Log in with a local account
+// @Description Works like loginsrv's login endpoint, the response body is the JWT.
+// @Tags local
+// @Accept json
+// @Produce plain
+// @Param request body LoginRequest true "Username and Password"
+// @Success 200 {string} string "JWT"
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 429 {object} httputil.HTTPError
+// @Failure 500 {object} httputil.HTTPError
+// @Router /login [post]
+func (c *Controller) Login(ctx *fiber.Ctx) error {
+	var json LoginRequest
+	if err := ctx.BodyParser(&json); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "malformed JSON body")
+	}
+	if len(json.Password) > passwordMaxLength {
+		return fiber.NewError(fiber.StatusForbidden, "wrong username or password")
+	}
+	if err := c.tooManyAttempts(ctx, json.Username); err != nil {
+		return err
+	}
+
+	user, passwordHash, err := c.repository.LocalUser(json.Username)
+	if err != nil {
+		// take as long as a wrong password
+		passwordHash = dummyPasswordHash
+	}
+	ok, checkErr := checkPassword(json.Password, passwordHash)
+	if checkErr != nil {
+		log.WithError(checkErr).WithField("user", json.Username).Error("can't check password")
+	}
+	if err != nil || !ok {
+		c.loginLimiter.Record(json.Username, ctx.IP(), time.Now())
+		return fiber.NewError(fiber.StatusForbidden, "wrong username or password")
+	}
+	c.loginLimiter.Reset(json.Username)
+
+	return c.sendJWT(ctx, user)
+}
+
+// ChangePassword godoc
+// @Summary Change the password of the local account
+// @Tags local
+// @Accept json
+// @Produce json
+// @Param request body ChangePasswordRequest true "Old and new Password"
+// @Success 200 {object} httputil.HTTPStatus
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 403 {object} httputil.HTTPError
+// @Failure 429 {object} httputil.HTTPError
+// @Failure 500 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /password [post]
+func (c *Controller) ChangePassword(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	if claims == nil || claims.Origin != LocalAuthOrigin {
+		return fiber.NewError(fiber.StatusBadRequest, "not a local account")
+	}
+
+	var json ChangePasswordRequest
+	if err := ctx.BodyParser(&json); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "malformed JSON body")
+	}
+	if err := checkPasswordLength(json.NewPassword); err != nil {
+		return err
+	}
+
+	if err := c.tooManyAttempts(ctx, claims.Name); err != nil {
+		return err
+	}
+
+	user, passwordHash, err := c.repository.LocalUser(claims.Name)
+	if err != nil || user.ID != claims.ID {
+		return fiber.NewError(fiber.StatusBadRequest, "not a local account")
+	}
+	if ok, _ := checkPassword(json.OldPassword, passwordHash); !ok {
+		c.loginLimiter.Record(claims.Name, ctx.IP(), time.Now())
+		return fiber.NewError(fiber.StatusForbidden, "wrong password")
+	}
+
+	newPasswordHash, err := hashPassword(json.NewPassword)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "hashing password failed")
+	}
+	if err = c.repository.ChangePasswordHash(user.ID, newPasswordHash); err != nil {
+		log.WithError(err).Error("failed changing password")
+		return fiber.NewError(fiber.StatusInternalServerError, "changing password failed")
+	}
+
+	return ctx.JSON(httputil.HTTPStatus{
+		Status: "ok",
+	})
+}
+
+// LoginRequest contains the credentials of a local account
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// ChangePasswordRequest contains the current and the new password
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+func checkPasswordLength(password string) error {
+	if len(password) < passwordMinLength {
+		return fiber.NewError(fiber.StatusBadRequest, "password too short")
+	}
+	if len(password) > passwordMaxLength {
+		return fiber.NewError(fiber.StatusBadRequest, "password too long")
+	}
+	return nil
+}
+
+// sendJWT responds with a new JWT for the user
+func (c *Controller) sendJWT(ctx *fiber.Ctx, user User) error {
+	token, err := c.issueJWT(user, time.Now())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "issuing JWT failed")
+	}
+
+	ctx.Set(fiber.HeaderContentType, "application/jwt")
+	return ctx.SendString(token)
+}
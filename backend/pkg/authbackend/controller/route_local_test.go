@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+)
+
+const testJWTSecretKey = "a test secret that is long enough"
+
+type localTestRepository struct {
+	Repository
+
+	users          map[string]User
+	passwordHashes map[string]string
+	tokens         map[uuid.UUID]string
+}
+
+func newLocalTestRepository() *localTestRepository {
+	return &localTestRepository{
+		users:          make(map[string]User),
+		passwordHashes: make(map[string]string),
+		tokens:         make(map[uuid.UUID]string),
+	}
+}
+
+func (r *localTestRepository) CreateLocalUser(username string, passwordHash string) (User, error) {
+	if _, ok := r.users[username]; ok {
+		return User{}, ErrUsernameTaken
+	}
+	r.users[username] = User{
+		ID:          uuid.Must(uuid.NewV4()),
+		AuthOrigin:  LocalAuthOrigin,
+		AuthSubject: username,
+	}
+	r.passwordHashes[username] = passwordHash
+	return r.users[username], nil
+}
+
+func (r *localTestRepository) LocalUser(username string) (User, string, error) {
+	user, ok := r.users[username]
+	if !ok {
+		return User{}, "", errors.New("user doesn't exist")
+	}
+	return user, r.passwordHashes[username], nil
+}
+
+func (r *localTestRepository) ChangePasswordHash(userID uuid.UUID, passwordHash string) error {
+	for username, user := range r.users {
+		if user.ID == userID {
+			r.passwordHashes[username] = passwordHash
+			return nil
+		}
+	}
+	return errors.New("user has no password")
+}
+
+func (r *localTestRepository) APITokens(userID uuid.UUID) ([]APIToken, error) {
+	return []APIToken{}, nil
+}
+
+func (r *localTestRepository) AddAPIToken(userID uuid.UUID, name string, tokenHash string) (APIToken, error) {
+	token := APIToken{ID: uuid.Must(uuid.NewV4()), Name: name}
+	r.tokens[token.ID] = tokenHash
+	return token, nil
+}
+
+// newLocalTestApp returns an app with the local account routes like the authbackend server
+func newLocalTestApp(t *testing.T, c *Controller) *fiber.App {
+	authMiddleware, err := fibertools.NewFiberAuthMiddleware(testJWTSecretKey, nil)
+	assert.NoError(t, err)
+
+	app := fiber.New()
+	app.Post("/register", c.Register)
+	app.Post("/login", c.Login)
+	app.Post("/password", authMiddleware, c.ChangePassword)
+	app.Post("/token", authMiddleware, c.AddAPIToken)
+	return app
+}
+
+func postJSON(t *testing.T, app *fiber.App, url string, jwtToken string, body string) (int, string) {
+	req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if jwtToken != "" {
+		req.Header.Set("Authorization", "Bearer "+jwtToken)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	respBody, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, string(respBody)
+}
+
+func TestController_LocalAccount(t *testing.T) {
+	repo := newLocalTestRepository()
+	c := NewController(repo)
+	c.SetJWT(testJWTSecretKey, time.Hour)
+	c.SetAdmins([]string{"local:admin"})
+	app := newLocalTestApp(t, c)
+
+	// registration is disabled by default
+	status, _ := postJSON(t, app, "/register", "", `{"username": "bob", "password": "secret123"}`)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Empty(t, repo.users)
+
+	c.SetRegistration(true)
+	status, _ = postJSON(t, app, "/register", "", `{"username": "b", "password": "secret123"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = postJSON(t, app, "/register", "", `{"username": "bob", "password": "short"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	// registering logs in
+	status, jwtToken := postJSON(t, app, "/register", "", `{"username": "bob", "password": "secret123"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.NotEqual(t, repo.passwordHashes["bob"], "secret123")
+	status, _ = postJSON(t, app, "/register", "", `{"username": "bob", "password": "other secret"}`)
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	// the JWT has the claims of loginsrv's tokens
+	token, err := jwt.Parse(jwtToken, func(token *jwt.Token) (interface{}, error) {
+		return []byte(testJWTSecretKey), nil
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, jwt.SigningMethodHS512, token.Method)
+		claims := token.Claims.(jwt.MapClaims)
+		assert.Equal(t, "bob", claims["sub"])
+		assert.Equal(t, LocalAuthOrigin, claims["origin"])
+		assert.Equal(t, repo.users["bob"].ID.String(), claims["uid"])
+		assert.Equal(t, helpers.RoleUser, claims["role"])
+	}
+
+	// login
+	status, _ = postJSON(t, app, "/login", "", `{"username": "bob", "password": "wrong password"}`)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, _ = postJSON(t, app, "/login", "", `{"username": "alice", "password": "secret123"}`)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, jwtToken = postJSON(t, app, "/login", "", `{"username": "bob", "password": "secret123"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// change password
+	status, _ = postJSON(t, app, "/password", "", `{"old_password": "secret123", "new_password": "secret456"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = postJSON(t, app, "/password", jwtToken, `{"old_password": "wrong password", "new_password": "secret456"}`)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, _ = postJSON(t, app, "/password", jwtToken, `{"old_password": "secret123", "new_password": "secret456"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = postJSON(t, app, "/login", "", `{"username": "bob", "password": "secret123"}`)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, _ = postJSON(t, app, "/login", "", `{"username": "bob", "password": "secret456"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// admins get their role
+	status, adminToken := postJSON(t, app, "/register", "", `{"username": "admin", "password": "secret123"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	token, err = jwt.Parse(adminToken, func(token *jwt.Token) (interface{}, error) {
+		return []byte(testJWTSecretKey), nil
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, helpers.RoleAdmin, token.Claims.(jwt.MapClaims)["role"])
+	}
+}
+
+func TestController_AddAPIToken(t *testing.T) {
+	repo := newLocalTestRepository()
+	c := NewController(repo)
+	c.SetJWT(testJWTSecretKey, time.Hour)
+	c.SetRegistration(true)
+	app := newLocalTestApp(t, c)
+
+	_, jwtToken := postJSON(t, app, "/register", "", `{"username": "bob", "password": "secret123"}`)
+
+	status, _ := postJSON(t, app, "/token", jwtToken, `{"name": "  "}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	status, body := postJSON(t, app, "/token", jwtToken, `{"name": "my script"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Contains(t, body, `"name":"my script"`)
+	assert.Contains(t, body, `"token":"`+helpers.APITokenPrefix)
+
+	// only the hash is stored
+	if assert.Len(t, repo.tokens, 1) {
+		for _, tokenHash := range repo.tokens {
+			assert.Len(t, tokenHash, 64)
+			assert.NotContains(t, body, tokenHash)
+		}
+	}
+}
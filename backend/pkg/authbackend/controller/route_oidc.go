@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/spezifisch/rueder3/backend/pkg/authbackend/oidc"
+)
+
+const (
+	// oidcCookieName is the cookie that keeps the authorization request until the callback
+	oidcCookieName = "rueder_oidc"
+	// oidcLoginTimeout is how long the user has to log in at the provider
+	oidcLoginTimeout = 10 * time.Minute
+)
+
+// oidcStateClaims are stored in a signed cookie, so that the authbackend doesn't need to keep state
+type oidcStateClaims struct {
+	jwt.RegisteredClaims
+	oidc.AuthRequest
+}
+
+// OIDCLogin godoc
+// @Summary Log in with the OpenID Connect provider
+// @Description Redirects to the provider which redirects back to the callback.
+// @Tags oidc
+// @Success 302
+// @Failure 404 {object} httputil.HTTPError
+// @Failure 502 {object} httputil.HTTPError
+// @Router /oidc/login [get]
+func (c *Controller) OIDCLogin(ctx *fiber.Ctx) error {
+	if c.oidc == nil {
+		return fiber.NewError(fiber.StatusNotFound, "OIDC login is not configured")
+	}
+
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "creating request failed")
+	}
+	authURL, err := c.oidc.AuthCodeURL(ctx.UserContext(), req)
+	if err != nil {
+		log.WithError(err).Error("OIDC provider unavailable")
+		return fiber.NewError(fiber.StatusBadGateway, "OIDC provider unavailable")
+	}
+
+	now := time.Now()
+	state, err := jwt.NewWithClaims(jwt.SigningMethodHS512, oidcStateClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(oidcLoginTimeout)),
+		},
+		AuthRequest: req,
+	}).SignedString(c.oidcStateKey())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "creating request failed")
+	}
+	c.setOIDCCookie(ctx, state, now.Add(oidcLoginTimeout))
+
+	return ctx.Redirect(authURL, fiber.StatusFound)
+}
+
+// OIDCCallback godoc
+// @Summary Callback for the OpenID Connect provider
+// @Description Redirects to the frontend with the JWT or an error in the URL fragment (#jwt=... or #error=...).
+// @Tags oidc
+// @Param code query string false "Authorization code"
+// @Param state query string false "State of the authorization request"
+// @Param error query string false "Error returned by the provider"
+// @Success 302
+// @Failure 404 {object} httputil.HTTPError
+// @Router /oidc/callback [get]
+func (c *Controller) OIDCCallback(ctx *fiber.Ctx) error {
+	if c.oidc == nil {
+		return fiber.NewError(fiber.StatusNotFound, "OIDC login is not configured")
+	}
+
+	// the request can only be used once
+	state := ctx.Cookies(oidcCookieName)
+	c.setOIDCCookie(ctx, "", time.Unix(0, 0))
+
+	if providerError := ctx.Query("error"); providerError != "" {
+		log.WithField("error", providerError).WithField("description", ctx.Query("error_description")).
+			Info("OIDC login failed at provider")
+		return c.redirectToFrontend(ctx, "error", "login failed: "+providerError)
+	}
+
+	req, err := c.parseOIDCState(state)
+	if err != nil {
+		return c.redirectToFrontend(ctx, "error", "login expired, please try again")
+	}
+	if subtle.ConstantTimeCompare([]byte(req.State), []byte(ctx.Query("state"))) != 1 {
+		return c.redirectToFrontend(ctx, "error", "login failed: wrong state")
+	}
+
+	claims, err := c.oidc.Exchange(ctx.UserContext(), ctx.Query("code"), req)
+	if err != nil {
+		log.WithError(err).Error("OIDC login failed")
+		return c.redirectToFrontend(ctx, "error", "login failed")
+	}
+
+	// the issuer is the origin like github or google is for loginsrv
+	user, err := c.repository.GetOrCreateUser(c.oidc.Issuer(), claims.Subject)
+	if err != nil {
+		log.WithError(err).Error("failed getting OIDC user")
+		return c.redirectToFrontend(ctx, "error", "login failed")
+	}
+	token, err := c.issueJWT(user, time.Now())
+	if err != nil {
+		return c.redirectToFrontend(ctx, "error", "login failed")
+	}
+
+	return c.redirectToFrontend(ctx, "jwt", token)
+}
+
+// oidcStateKey is derived from the JWT secret key so that state cookies and JWTs can't be mixed up
+func (c *Controller) oidcStateKey() []byte {
+	mac := hmac.New(sha256.New, []byte(c.jwtSecretKey))
+	mac.Write([]byte("rueder oidc state"))
+	return mac.Sum(nil)
+}
+
+func (c *Controller) parseOIDCState(state string) (req oidc.AuthRequest, err error) {
+	if state == "" {
+		err = errors.New("no state cookie")
+		return
+	}
+
+	claims := &oidcStateClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}))
+	_, err = parser.ParseWithClaims(state, claims, func(token *jwt.Token) (interface{}, error) {
+		return c.oidcStateKey(), nil
+	})
+	if err != nil {
+		return
+	}
+	req = claims.AuthRequest
+	return
+}
+
+// setOIDCCookie sets the state cookie for the callback route
+func (c *Controller) setOIDCCookie(ctx *fiber.Ctx, value string, expires time.Time) {
+	// cookies are only sent back by the browser if they match the callback URL
+	callbackURL, _ := url.Parse(c.oidc.RedirectURL())
+	ctx.Cookie(&fiber.Cookie{
+		Name:     oidcCookieName,
+		Value:    value,
+		Path:     callbackURL.Path,
+		Expires:  expires,
+		Secure:   callbackURL.Scheme == "https",
+		HTTPOnly: true,
+		// the callback is a top-level navigation from the provider
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// redirectToFrontend sends the result in the URL fragment, so that it isn't sent to any server
+func (c *Controller) redirectToFrontend(ctx *fiber.Ctx, key, value string) error {
+	fragment := url.Values{}
+	fragment.Set(key, value)
+	return ctx.Redirect(c.oidcFrontendURL+"#"+fragment.Encode(), fiber.StatusFound)
+}
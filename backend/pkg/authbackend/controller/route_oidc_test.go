@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spezifisch/rueder3/backend/pkg/authbackend/oidc"
+	"github.com/spezifisch/rueder3/backend/pkg/authbackend/oidc/mock"
+)
+
+const (
+	testOIDCRedirectURL = "https://rueder.invalid/auth/oidc/callback"
+	testFrontendURL     = "https://rueder.invalid/"
+)
+
+type oidcTestRepository struct {
+	Repository
+
+	users map[string]User
+}
+
+func (r *oidcTestRepository) GetOrCreateUser(authOrigin, authSubject string) (User, error) {
+	key := authOrigin + ":" + authSubject
+	if _, ok := r.users[key]; !ok {
+		r.users[key] = User{
+			ID:          uuid.Must(uuid.NewV4()),
+			AuthOrigin:  authOrigin,
+			AuthSubject: authSubject,
+		}
+	}
+	return r.users[key], nil
+}
+
+func newOIDCTestApp(t *testing.T, mockProvider *mock.Provider) (*fiber.App, *oidcTestRepository) {
+	provider, err := oidc.NewProvider(mockProvider.URL, mockProvider.ClientID, mockProvider.ClientSecret,
+		testOIDCRedirectURL, nil)
+	assert.NoError(t, err)
+
+	repo := &oidcTestRepository{users: make(map[string]User)}
+	c := NewController(repo)
+	c.SetJWT(testJWTSecretKey, time.Hour)
+	c.SetOIDC(provider, testFrontendURL)
+
+	app := fiber.New()
+	app.Get("/oidc/login", c.OIDCLogin)
+	app.Get("/oidc/callback", c.OIDCCallback)
+	return app, repo
+}
+
+// oidcLogin starts the login and returns the state cookie and the callback URL the provider redirects to
+func oidcLogin(t *testing.T, app *fiber.App) (*http.Cookie, *url.URL) {
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/oidc/login", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusFound, resp.StatusCode)
+
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == oidcCookieName {
+			cookie = c
+		}
+	}
+	if assert.NotNil(t, cookie) {
+		assert.True(t, cookie.HttpOnly)
+		assert.True(t, cookie.Secure)
+		assert.Equal(t, "/auth/oidc/callback", cookie.Path)
+	}
+
+	// the mock provider logs in immediately
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	providerResp, err := client.Get(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+	providerResp.Body.Close()
+	assert.Equal(t, http.StatusFound, providerResp.StatusCode)
+
+	callbackURL, err := url.Parse(providerResp.Header.Get("Location"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(callbackURL.String(), testOIDCRedirectURL+"?"))
+	return cookie, callbackURL
+}
+
+// oidcCallback calls the callback route and returns the URL fragment of the frontend redirect
+func oidcCallback(t *testing.T, app *fiber.App, cookie *http.Cookie, query url.Values) url.Values {
+	req := httptest.NewRequest(http.MethodGet, "/oidc/callback?"+query.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusFound, resp.StatusCode)
+
+	location := resp.Header.Get("Location")
+	assert.True(t, strings.HasPrefix(location, testFrontendURL+"#"), location)
+	fragment, err := url.ParseQuery(strings.SplitN(location, "#", 2)[1])
+	assert.NoError(t, err)
+	return fragment
+}
+
+func TestController_OIDC(t *testing.T) {
+	mockProvider := mock.NewProvider("rueder", "client secret", "f3c2a1b0-alice")
+	defer mockProvider.Close()
+	app, repo := newOIDCTestApp(t, mockProvider)
+
+	cookie, callbackURL := oidcLogin(t, app)
+	fragment := oidcCallback(t, app, cookie, callbackURL.Query())
+	assert.Empty(t, fragment.Get("error"))
+
+	// the JWT has the claims of loginsrv's tokens with the issuer as origin
+	token, err := jwt.Parse(fragment.Get("jwt"), func(token *jwt.Token) (interface{}, error) {
+		return []byte(testJWTSecretKey), nil
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, jwt.SigningMethodHS512, token.Method)
+		claims := token.Claims.(jwt.MapClaims)
+		user := repo.users[mockProvider.URL+":f3c2a1b0-alice"]
+		assert.Equal(t, "f3c2a1b0-alice", claims["sub"])
+		assert.Equal(t, mockProvider.URL, claims["origin"])
+		assert.Equal(t, user.ID.String(), claims["uid"])
+	}
+
+	// the same login can't be used twice
+	fragment = oidcCallback(t, app, cookie, callbackURL.Query())
+	assert.NotEmpty(t, fragment.Get("error"))
+	assert.Empty(t, fragment.Get("jwt"))
+	assert.Len(t, repo.users, 1)
+}
+
+func TestController_OIDCInvalidCallback(t *testing.T) {
+	mockProvider := mock.NewProvider("rueder", "", "alice")
+	defer mockProvider.Close()
+	app, repo := newOIDCTestApp(t, mockProvider)
+
+	// without the state cookie of the browser that started the login
+	_, callbackURL := oidcLogin(t, app)
+	fragment := oidcCallback(t, app, nil, callbackURL.Query())
+	assert.Equal(t, "login expired, please try again", fragment.Get("error"))
+
+	// with the state of another login
+	cookie, _ := oidcLogin(t, app)
+	_, callbackURL = oidcLogin(t, app)
+	fragment = oidcCallback(t, app, cookie, callbackURL.Query())
+	assert.Equal(t, "login failed: wrong state", fragment.Get("error"))
+
+	// with a forged state cookie
+	cookie, callbackURL = oidcLogin(t, app)
+	cookie.Value = cookie.Value[:len(cookie.Value)-2] + "xx"
+	fragment = oidcCallback(t, app, cookie, callbackURL.Query())
+	assert.Equal(t, "login expired, please try again", fragment.Get("error"))
+
+	// the provider reports an error
+	cookie, _ = oidcLogin(t, app)
+	fragment = oidcCallback(t, app, cookie, url.Values{"error": {"access_denied"}})
+	assert.Equal(t, "login failed: access_denied", fragment.Get("error"))
+
+	assert.Empty(t, repo.users)
+}
+
+func TestController_OIDCNotConfigured(t *testing.T) {
+	c := NewController(&oidcTestRepository{})
+	app := fiber.New()
+	app.Get("/oidc/login", c.OIDCLogin)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/oidc/login", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
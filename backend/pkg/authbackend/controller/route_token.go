@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/apex/log"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+	"github.com/spezifisch/rueder3/backend/pkg/httputil"
+)
+
+// limits for personal API tokens
+const (
+	apiTokenNameMaxLength = 256
+	apiTokenCountLimit    = 50
+)
+
+// APITokens godoc
+// @Summary List the personal API tokens of the user
+// @Tags token
+// @Produce json
+// @Success 200 {object} []APIToken
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 500 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /tokens [get]
+func (c *Controller) APITokens(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	if claims == nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid claims")
+	}
+
+	tokens, err := c.repository.APITokens(claims.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed fetching tokens")
+	}
+	return ctx.JSON(tokens)
+}
+
+// AddAPIToken godoc
+// @Summary Create a personal API token for scripts and other clients
+// @Description The token is only returned once. It's used like a JWT in the Authorization header.
+// @Tags token
+// @Accept json
+// @Produce json
+// @Param request body APITokenRequest true "Token name"
+// @Success 200 {object} APITokenResponse
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 500 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /token [post]
+func (c *Controller) AddAPIToken(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	if claims == nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid claims")
+	}
+
+	var json APITokenRequest
+	if err := ctx.BodyParser(&json); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "malformed JSON body")
+	}
+	name := strings.TrimSpace(json.Name)
+	if name == "" || utf8.RuneCountInString(name) > apiTokenNameMaxLength {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid token name")
+	}
+
+	tokens, err := c.repository.APITokens(claims.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed fetching tokens")
+	}
+	if len(tokens) >= apiTokenCountLimit {
+		return fiber.NewError(fiber.StatusBadRequest, "too many tokens")
+	}
+
+	token, err := newAPIToken()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "creating token failed")
+	}
+	apiToken, err := c.repository.AddAPIToken(claims.ID, name, helpers.HashAPIToken(token))
+	if err != nil {
+		log.WithError(err).Error("failed adding api token")
+		return fiber.NewError(fiber.StatusInternalServerError, "creating token failed")
+	}
+
+	return ctx.JSON(APITokenResponse{
+		APIToken: apiToken,
+		Token:    token,
+	})
+}
+
+// DeleteAPIToken godoc
+// @Summary Revoke a personal API token
+// @Tags token
+// @Produce json
+// @Param token_id path string true "Token ID"
+// @Success 200 {object} httputil.HTTPStatus
+// @Failure 400 {object} httputil.HTTPError
+// @Failure 401 {object} httputil.HTTPError
+// @Failure 404 {object} httputil.HTTPError
+// @Security ApiKeyAuth
+// @Router /token/{token_id} [delete]
+func (c *Controller) DeleteAPIToken(ctx *fiber.Ctx) error {
+	claims := fibertools.GetFiberAuthClaims(ctx)
+	if claims == nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid claims")
+	}
+	tokenID, err := uuid.FromString(ctx.Params("token_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid token_id")
+	}
+
+	if err = c.repository.DeleteAPIToken(claims.ID, tokenID); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+
+	return ctx.JSON(httputil.HTTPStatus{
+		Status: "ok",
+	})
+}
+
+// APITokenRequest names a new personal API token
+type APITokenRequest struct {
+	Name string `json:"name"`
+}
+
+// APITokenResponse contains the new personal API token
+type APITokenResponse struct {
+	APIToken
+
+	Token string `json:"token"`
+}
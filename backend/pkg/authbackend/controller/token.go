@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+)
+
+// apiTokenLen is the number of random bytes in a personal API token
+const apiTokenLen = 32
+
+// issueJWT returns a JWT for the user with the same claims that loginsrv puts in its tokens
+func (c *Controller) issueJWT(user User, now time.Time) (string, error) {
+	if c.jwtSecretKey == "" {
+		return "", errors.New("no JWT secret key")
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+		"sub":    user.AuthSubject,
+		"origin": user.AuthOrigin,
+		"uid":    user.ID.String(),
+		"role":   c.role(user),
+		"iat":    now.Unix(),
+		"exp":    now.Add(c.jwtExpiry).Unix(),
+	})
+	return token.SignedString([]byte(c.jwtSecretKey))
+}
+
+// newAPIToken returns a random personal API token
+func newAPIToken() (string, error) {
+	buf := make([]byte, apiTokenLen)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return helpers.APITokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
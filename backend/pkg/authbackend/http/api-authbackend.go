@@ -1,8 +1,10 @@
 package http
 
+import "github.com/gofiber/fiber/v2"
+
 // @title rueder3 Auth Backend API
 // @version 1.0
-// @description Auth Backend API is called internally by loginsrv
+// @description Auth Backend API is called internally by loginsrv and provides local accounts and OpenID Connect login
 
 // @contact.name spezifisch
 // @contact.url https://github.com/spezifisch
@@ -14,5 +16,24 @@ package http
 // @BasePath /
 // @query.collection.format multi
 func (s *Server) addRoutesAuthbackend() {
+	// unauthenticated, only loginsrv may reach this app
 	s.app.Get("/claims", s.controller.Claims)
 }
+
+// addRoutesLocalAccounts adds the routes for local accounts that have to be reachable by the frontend
+func (s *Server) addRoutesLocalAccounts(authMiddleware fiber.Handler) {
+	s.publicApp.Post("/register", s.controller.Register)
+	s.publicApp.Post("/login", s.controller.Login)
+
+	// tied to the user, personal API tokens can't be used to manage passwords and tokens
+	s.publicApp.Post("/password", authMiddleware, s.controller.ChangePassword)
+	s.publicApp.Get("/tokens", authMiddleware, s.controller.APITokens)
+	s.publicApp.Post("/token", authMiddleware, s.controller.AddAPIToken)
+	s.publicApp.Delete("/token/:token_id", authMiddleware, s.controller.DeleteAPIToken)
+}
+
+// addRoutesOIDC adds the routes for the OpenID Connect login that are opened by the browser
+func (s *Server) addRoutesOIDC() {
+	s.publicApp.Get("/oidc/login", s.controller.OIDCLogin)
+	s.publicApp.Get("/oidc/callback", s.controller.OIDCCallback)
+}
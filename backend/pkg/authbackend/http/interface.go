@@ -5,4 +5,15 @@ import "github.com/gofiber/fiber/v2"
 // Controller is the URL handler
 type Controller interface {
 	Claims(ctx *fiber.Ctx) error
+
+	Register(ctx *fiber.Ctx) error
+	Login(ctx *fiber.Ctx) error
+	ChangePassword(ctx *fiber.Ctx) error
+
+	APITokens(ctx *fiber.Ctx) error
+	AddAPIToken(ctx *fiber.Ctx) error
+	DeleteAPIToken(ctx *fiber.Ctx) error
+
+	OIDCLogin(ctx *fiber.Ctx) error
+	OIDCCallback(ctx *fiber.Ctx) error
 }
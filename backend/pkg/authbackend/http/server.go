@@ -1,27 +1,42 @@
 package http
 
 import (
+	"context"
+	"time"
+
 	"github.com/apex/log"
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/health"
 )
 
-// Server is a http server
+// Server is a http server. The internal app serves the claims for loginsrv, the public app serves the routes for
+// local accounts and OIDC login that are opened by the browser.
 type Server struct {
-	Bind string
+	Bind       string
+	PublicBind string
 
 	app               *fiber.App
+	publicApp         *fiber.App
 	controller        Controller
+	jwtSecretKey      string
 	isDevelopmentMode bool
+	trustedProxies    []string
+	readinessChecks   health.Checks
 }
 
-// NewServer creates a default http backend
-func NewServer(controller Controller, bind string, isDevelopmentMode bool) *Server {
+// NewServer creates a default http backend. Local accounts and OIDC login are only available with a JWT secret key,
+// they are served on publicBind. The login limits need the client IP, so the proxy in front of them has to be trusted.
+func NewServer(controller Controller, bind string, publicBind string, jwtSecretKey string, isDevelopmentMode bool, trustedProxies []string, readinessChecks health.Checks) *Server {
 	s := &Server{
 		Bind:              bind,
+		PublicBind:        publicBind,
 		controller:        controller,
+		jwtSecretKey:      jwtSecretKey,
 		isDevelopmentMode: isDevelopmentMode,
+		trustedProxies:    trustedProxies,
+		readinessChecks:   readinessChecks,
 	}
 	s.init()
 	return s
@@ -33,18 +48,61 @@ func (s *Server) init() {
 		appName += "-dev"
 	}
 
-	// never trust any proxy because this service should only be used internally by loginsrv
+	// never trust any proxy because this app should only be used internally by loginsrv
 	enableTrustedProxyCheck := true
 	s.app = fibertools.NewFiberRuederApp(appName, s.isDevelopmentMode, enableTrustedProxyCheck, nil)
 
+	// health checks for the orchestrator
+	fibertools.AddFiberHealthRoutes(s.app, s.readinessChecks)
+
 	// add routes
 	s.addRoutesAuthbackend()
+
+	if s.jwtSecretKey == "" {
+		log.Info("no JWT secret key, local accounts and OIDC login are disabled")
+		return
+	}
+	authMiddleware, err := fibertools.NewFiberAuthMiddleware(s.jwtSecretKey, nil)
+	if err != nil {
+		log.WithError(err).Error("couldn't setup jwt auth middleware")
+		return
+	}
+
+	// only trust the configured proxies, the login limits use the client IP
+	s.publicApp = fibertools.NewFiberRuederApp(appName+"-public", s.isDevelopmentMode, enableTrustedProxyCheck, s.trustedProxies)
+	s.addRoutesLocalAccounts(authMiddleware)
+	s.addRoutesOIDC()
 }
 
-// Run starts the server
-func (s *Server) Run() {
+// Run starts the server. When ctx is cancelled it stops accepting connections and returns
+// after the open ones are finished, waiting at most shutdownTimeout for them.
+func (s *Server) Run(ctx context.Context, shutdownTimeout time.Duration) {
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+
+		<-ctx.Done()
+		if s.publicApp != nil {
+			if err := s.publicApp.ShutdownWithTimeout(shutdownTimeout); err != nil {
+				log.WithError(err).Error("public http server shutdown failed")
+			}
+		}
+		if err := s.app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+			log.WithError(err).Error("http server shutdown failed")
+		}
+	}()
+
+	if s.publicApp != nil {
+		go func() {
+			if err := s.publicApp.Listen(s.PublicBind); err != nil {
+				log.WithError(err).Fatal("public http server failed")
+			}
+		}()
+	}
+
 	err := s.app.Listen(s.Bind)
 	if err != nil {
 		log.WithError(err).Fatal("http server failed")
 	}
+	<-shutdownDone
 }
@@ -0,0 +1,238 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// clockSkew is tolerated between us and the provider when checking the ID token times
+	clockSkew = time.Minute
+	// keyRefreshInterval limits how often the keys are fetched when an ID token has an unknown key ID
+	keyRefreshInterval = time.Minute
+)
+
+// signingAlgs are the ID token signing algorithms we accept, see OpenID Connect Core 1.0 section 3.1.3.7
+var signingAlgs = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// IDTokenClaims contains the claims of the ID token that we check,
+// see https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+type IDTokenClaims struct {
+	jwt.RegisteredClaims
+
+	Nonce           string `json:"nonce"`
+	AuthorizedParty string `json:"azp,omitempty"`
+}
+
+// VerifyIDToken checks the signature and claims of the ID token and returns its claims.
+// The nonce must be the one from the authorization request.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken string, nonce string) (*IDTokenClaims, error) {
+	discovery, keys, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	algs := []string{}
+	for _, alg := range discovery.SigningAlgs {
+		if contains(signingAlgs, alg) {
+			algs = append(algs, alg)
+		}
+	}
+	if len(algs) == 0 {
+		// RS256 is mandatory to implement for providers
+		algs = []string{"RS256"}
+	}
+
+	// the times are checked below with some tolerance
+	parser := jwt.NewParser(jwt.WithValidMethods(algs), jwt.WithoutClaimsValidation())
+	claims := &IDTokenClaims{}
+	_, err = parser.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keys.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	if claims.Issuer != discovery.Issuer {
+		return nil, fmt.Errorf("ID token has wrong issuer %q", claims.Issuer)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("ID token has no subject")
+	}
+	if !claims.VerifyAudience(p.clientID, true) {
+		return nil, errors.New("ID token isn't meant for us")
+	}
+	if (len(claims.Audience) > 1 || claims.AuthorizedParty != "") && claims.AuthorizedParty != p.clientID {
+		return nil, errors.New("ID token has wrong authorized party")
+	}
+	now := time.Now()
+	if !claims.VerifyExpiresAt(now.Add(-clockSkew), true) {
+		return nil, errors.New("ID token is expired")
+	}
+	if !claims.VerifyIssuedAt(now.Add(clockSkew), true) || !claims.VerifyNotBefore(now.Add(clockSkew), false) {
+		return nil, errors.New("ID token is not valid yet")
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("ID token has wrong nonce")
+	}
+	return claims, nil
+}
+
+// jwk is a public key in a JWK set, see RFC 7517 and RFC 7518 section 6
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+// keySet caches the signing keys of the provider
+type keySet struct {
+	uri     string
+	getJSON func(ctx context.Context, url string, v interface{}) error
+
+	mutex     sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func newKeySet(uri string, getJSON func(ctx context.Context, url string, v interface{}) error) *keySet {
+	return &keySet{
+		uri:     uri,
+		getJSON: getJSON,
+	}
+}
+
+// key returns the key with the key ID, the keys are fetched again if it's unknown because the provider
+// might have rotated them. Without key ID the only key is used.
+func (s *keySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, ok := s.lookup(kid)
+	if ok {
+		return key, nil
+	}
+	if time.Since(s.fetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+
+	if err := s.fetch(ctx); err != nil {
+		return nil, err
+	}
+	key, ok = s.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	return key, nil
+}
+
+func (s *keySet) lookup(kid string) (key crypto.PublicKey, ok bool) {
+	if kid == "" {
+		if len(s.keys) != 1 {
+			return
+		}
+		for _, key = range s.keys {
+			ok = true
+		}
+		return
+	}
+	key, ok = s.keys[kid]
+	return
+}
+
+func (s *keySet) fetch(ctx context.Context) error {
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := s.getJSON(ctx, s.uri, &set); err != nil {
+		return fmt.Errorf("fetching OIDC keys failed: %w", err)
+	}
+	s.fetchedAt = time.Now()
+
+	s.keys = make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// unsupported keys are skipped, the provider might offer them for other clients
+			continue
+		}
+		s.keys[k.KeyID] = key
+	}
+	return nil
+}
+
+// publicKey decodes the RSA or EC public key
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(buf) == 0 {
+		return nil, errors.New("malformed key parameter")
+	}
+	return new(big.Int).SetBytes(buf), nil
+}
@@ -0,0 +1,205 @@
+// Package mock provides an OpenID provider for tests that logs in every user as the same subject.
+package mock
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/spezifisch/rueder3/backend/pkg/authbackend/oidc"
+)
+
+const keyID = "mock-key"
+
+// authCode is an issued authorization code and what it was issued for
+type authCode struct {
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+// Provider is a running mock OpenID provider, its issuer URL is Provider.URL
+type Provider struct {
+	*httptest.Server
+
+	ClientID     string
+	ClientSecret string
+	// Subject is the sub claim of the issued ID tokens
+	Subject string
+
+	key   *rsa.PrivateKey
+	mutex sync.Mutex
+	codes map[string]authCode
+}
+
+// NewProvider starts a mock provider for the client. Without client secret it's a public client.
+func NewProvider(clientID, clientSecret, subject string) *Provider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Subject:      subject,
+		key:          key,
+		codes:        make(map[string]authCode),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// IDToken returns an ID token signed by the provider with the claims
+func (p *Provider) IDToken(claims jwt.Claims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, oidc.Discovery{
+		Issuer:                p.URL,
+		AuthorizationEndpoint: p.URL + "/authorize",
+		TokenEndpoint:         p.URL + "/token",
+		JWKSURI:               p.URL + "/jwks",
+		SigningAlgs:           []string{"RS256"},
+		CodeChallengeMethods:  []string{"S256"},
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": keyID,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+			},
+		},
+	})
+}
+
+// authorize logs in immediately and redirects back to the client
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != p.ClientID || query.Get("response_type") != "code" ||
+		query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	redirectURL, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirectURL.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+	p.mutex.Lock()
+	p.codes[code] = authCode{
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+	p.mutex.Unlock()
+
+	callback := redirectURL.Query()
+	callback.Set("code", code)
+	callback.Set("state", query.Get("state"))
+	redirectURL.RawQuery = callback.Encode()
+	http.Redirect(w, r, redirectURL.String(), http.StatusFound)
+}
+
+// token redeems an authorization code once
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeTokenError(w, "invalid_request")
+		return
+	}
+
+	// the basic auth credentials are form encoded, see RFC 6749 section 2.3.1
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+	}
+	if clientID != p.ClientID || clientSecret != p.ClientSecret {
+		writeTokenError(w, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeTokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	p.mutex.Lock()
+	code, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mutex.Unlock()
+	if !ok || code.redirectURI != r.PostForm.Get("redirect_uri") ||
+		oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != code.codeChallenge {
+		writeTokenError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token": p.IDToken(oidc.IDTokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    p.URL,
+				Subject:   p.Subject,
+				Audience:  jwt.ClaimStrings{p.ClientID},
+				ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+				IssuedAt:  jwt.NewNumericDate(now),
+			},
+			Nonce: code.nonce,
+		}),
+	})
+}
+
+func writeTokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{
+		"error": code,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
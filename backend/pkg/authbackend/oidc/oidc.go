@@ -0,0 +1,271 @@
+// Package oidc is a minimal OpenID Connect relying party for the authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// httpTimeout limits requests to the provider
+	httpTimeout = 10 * time.Second
+	// maxResponseSize limits responses of the provider
+	maxResponseSize = 1 << 20
+	// randomLen is the number of random bytes in states, nonces and code verifiers
+	randomLen = 32
+)
+
+// DefaultScopes are requested if no scopes are configured
+var DefaultScopes = []string{"openid"}
+
+// Discovery contains the fields of the provider metadata that we use,
+// see https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type Discovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgs           []string `json:"id_token_signing_alg_values_supported"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+// Provider is an OpenID provider that we use to authenticate users
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+
+	client *http.Client
+
+	// discovery is done lazily, so that the authbackend starts without the provider being reachable
+	mutex     sync.Mutex
+	discovery *Discovery
+	keys      *keySet
+}
+
+// NewProvider returns a Provider for the issuer URL. The client secret is optional for public clients.
+// The redirect URL must point to the callback route and be registered at the provider.
+func NewProvider(issuer, clientID, clientSecret, redirectURL string, scopes []string) (*Provider, error) {
+	if issuer == "" || clientID == "" || redirectURL == "" {
+		return nil, errors.New("OIDC issuer, client ID and redirect URL are required")
+	}
+	if _, err := url.ParseRequestURI(redirectURL); err != nil {
+		return nil, fmt.Errorf("invalid OIDC redirect URL: %w", err)
+	}
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	} else if !contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	return &Provider{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		client:       &http.Client{Timeout: httpTimeout},
+	}, nil
+}
+
+// Issuer returns the issuer URL, it's used as the auth origin of the users
+func (p *Provider) Issuer() string {
+	return p.issuer
+}
+
+// RedirectURL returns the URL of the callback route
+func (p *Provider) RedirectURL() string {
+	return p.redirectURL
+}
+
+// Discover fetches the provider metadata if it isn't known yet
+func (p *Provider) Discover(ctx context.Context) (*Discovery, error) {
+	discovery, _, err := p.discover(ctx)
+	return discovery, err
+}
+
+func (p *Provider) discover(ctx context.Context) (*Discovery, *keySet, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, p.keys, nil
+	}
+
+	discovery := &Discovery{}
+	if err := p.getJSON(ctx, p.issuer+"/.well-known/openid-configuration", discovery); err != nil {
+		return nil, nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	// the issuer must match, see OpenID Connect Discovery 1.0 section 4.3
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.issuer {
+		return nil, nil, fmt.Errorf("OIDC discovery returned issuer %q instead of %q", discovery.Issuer, p.issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, nil, errors.New("OIDC discovery is missing endpoints")
+	}
+	if len(discovery.CodeChallengeMethods) > 0 && !contains(discovery.CodeChallengeMethods, "S256") {
+		return nil, nil, errors.New("OIDC provider doesn't support PKCE with S256")
+	}
+
+	p.discovery = discovery
+	p.keys = newKeySet(discovery.JWKSURI, p.getJSON)
+	return p.discovery, p.keys, nil
+}
+
+// AuthRequest contains the secrets of an authorization request that are needed for its callback
+type AuthRequest struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// NewAuthRequest returns a random state, nonce and PKCE code verifier
+func NewAuthRequest() (req AuthRequest, err error) {
+	if req.State, err = randomString(); err != nil {
+		return
+	}
+	if req.Nonce, err = randomString(); err != nil {
+		return
+	}
+	req.CodeVerifier, err = randomString()
+	return
+}
+
+// AuthCodeURL returns the URL of the provider's login page for the request
+func (p *Provider) AuthCodeURL(ctx context.Context, req AuthRequest) (string, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.clientID)
+	query.Set("redirect_uri", p.redirectURL)
+	query.Set("scope", strings.Join(p.scopes, " "))
+	query.Set("state", req.State)
+	query.Set("nonce", req.Nonce)
+	query.Set("code_challenge", CodeChallenge(req.CodeVerifier))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+// tokenResponse is the response of the token endpoint, see RFC 6749 section 5
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeems the authorization code and returns the verified claims of the ID token
+func (p *Provider) Exchange(ctx context.Context, code string, req AuthRequest) (*IDTokenClaims, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	form.Set("code_verifier", req.CodeVerifier)
+	if p.clientSecret == "" {
+		form.Set("client_id", p.clientID)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		// client_secret_basic, see RFC 6749 section 2.3.1
+		httpReq.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	token := tokenResponse{}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("malformed token response with status %d: %w", resp.StatusCode, err)
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("token request failed: %s %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response contains no ID token")
+	}
+
+	return p.VerifyIDToken(ctx, token.IDToken, req.Nonce)
+}
+
+// getJSON fetches a JSON document from the provider
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+// CodeChallenge returns the S256 PKCE code challenge for the code verifier, see RFC 7636 section 4.2
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomString returns a random URL-safe string
+func randomString() (string, error) {
+	buf := make([]byte, randomLen)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
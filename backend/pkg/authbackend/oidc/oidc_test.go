@@ -0,0 +1,174 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spezifisch/rueder3/backend/pkg/authbackend/oidc"
+	"github.com/spezifisch/rueder3/backend/pkg/authbackend/oidc/mock"
+)
+
+const testRedirectURL = "http://rueder.invalid/oidc/callback"
+
+// authorize follows the provider's login page to the callback and returns the authorization code
+func authorize(t *testing.T, p *oidc.Provider, req oidc.AuthRequest) string {
+	authURL, err := p.AuthCodeURL(context.Background(), req)
+	assert.NoError(t, err)
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(authURL)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, req.State, callback.Query().Get("state"))
+	return callback.Query().Get("code")
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	for _, clientSecret := range []string{"", "client secret"} {
+		mockProvider := mock.NewProvider("rueder", clientSecret, "alice")
+		defer mockProvider.Close()
+
+		p, err := oidc.NewProvider(mockProvider.URL+"/", "rueder", clientSecret, testRedirectURL, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, mockProvider.URL, p.Issuer())
+
+		req, err := oidc.NewAuthRequest()
+		assert.NoError(t, err)
+		code := authorize(t, p, req)
+
+		claims, err := p.Exchange(context.Background(), code, req)
+		if assert.NoError(t, err) {
+			assert.Equal(t, mockProvider.URL, claims.Issuer)
+			assert.Equal(t, "alice", claims.Subject)
+			assert.Equal(t, req.Nonce, claims.Nonce)
+		}
+
+		// codes can only be used once
+		_, err = p.Exchange(context.Background(), code, req)
+		assert.Error(t, err)
+	}
+}
+
+func TestProvider_ExchangePKCE(t *testing.T) {
+	mockProvider := mock.NewProvider("rueder", "", "alice")
+	defer mockProvider.Close()
+	p, err := oidc.NewProvider(mockProvider.URL, "rueder", "", testRedirectURL, nil)
+	assert.NoError(t, err)
+
+	req, err := oidc.NewAuthRequest()
+	assert.NoError(t, err)
+	code := authorize(t, p, req)
+
+	// somebody who intercepted the code doesn't have the verifier
+	otherReq, err := oidc.NewAuthRequest()
+	assert.NoError(t, err)
+	otherReq.Nonce = req.Nonce
+	_, err = p.Exchange(context.Background(), code, otherReq)
+	assert.Error(t, err)
+}
+
+func TestProvider_Discover(t *testing.T) {
+	mockProvider := mock.NewProvider("rueder", "", "alice")
+	defer mockProvider.Close()
+
+	p, err := oidc.NewProvider(mockProvider.URL, "rueder", "", testRedirectURL, nil)
+	assert.NoError(t, err)
+	discovery, err := p.Discover(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, mockProvider.URL+"/token", discovery.TokenEndpoint)
+	}
+
+	// the issuer in the discovery document must be the configured one
+	p, err = oidc.NewProvider(mockProvider.URL+"/realms/other", "rueder", "", testRedirectURL, nil)
+	assert.NoError(t, err)
+	_, err = p.Discover(context.Background())
+	assert.Error(t, err)
+
+	_, err = oidc.NewProvider(mockProvider.URL, "rueder", "", "", nil)
+	assert.Error(t, err)
+}
+
+func TestProvider_VerifyIDToken(t *testing.T) {
+	mockProvider := mock.NewProvider("rueder", "", "alice")
+	defer mockProvider.Close()
+	p, err := oidc.NewProvider(mockProvider.URL, "rueder", "", testRedirectURL, nil)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	validClaims := func() *oidc.IDTokenClaims {
+		return &oidc.IDTokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    mockProvider.URL,
+				Subject:   "alice",
+				Audience:  jwt.ClaimStrings{"rueder"},
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+				IssuedAt:  jwt.NewNumericDate(now),
+			},
+			Nonce: "nonce",
+		}
+	}
+
+	claims, err := p.VerifyIDToken(context.Background(), mockProvider.IDToken(validClaims()), "nonce")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "alice", claims.Subject)
+	}
+
+	// slightly wrong clocks are ok
+	claims = validClaims()
+	claims.IssuedAt = jwt.NewNumericDate(now.Add(10 * time.Second))
+	_, err = p.VerifyIDToken(context.Background(), mockProvider.IDToken(claims), "nonce")
+	assert.NoError(t, err)
+
+	invalid := map[string]func(claims *oidc.IDTokenClaims){
+		"wrong issuer":   func(claims *oidc.IDTokenClaims) { claims.Issuer = "https://evil.invalid" },
+		"no subject":     func(claims *oidc.IDTokenClaims) { claims.Subject = "" },
+		"wrong audience": func(claims *oidc.IDTokenClaims) { claims.Audience = jwt.ClaimStrings{"other"} },
+		"wrong azp": func(claims *oidc.IDTokenClaims) {
+			claims.Audience = jwt.ClaimStrings{"rueder", "other"}
+			claims.AuthorizedParty = "other"
+		},
+		"expired":       func(claims *oidc.IDTokenClaims) { claims.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Hour)) },
+		"no expiry":     func(claims *oidc.IDTokenClaims) { claims.ExpiresAt = nil },
+		"issued later":  func(claims *oidc.IDTokenClaims) { claims.IssuedAt = jwt.NewNumericDate(now.Add(time.Hour)) },
+		"wrong nonce":   func(claims *oidc.IDTokenClaims) { claims.Nonce = "other nonce" },
+		"missing nonce": func(claims *oidc.IDTokenClaims) { claims.Nonce = "" },
+	}
+	for name, modify := range invalid {
+		claims := validClaims()
+		modify(claims)
+		_, err = p.VerifyIDToken(context.Background(), mockProvider.IDToken(claims), "nonce")
+		assert.Error(t, err, name)
+	}
+
+	// only tokens signed by the provider are accepted
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	_, err = p.VerifyIDToken(context.Background(), hmacToken, "nonce")
+	assert.Error(t, err)
+
+	noneToken, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(t, err)
+	_, err = p.VerifyIDToken(context.Background(), noneToken, "nonce")
+	assert.Error(t, err)
+
+	otherProvider := mock.NewProvider("rueder", "", "alice")
+	defer otherProvider.Close()
+	_, err = p.VerifyIDToken(context.Background(), otherProvider.IDToken(validClaims()), "nonce")
+	assert.Error(t, err)
+}
@@ -1,9 +1,15 @@
 package controller
 
+import "sync"
+
 // Controller for Events API v1
 type Controller struct {
 	ruederRepo RuederRepository
 	eventRepo  UserEventRepository
+
+	// closed on shutdown to end all SSE streams
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
 }
 
 // NewController for Events API v1
@@ -11,5 +17,13 @@ func NewController(ruederRepo RuederRepository, eventRepo UserEventRepository) *
 	return &Controller{
 		ruederRepo: ruederRepo,
 		eventRepo:  eventRepo,
+		shutdown:   make(chan struct{}),
 	}
 }
+
+// Shutdown ends all SSE streams so the clients reconnect to another instance
+func (c *Controller) Shutdown() {
+	c.shutdownOnce.Do(func() {
+		close(c.shutdown)
+	})
+}
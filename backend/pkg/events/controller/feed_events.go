@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"github.com/apex/log"
+
+	"github.com/spezifisch/rueder3/backend/internal/common"
+)
+
+// RunFeedEventFanout forwards the feed events of the workers to all subscribers of the feed.
+// It blocks until the event repository is closed.
+func (c *Controller) RunFeedEventFanout() (err error) {
+	feedEvents, err := c.eventRepo.ConsumeFeedEvents()
+	if err != nil {
+		return
+	}
+
+	for message := range feedEvents {
+		c.fanoutFeedEvent(message)
+	}
+	return
+}
+
+// fanoutFeedEvent sends the payload of the feed event to every user subscribed to the feed
+func (c *Controller) fanoutFeedEvent(message common.FeedEventMessage) {
+	feedLog := log.WithField("feedID", message.FeedID).WithField("type", message.Payload.Type)
+
+	userIDs, err := c.ruederRepo.FeedSubscriberIDs(message.FeedID)
+	if err != nil {
+		feedLog.WithError(err).Error("couldn't get feed subscribers")
+		return
+	}
+
+	for _, userID := range userIDs {
+		if err := c.eventRepo.PublishUserEvent(userID, message.Payload); err != nil {
+			feedLog.WithError(err).WithField("userID", userID).Error("couldn't publish user event")
+		}
+	}
+	metricFeedEventsFannedOut.Add(float64(len(userIDs)))
+}
@@ -1,13 +1,23 @@
 package controller
 
-import "github.com/gofrs/uuid"
+import (
+	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/internal/common"
+)
 
 // UserEventRepository is for IPC notifications from the api package
 type UserEventRepository interface {
 	ConnectUser(uuid uuid.UUID) (state UserEventConsumer, err error)
+	// ConsumeFeedEvents returns the feed events published by the workers
+	ConsumeFeedEvents() (<-chan common.FeedEventMessage, error)
+	// PublishUserEvent sends a message to all connected clients of the user
+	PublishUserEvent(userID uuid.UUID, payload common.UserEventMessage) error
 }
 
 // RuederRepository is the interface to the persistent database
 type RuederRepository interface {
 	AddFeed(url string) (feedID uuid.UUID, err error) // HACK temporary stand-in so we can't assign RedisRepository to this
+	// FeedSubscriberIDs returns the ids of all users subscribed to the feed
+	FeedSubscriberIDs(feedID uuid.UUID) (userIDs []uuid.UUID, err error)
 }
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricSSEClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "rueder",
+		Subsystem: "events",
+		Name:      "sse_clients",
+		Help:      "Number of connected SSE clients.",
+	})
+
+	metricEventsForwarded = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "rueder",
+		Subsystem: "events",
+		Name:      "messages_forwarded_total",
+		Help:      "Number of messages from the message queue forwarded to SSE clients.",
+	})
+
+	metricFeedEventsFannedOut = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "rueder",
+		Subsystem: "events",
+		Name:      "feed_events_fanned_out_total",
+		Help:      "Number of user events created from feed events of the workers.",
+	})
+)
@@ -73,6 +73,7 @@ func (c *Controller) SSE(ctx *fiber.Ctx) error {
 			logBase.WithError(err).Error("couldn't connect to message queue")
 			return
 		}
+		metricSSEClients.Inc()
 
 		ticker := time.NewTicker(5 * time.Second)
 		var i int
@@ -105,8 +106,14 @@ func (c *Controller) SSE(ctx *fiber.Ctx) error {
 					if err != nil {
 						logBase.WithError(err).Info("disconnected")
 						quit = true
+					} else {
+						metricEventsForwarded.Inc()
 					}
 				}
+			case <-c.shutdown:
+				// closing the stream makes the browser reconnect
+				logBase.Info("server shutting down")
+				quit = true
 			}
 
 			if quit {
@@ -115,6 +122,7 @@ func (c *Controller) SSE(ctx *fiber.Ctx) error {
 		}
 
 		logBase.Info("cleaning up")
+		metricSSEClients.Dec()
 		eventUserState.Close <- struct{}{}
 		logBase.Info("cleaned up")
 	}))
@@ -1,10 +1,41 @@
 package controller
 
+import (
+	"net/http"
+	"time"
+
+	"github.com/spezifisch/rueder3/backend/pkg/httputil"
+)
+
 // Controller for feedfinder API
 type Controller struct {
+	httpClient      *http.Client
+	userAgent       string
+	maxBodySize     int64
+	commonFeedPaths []string
+	// searchTimeout limits the whole search including probing
+	searchTimeout time.Duration
 }
 
 // NewController for feedfinder API
 func NewController() *Controller {
-	return &Controller{}
+	return &Controller{
+		// the URLs come from users, so only public addresses are fetched
+		httpClient: httputil.NewPublicHTTPClient(20 * time.Second),
+		// same as the feed worker so sites treat us consistently
+		userAgent:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/89.0.4389.90 Safari/537.36",
+		maxBodySize: 5 * 1024 * 1024,
+		// probed if the site doesn't announce its feeds
+		commonFeedPaths: []string{
+			"/feed",
+			"/feed/",
+			"/rss",
+			"/rss.xml",
+			"/atom.xml",
+			"/feed.xml",
+			"/index.xml",
+			"/feed.json",
+		},
+		searchTimeout: 30 * time.Second,
+	}
 }
@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/apex/log"
+	"github.com/mmcdole/gofeed"
+)
+
+// mime types announced in <link rel="alternate"> tags that we consider feeds
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/rdf+xml":   true,
+}
+
+// findFeeds returns all feeds we can find for the given website URL
+func (c *Controller) findFeeds(ctx context.Context, siteURL string) (feeds []Feed, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.searchTimeout)
+	defer cancel()
+
+	finalURL, body, err := c.fetch(ctx, siteURL)
+	if err != nil {
+		return
+	}
+
+	// maybe the user gave us a feed URL directly
+	if parsedFeed, parseErr := gofeed.NewParser().Parse(bytes.NewReader(body)); parseErr == nil {
+		feeds = []Feed{{
+			URL:   finalURL.String(),
+			Title: strings.TrimSpace(parsedFeed.Title),
+		}}
+		return
+	}
+
+	// it's a website, look for announced feeds
+	feeds, err = parseFeedLinks(finalURL, body)
+	if err != nil {
+		return
+	}
+	if len(feeds) > 0 {
+		return
+	}
+
+	// nothing announced, try where feeds usually are
+	feeds = c.probeCommonFeedPaths(ctx, finalURL)
+	return
+}
+
+// fetch downloads the given URL and returns the URL after redirects and the (size-limited) body
+func (c *Controller) fetch(ctx context.Context, rawURL string) (finalURL *url.URL, body []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = fmt.Errorf("http status %d", resp.StatusCode)
+		return
+	}
+
+	body, err = io.ReadAll(io.LimitReader(resp.Body, c.maxBodySize))
+	if err != nil {
+		return
+	}
+	finalURL = resp.Request.URL
+	return
+}
+
+// parseFeedLinks extracts feeds from <link rel="alternate"> tags of a HTML page
+func parseFeedLinks(pageURL *url.URL, body []byte) (feeds []Feed, err error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	// relative links are resolved against <base href> if the page has one
+	baseURL := pageURL
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if u, err := pageURL.Parse(strings.TrimSpace(href)); err == nil {
+			baseURL = u
+		}
+	}
+	pageTitle := strings.TrimSpace(doc.Find("title").First().Text())
+
+	seen := make(map[string]bool)
+	feeds = make([]Feed, 0)
+	doc.Find("link[rel][href]").Each(func(_ int, s *goquery.Selection) {
+		rel, _ := s.Attr("rel")
+		if !hasToken(rel, "alternate") {
+			return
+		}
+		linkType, _ := s.Attr("type")
+		linkType = strings.ToLower(strings.TrimSpace(linkType))
+		if !feedLinkTypes[linkType] {
+			return
+		}
+
+		href, _ := s.Attr("href")
+		feedURL, err := baseURL.Parse(strings.TrimSpace(href))
+		if err != nil || (feedURL.Scheme != "http" && feedURL.Scheme != "https") {
+			return
+		}
+		feedURL.Fragment = ""
+		if seen[feedURL.String()] {
+			return
+		}
+		seen[feedURL.String()] = true
+
+		title, _ := s.Attr("title")
+		title = strings.TrimSpace(title)
+		if title == "" {
+			title = pageTitle
+		}
+
+		feeds = append(feeds, Feed{
+			URL:   feedURL.String(),
+			Title: title,
+		})
+	})
+	return
+}
+
+// probeCommonFeedPaths tries the usual feed locations on the site's host
+func (c *Controller) probeCommonFeedPaths(ctx context.Context, siteURL *url.URL) (feeds []Feed) {
+	feeds = make([]Feed, 0)
+	seen := make(map[string]bool)
+	for _, path := range c.commonFeedPaths {
+		probeURL := url.URL{
+			Scheme: siteURL.Scheme,
+			Host:   siteURL.Host,
+			Path:   path,
+		}
+
+		if ctx.Err() != nil {
+			// out of time, return what we have
+			return
+		}
+
+		finalURL, body, err := c.fetch(ctx, probeURL.String())
+		if err != nil {
+			log.WithError(err).WithField("url", probeURL.String()).Debug("probing feed failed")
+			continue
+		}
+		if seen[finalURL.String()] {
+			// eg. /feed redirecting to /feed/
+			continue
+		}
+
+		parsedFeed, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		seen[finalURL.String()] = true
+
+		feeds = append(feeds, Feed{
+			URL:   finalURL.String(),
+			Title: strings.TrimSpace(parsedFeed.Title),
+		})
+	}
+	return
+}
+
+// hasToken returns true if the space-separated list contains the given token (case-insensitive)
+func hasToken(list, token string) bool {
+	for _, t := range strings.Fields(list) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
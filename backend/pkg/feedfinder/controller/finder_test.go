@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spezifisch/rueder3/backend/pkg/httputil"
+)
+
+const testRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>Test Feed</title><link>https://example.com/</link>
+<item><title>Hello</title><guid>1</guid></item>
+</channel></rss>`
+
+func Test_parseFeedLinks(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/blog/post.html")
+	html := `<html><head>
+<title> Example Blog </title>
+<link rel="stylesheet" href="/style.css">
+<link rel="alternate" type="application/rss+xml" title="RSS" href="/rss.xml">
+<link rel="Alternate" type="application/atom+xml" href="atom.xml">
+<link rel="alternate" type="application/feed+json" title="JSON" href="https://cdn.example.com/feed.json">
+<link rel="alternate" type="application/rss+xml" title="Duplicate" href="/rss.xml#foo">
+<link rel="alternate" type="text/html" hreflang="de" href="/de/">
+<link rel="alternate" type="application/rss+xml" href="javascript:alert(1)">
+</head><body></body></html>`
+
+	feeds, err := parseFeedLinks(pageURL, []byte(html))
+	assert.NoError(t, err)
+	assert.Equal(t, []Feed{
+		{URL: "https://example.com/rss.xml", Title: "RSS"},
+		{URL: "https://example.com/blog/atom.xml", Title: "Example Blog"},
+		{URL: "https://cdn.example.com/feed.json", Title: "JSON"},
+	}, feeds)
+}
+
+func Test_parseFeedLinks_Base(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/a/b/c")
+	html := `<html><head><base href="https://other.example.com/x/">
+<link rel="alternate" type="application/atom+xml" title="Atom" href="feed.atom">
+</head></html>`
+
+	feeds, err := parseFeedLinks(pageURL, []byte(html))
+	assert.NoError(t, err)
+	assert.Equal(t, []Feed{{URL: "https://other.example.com/x/feed.atom", Title: "Atom"}}, feeds)
+}
+
+func TestController_findFeeds(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/announced", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<html><head><link rel="alternate" type="application/rss+xml" title="Announced" href="/announced.xml"></head></html>`))
+	})
+	mux.HandleFunc("/direct.xml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testRSS))
+	})
+	mux.HandleFunc("/rss.xml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testRSS))
+	})
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/rss.xml", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`<html><head><title>No Feeds</title></head></html>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewController()
+	// the test server is on loopback
+	c.httpClient = &http.Client{}
+	ctx := context.Background()
+
+	t.Run("announced feed", func(t *testing.T) {
+		feeds, err := c.findFeeds(ctx, server.URL+"/announced")
+		assert.NoError(t, err)
+		assert.Equal(t, []Feed{{URL: server.URL + "/announced.xml", Title: "Announced"}}, feeds)
+	})
+
+	t.Run("feed url given directly", func(t *testing.T) {
+		feeds, err := c.findFeeds(ctx, server.URL+"/direct.xml")
+		assert.NoError(t, err)
+		assert.Equal(t, []Feed{{URL: server.URL + "/direct.xml", Title: "Test Feed"}}, feeds)
+	})
+
+	t.Run("probing common paths", func(t *testing.T) {
+		feeds, err := c.findFeeds(ctx, server.URL+"/")
+		assert.NoError(t, err)
+		// /feed redirects to /rss.xml so it's only found once
+		assert.Equal(t, []Feed{{URL: server.URL + "/rss.xml", Title: "Test Feed"}}, feeds)
+	})
+
+	t.Run("site not found", func(t *testing.T) {
+		_, err := c.findFeeds(ctx, server.URL+"/doesnotexist")
+		assert.Error(t, err)
+	})
+}
+
+func TestController_findFeedsPrivate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testRSS))
+	}))
+	defer server.Close()
+
+	c := NewController()
+	_, err := c.findFeeds(context.Background(), server.URL+"/rss.xml")
+	assert.ErrorIs(t, err, httputil.ErrForbiddenAddress)
+}
+
+func TestController_findFeedsTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			time.Sleep(200 * time.Millisecond)
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`<html><head><title>No Feeds</title></head></html>`))
+	}))
+	defer server.Close()
+
+	c := NewController()
+	c.httpClient = &http.Client{}
+	c.searchTimeout = 300 * time.Millisecond
+
+	// probing stops at the deadline instead of trying every path
+	start := time.Now()
+	feeds, err := c.findFeeds(context.Background(), server.URL+"/")
+	assert.NoError(t, err)
+	assert.Empty(t, feeds)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -5,10 +5,15 @@
 package controller
 
 import (
+	"context"
+	"errors"
+
+	"github.com/apex/log"
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
 	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+	"github.com/spezifisch/rueder3/backend/pkg/httputil"
 )
 
 // Feedfinder godoc
@@ -38,11 +43,28 @@ func (c *Controller) Feedfinder(ctx *fiber.Ctx) error {
 	}
 	siteURL := json.URL
 
-	// TODO find feeds
+	feeds, err := c.findFeeds(ctx.UserContext(), siteURL)
+	if err != nil {
+		log.WithError(err).WithField("url", siteURL).Info("finding feeds failed")
+
+		// the error text can tell things about the network we're in, so it's not returned
+		message := "couldn't fetch site"
+		if errors.Is(err, httputil.ErrForbiddenAddress) {
+			message = "site address not allowed"
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			message = "site took too long"
+		}
+		return ctx.JSON(FeedFinderResponse{
+			OK:           false,
+			ErrorMessage: message,
+			URL:          siteURL,
+		})
+	}
 
 	result := FeedFinderResponse{
-		OK:  true,
-		URL: siteURL,
+		OK:    true,
+		URL:   siteURL,
+		Feeds: feeds,
 	}
 	return ctx.JSON(result)
 }
@@ -1,11 +1,15 @@
 package http
 
 import (
+	"context"
+	"time"
+
 	"github.com/apex/log"
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/spezifisch/rueder3/backend/pkg/feedfinder/controller"
 	"github.com/spezifisch/rueder3/backend/pkg/fibertools"
+	"github.com/spezifisch/rueder3/backend/pkg/health"
 )
 
 // Server is a http server
@@ -16,11 +20,12 @@ type Server struct {
 	controller        *controller.Controller
 	jwtSecretKey      string
 	isDevelopmentMode bool
+	readinessChecks   health.Checks
 	trustedProxies    []string
 }
 
 // NewServer creates a default http backend
-func NewServer(controller *controller.Controller, bind string, jwtSecretKey string, isDevelopmentMode bool, trustedProxies []string) *Server {
+func NewServer(controller *controller.Controller, bind string, jwtSecretKey string, isDevelopmentMode bool, trustedProxies []string, readinessChecks health.Checks) *Server {
 	if controller == nil {
 		panic("controller is nil")
 	}
@@ -30,6 +35,7 @@ func NewServer(controller *controller.Controller, bind string, jwtSecretKey stri
 		controller:        controller,
 		jwtSecretKey:      jwtSecretKey,
 		isDevelopmentMode: isDevelopmentMode,
+		readinessChecks:   readinessChecks,
 		trustedProxies:    trustedProxies,
 	}
 	s.init()
@@ -46,8 +52,11 @@ func (s *Server) init() {
 	enableTrustedProxyCheck := !s.isDevelopmentMode
 	s.app = fibertools.NewFiberRuederApp(appName, s.isDevelopmentMode, enableTrustedProxyCheck, nil)
 
-	// add auth middleware, all following routes require auth
-	authMiddleware, err := fibertools.NewFiberAuthMiddleware(s.jwtSecretKey)
+	// health checks for the orchestrator don't require auth
+	fibertools.AddFiberHealthRoutes(s.app, s.readinessChecks)
+
+	// add auth middleware, all following routes require auth. without a db there are no API tokens.
+	authMiddleware, err := fibertools.NewFiberAuthMiddleware(s.jwtSecretKey, nil)
 	if err != nil {
 		log.WithError(err).Error("couldn't setup jwt auth middleware")
 		return
@@ -58,10 +67,23 @@ func (s *Server) init() {
 	s.addRoutesApiFeedfinderV1()
 }
 
-// Run starts the server
-func (s *Server) Run() {
+// Run starts the server. When ctx is cancelled it stops accepting connections and returns
+// after the open ones are finished, waiting at most shutdownTimeout for them.
+func (s *Server) Run(ctx context.Context, shutdownTimeout time.Duration) {
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+
+		<-ctx.Done()
+		if err := s.app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+			log.WithError(err).Error("http server shutdown failed")
+		}
+	}()
+
 	err := s.app.Listen(s.Bind)
 	if err != nil {
 		log.WithError(err).Error("gin failed")
+		return
 	}
+	<-shutdownDone
 }
@@ -29,11 +29,18 @@ func GetFiberAuthClaims(c *fiber.Ctx) *helpers.AuthClaims {
 	name := claims["sub"].(string)
 	originName := fmt.Sprintf("%s:%s", origin, name)
 
+	// tokens issued before roles were introduced don't have one
+	role, ok := claims["role"].(string)
+	if !ok || role == "" {
+		role = helpers.RoleUser
+	}
+
 	ret := &helpers.AuthClaims{
 		ID:         uid,
 		Origin:     origin,
 		Name:       name,
 		OriginName: originName,
+		Role:       role,
 	}
 	if !ret.IsValid() {
 		return nil
@@ -2,15 +2,25 @@ package fibertools
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/apex/log"
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
 
 	jwtware "github.com/gofiber/jwt/v3"
+
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
 )
 
-// NewFiberAuthMiddleware configures a middleware that ensures the user has a valid JWT
-func NewFiberAuthMiddleware(jwtSecretKey string) (authMiddleware fiber.Handler, err error) {
+// APITokenValidator looks up the user of a personal API token
+type APITokenValidator interface {
+	ValidateAPIToken(token string) (*helpers.AuthClaims, error)
+}
+
+// NewFiberAuthMiddleware configures a middleware that ensures the user has a valid JWT.
+// If apiTokens is set, personal API tokens are accepted too.
+func NewFiberAuthMiddleware(jwtSecretKey string, apiTokens APITokenValidator) (authMiddleware fiber.Handler, err error) {
 	if jwtSecretKey == "secret" {
 		// (2x space after emoji looks better)
 		log.Warn("⚠️  USING INSECURE JWT SECRET KEY. Do this for local development only!")
@@ -57,6 +67,37 @@ func NewFiberAuthMiddleware(jwtSecretKey string) (authMiddleware fiber.Handler,
 		// Optional. Default: "Bearer".
 		AuthScheme: "Bearer",
 	}
-	authMiddleware = jwtware.New(config)
+	jwtMiddleware := jwtware.New(config)
+	if apiTokens == nil {
+		authMiddleware = jwtMiddleware
+		return
+	}
+
+	authMiddleware = func(c *fiber.Ctx) error {
+		token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !helpers.IsAPIToken(token) {
+			return jwtMiddleware(c)
+		}
+
+		claims, err := apiTokens.ValidateAPIToken(token)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"code":    fiber.StatusUnauthorized,
+				"message": "Invalid API token",
+			})
+		}
+
+		// look like a JWT to the handlers
+		c.Locals("user", &jwt.Token{
+			Valid: true,
+			Claims: jwt.MapClaims{
+				"uid":    claims.ID.String(),
+				"origin": claims.Origin,
+				"sub":    claims.Name,
+				"role":   claims.Role,
+			},
+		})
+		return c.Next()
+	}
 	return
 }
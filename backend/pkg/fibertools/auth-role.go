@@ -0,0 +1,20 @@
+package fibertools
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// NewFiberRoleMiddleware configures a middleware that only lets users with the given role through.
+// It has to come after the auth middleware.
+func NewFiberRoleMiddleware(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := GetFiberAuthClaims(c)
+		if claims == nil || claims.Role != role {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"code":    fiber.StatusForbidden,
+				"message": "Missing role " + role,
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,25 @@
+package fibertools
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/spezifisch/rueder3/backend/pkg/health"
+)
+
+// AddFiberHealthRoutes adds the unauthenticated /healthz and /readyz routes.
+// It has to be called before adding the auth middleware.
+func AddFiberHealthRoutes(app *fiber.App, checks health.Checks) {
+	// the process is running and serving requests
+	app.Get("/healthz", func(ctx *fiber.Ctx) error {
+		return ctx.JSON(health.Response{Status: "ok"})
+	})
+
+	// all dependencies are usable
+	app.Get("/readyz", func(ctx *fiber.Ctx) error {
+		ret, ready := checks.Run()
+		if !ready {
+			ctx.Status(fiber.StatusServiceUnavailable)
+		}
+		return ctx.JSON(ret)
+	})
+}
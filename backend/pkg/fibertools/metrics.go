@@ -0,0 +1,43 @@
+package fibertools
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var metricRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "rueder",
+	Subsystem: "http",
+	Name:      "request_duration_seconds",
+	Help:      "Duration of HTTP requests by route.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"method", "route", "status"})
+
+// NewFiberMetricsMiddleware returns a middleware that records the request latency per route.
+// It has to be added before all other handlers to measure them.
+func NewFiberMetricsMiddleware() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		start := time.Now()
+		err := ctx.Next()
+
+		// errors are turned into responses by the error handler after all middlewares are done
+		status := ctx.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+
+		// use the route pattern instead of the path to keep the number of labels small
+		metricRequestDuration.WithLabelValues(ctx.Method(), ctx.Route().Path, strconv.Itoa(status)).
+			Observe(time.Since(start).Seconds())
+		return err
+	}
+}
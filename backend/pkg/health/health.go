@@ -0,0 +1,64 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// CheckTimeout is the time a single check should take at most
+const CheckTimeout = 2 * time.Second
+
+// Check returns an error if a dependency of the service isn't usable
+type Check func() error
+
+// Checks are the named readiness checks of a service
+type Checks map[string]Check
+
+// Response is returned by /healthz and /readyz
+type Response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Run executes all checks and returns the result of each one
+func (c Checks) Run() (ret Response, ready bool) {
+	ready = true
+	ret.Status = "ok"
+	if len(c) == 0 {
+		return
+	}
+
+	ret.Checks = make(map[string]string, len(c))
+	for name, check := range c {
+		if err := check(); err != nil {
+			ret.Checks[name] = err.Error()
+			ret.Status = "unavailable"
+			ready = false
+		} else {
+			ret.Checks[name] = "ok"
+		}
+	}
+	return
+}
+
+// AddHTTPRoutes adds /healthz and /readyz to a net/http mux, for services without a fiber app
+func AddHTTPRoutes(mux *http.ServeMux, checks Checks) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, http.StatusOK, Response{Status: "ok"})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ret, ready := checks.Run()
+		status := http.StatusOK
+		if !ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeResponse(w, status, ret)
+	})
+}
+
+func writeResponse(w http.ResponseWriter, status int, ret Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(ret)
+}
@@ -0,0 +1,50 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecks_Run(t *testing.T) {
+	ret, ready := Checks(nil).Run()
+	assert.True(t, ready)
+	assert.Equal(t, "ok", ret.Status)
+	assert.Nil(t, ret.Checks)
+
+	ret, ready = Checks{
+		"db":       func() error { return nil },
+		"rabbitmq": func() error { return errors.New("connection closed") },
+	}.Run()
+	assert.False(t, ready)
+	assert.Equal(t, "unavailable", ret.Status)
+	assert.Equal(t, map[string]string{"db": "ok", "rabbitmq": "connection closed"}, ret.Checks)
+}
+
+func TestAddHTTPRoutes(t *testing.T) {
+	dbErr := errors.New("db down")
+	mux := http.NewServeMux()
+	AddHTTPRoutes(mux, Checks{
+		"db": func() error { return dbErr },
+	})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	ret := Response{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ret))
+	assert.Equal(t, "db down", ret.Checks["db"])
+
+	dbErr = nil
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,21 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// APITokenPrefix starts every personal API token. It tells them apart from JWTs.
+const APITokenPrefix = "rueder_"
+
+// IsAPIToken returns true if the token looks like a personal API token
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// HashAPIToken returns the hash that's stored for the token. The tokens are random so a plain hash is enough.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,12 +2,20 @@ package helpers
 
 import "github.com/gofrs/uuid"
 
+// roles issued by the authbackend in the JWT's role claim
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // AuthClaims is the parsed data from the JWT
 type AuthClaims struct {
 	ID         uuid.UUID
 	Origin     string
 	Name       string
 	OriginName string
+	// RoleUser if the JWT has no role claim
+	Role string
 }
 
 func (a AuthClaims) IsValid() bool {
@@ -0,0 +1,51 @@
+package httputil
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a URL resolves to an address that isn't public
+var ErrForbiddenAddress = errors.New("address not allowed")
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598 which net.IP doesn't consider private
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// NewPublicHTTPClient returns a client that refuses to connect to loopback, private and link-local addresses.
+// The check is done when connecting, so it also covers redirects and DNS names resolving to such addresses.
+// Use it for all URLs that come from users or feeds.
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be on a private address and hide the target
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// isPublicIP returns true if the address is reachable on the internet
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || sharedAddressSpace.Contains(ip))
+}
@@ -0,0 +1,18 @@
+package httputil
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_isPublicIP(t *testing.T) {
+	for _, ip := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"} {
+		assert.True(t, isPublicIP(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1"} {
+		assert.False(t, isPublicIP(net.ParseIP(ip)), ip)
+	}
+}
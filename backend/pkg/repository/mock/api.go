@@ -114,7 +114,7 @@ func (r *Repository) GetFeed(id uuid.UUID) (controller.Feed, error) {
 }
 
 // Feeds returns some feeds
-func (r *Repository) Feeds() (ret []controller.Feed, err error) {
+func (r *Repository) Feeds(claims *helpers.AuthClaims) (ret []controller.Feed, err error) {
 	ret = make([]controller.Feed, 10)
 	for i := 0; i < len(ret); i++ {
 		ret[i], _ = r.GetFeed(getMockUUID())
@@ -142,6 +142,11 @@ func (*Repository) AddFeed(url string) (feedID uuid.UUID, err error) {
 	return
 }
 
+// FeedSubscriberIDs returns no users
+func (*Repository) FeedSubscriberIDs(feedID uuid.UUID) (userIDs []uuid.UUID, err error) {
+	return
+}
+
 // GetFeedByURL does nothing
 func (*Repository) GetFeedByURL(url string) (ret controller.Feed, err error) {
 	err = errors.New("not implemented")
@@ -154,6 +159,158 @@ func (*Repository) ChangeFolders(claims *helpers.AuthClaims, folders []controlle
 	return
 }
 
+// ImportFolders does nothing
+func (*Repository) ImportFolders(claims *helpers.AuthClaims, folders []controller.Folder, newFeeds []controller.NewFeed) (err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// FolderFeedIDs does nothing
+func (*Repository) FolderFeedIDs(claims *helpers.AuthClaims, folderID uuid.UUID) (feedIDs []uuid.UUID, err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// GetUserArticles does nothing
+func (*Repository) GetUserArticles(claims *helpers.AuthClaims, feedIDs []uuid.UUID, limit int, offset int) (ret []controller.ArticlePreview, err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// SearchArticles does nothing
+func (*Repository) SearchArticles(claims *helpers.AuthClaims, query string, feedIDs []uuid.UUID, limit int, offset int) (ret []controller.ArticlePreview, err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// ChangeFeedSettings does nothing
+func (*Repository) ChangeFeedSettings(claims *helpers.AuthClaims, feedID uuid.UUID, settings controller.FeedSettings) (err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// ChangeFeed does nothing
+func (*Repository) ChangeFeed(claims *helpers.AuthClaims, feedID uuid.UUID, changes controller.FeedChanges) (err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// DeleteFeed does nothing
+func (*Repository) DeleteFeed(feedID uuid.UUID) (subscriberIDs []uuid.UUID, err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// RefreshFeed does nothing
+func (*Repository) RefreshFeed(claims *helpers.AuthClaims, feedID uuid.UUID) (err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// Users returns no users
+func (*Repository) Users() (ret []controller.AdminUser, err error) {
+	return []controller.AdminUser{}, nil
+}
+
+// DeleteUser does nothing
+func (*Repository) DeleteUser(userID uuid.UUID) (err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// FeedHealth returns no feeds
+func (*Repository) FeedHealth(brokenOnly bool) (ret []controller.AdminFeed, err error) {
+	return []controller.AdminFeed{}, nil
+}
+
+// ForceRefreshFeed does nothing
+func (*Repository) ForceRefreshFeed(claims *helpers.AuthClaims, feedID uuid.UUID) (err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// ResetFeed does nothing
+func (*Repository) ResetFeed(claims *helpers.AuthClaims, feedID uuid.UUID) (err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// FolderLimits returns the same limits as the pop repository
+func (*Repository) FolderLimits() (folderCount int, folderFeedCount int) {
+	return 100, 1000
+}
+
+// UserState returns an empty state
+func (*Repository) UserState(claims *helpers.AuthClaims) (controller.UserState, error) {
+	return controller.UserState{
+		FeedStates: make(map[uuid.UUID]controller.UserFeedState),
+	}, nil
+}
+
+// MarkArticles does nothing
+func (*Repository) MarkArticles(claims *helpers.AuthClaims, feedIDs []uuid.UUID, seqs []int, read bool) (ret controller.UserState, err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// MarkAllRead does nothing
+func (*Repository) MarkAllRead(claims *helpers.AuthClaims, feedIDs []uuid.UUID, untilSeq int) (ret controller.UserState, err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// Labels returns no labels
+func (*Repository) Labels(claims *helpers.AuthClaims) ([]controller.Label, error) {
+	return []controller.Label{}, nil
+}
+
+// AddLabel does nothing
+func (*Repository) AddLabel(claims *helpers.AuthClaims, name string, color string) (ret controller.Label, err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// ChangeLabel does nothing
+func (*Repository) ChangeLabel(claims *helpers.AuthClaims, labelID uuid.UUID, name string, color string) (ret controller.Label, err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// DeleteLabel does nothing
+func (*Repository) DeleteLabel(claims *helpers.AuthClaims, labelID uuid.UUID) (err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// AssignLabel does nothing
+func (*Repository) AssignLabel(claims *helpers.AuthClaims, labelID uuid.UUID, articleID uuid.UUID) (err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// UnassignLabel does nothing
+func (*Repository) UnassignLabel(claims *helpers.AuthClaims, labelID uuid.UUID, articleID uuid.UUID) (err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// GetLabelArticles does nothing
+func (*Repository) GetLabelArticles(claims *helpers.AuthClaims, labelID uuid.UUID, limit int, offset int) (ret []controller.ArticlePreview, err error) {
+	err = errors.New("not implemented")
+	return
+}
+
+// LabelledArticles returns no articles
+func (*Repository) LabelledArticles(claims *helpers.AuthClaims) ([]controller.LabelledArticle, error) {
+	return []controller.LabelledArticle{}, nil
+}
+
+// ValidateAPIToken accepts no tokens
+func (*Repository) ValidateAPIToken(token string) (claims *helpers.AuthClaims, err error) {
+	err = errors.New("not implemented")
+	return
+}
+
 func getMockUUID() uuid.UUID {
 	id, _ := uuid.NewGen().NewV4()
 	return id
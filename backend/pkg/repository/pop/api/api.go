@@ -1,10 +1,17 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
 	"github.com/apex/log"
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
 	"github.com/spezifisch/rueder3/backend/pkg/api/controller"
+	"github.com/spezifisch/rueder3/backend/pkg/health"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
 	"github.com/spezifisch/rueder3/backend/pkg/repository/pop/models"
 )
 
@@ -14,6 +21,8 @@ type APIPopRepository struct {
 
 	folderCountLimit     int
 	folderFeedCountLimit int
+	markArticlesLimit    int
+	labelCountLimit      int
 }
 
 // NewAPIPopRepository returns a FeedRepository that wraps a pop DB
@@ -28,13 +37,33 @@ func NewAPIPopRepository(db string) *APIPopRepository {
 		pop:                  tx,
 		folderCountLimit:     100,
 		folderFeedCountLimit: 1000,
+		markArticlesLimit:    1000,
+		labelCountLimit:      100,
 	}
 }
 
-// Feeds returns all feeds
-func (r *APIPopRepository) Feeds() (feeds []controller.Feed, err error) {
+// Ping checks if the database is usable
+func (r *APIPopRepository) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), health.CheckTimeout)
+	defer cancel()
+
+	return r.pop.WithContext(ctx).RawQuery("SELECT 1").Exec()
+}
+
+// Close closes the database connection
+func (r *APIPopRepository) Close() error {
+	return r.pop.Close()
+}
+
+// Feeds returns the feeds the user is subscribed to
+func (r *APIPopRepository) Feeds(claims *helpers.AuthClaims) (feeds []controller.Feed, err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
 	allFeeds := []models.Feed{}
-	if err = r.pop.All(&allFeeds); err != nil {
+	err = r.pop.Where("id IN (SELECT feed_id FROM user_feeds WHERE user_id = ?)", claims.ID).All(&allFeeds)
+	if err != nil {
 		log.WithError(err).Error("failed fetching feeds")
 		return
 	}
@@ -49,15 +78,26 @@ func (r *APIPopRepository) Feeds() (feeds []controller.Feed, err error) {
 	return
 }
 
+// articleColumns are the columns of models.Article. The search vector in the articles table is
+// left out on purpose, it's only needed in the search query and can be large.
+const articleColumns = "articles.id, articles.created_at, articles.updated_at, articles.seq, articles.feed_id, articles.site_guid, " +
+	"articles.posted_at, articles.link, articles.thumbnail, articles.image, articles.image_title, articles.title, articles.teaser, articles.content"
+
 // GetArticle returns the article with the given id
 func (r *APIPopRepository) GetArticle(id uuid.UUID) (ret controller.Article, err error) {
 	article := models.Article{}
-	err = r.pop.Eager().Select("*, row_number() over (partition by feed_id order by seq) as feed_seq").Find(&article, id)
+	err = r.pop.Eager().Select(articleColumns, "row_number() over (partition by feed_id order by seq) as feed_seq").Find(&article, id)
 	if err != nil {
 		log.WithError(err).Error("failed fetching article")
 		return
 	}
 
+	ret = toArticle(&article, article.Feed)
+	return
+}
+
+// toArticle converts the article with its feed for the article view
+func toArticle(article *models.Article, feed *models.Feed) (ret controller.Article) {
 	var enclosures []controller.ArticleEnclosure = nil
 	if article.Content.Enclosures != nil && len(article.Content.Enclosures) > 0 {
 		enclosures = make([]controller.ArticleEnclosure, len(article.Content.Enclosures))
@@ -77,9 +117,9 @@ func (r *APIPopRepository) GetArticle(id uuid.UUID) (ret controller.Article, err
 
 	ret = controller.Article{
 		ID:        article.ID,
-		FeedID:    article.Feed.ID,
-		FeedTitle: article.Feed.Title.String,
-		FeedURL:   article.Feed.FeedURL,
+		FeedID:    feed.ID,
+		FeedTitle: feed.Title.String,
+		FeedURL:   feed.FeedURL,
 
 		Title: article.Title.String,
 		Time:  article.PostedAt,
@@ -107,7 +147,7 @@ func (r *APIPopRepository) GetArticles(feedID uuid.UUID, limit int, offset int)
 
 	// get articles of feed
 	feedArticles := models.Articles{}
-	q := r.pop.Select("*, row_number() over (partition by feed_id order by seq) as feed_seq").Where("feed_id = ?", feedID)
+	q := r.pop.Select(articleColumns, "row_number() over (partition by feed_id order by seq) as feed_seq").Where("feed_id = ?", feedID)
 	if offset > 0 {
 		q = q.Where("seq < ?", offset)
 	}
@@ -152,14 +192,25 @@ func (r *APIPopRepository) GetFeed(id uuid.UUID) (ret controller.Feed, err error
 		return
 	}
 
+	fetchFullArticle := feed.FetchFullArticle
+	if !fetchFullArticle {
+		fetchFullArticle, err = r.pop.Where("id = ? AND "+models.FullArticleRequestedCondition, feed.ID).Exists(&models.Feed{})
+		if err != nil {
+			log.WithError(err).Error("failed checking feed preferences")
+			return
+		}
+	}
+
 	ret = controller.Feed{
-		ID:           feed.ID,
-		Title:        feed.Title.String,
-		Icon:         feed.Icon.String,
-		URL:          feed.FeedURL,
-		SiteURL:      feed.SiteURL.String,
-		ArticleCount: articleCount,
+		ID:               feed.ID,
+		Title:            feed.Title.String,
+		Icon:             feed.Icon.String,
+		URL:              feed.FeedURL,
+		SiteURL:          feed.SiteURL.String,
+		ArticleCount:     articleCount,
+		FetchFullArticle: fetchFullArticle,
 		FetcherState: &controller.FetcherState{
+			Dead:        feed.FetcherState.Dead,
 			Working:     feed.FetcherState.Working,
 			LastSuccess: feed.FetcherState.LastSuccess,
 			LastError:   feed.FetcherState.LastError,
@@ -182,6 +233,106 @@ func (r *APIPopRepository) AddFeed(url string) (feedID uuid.UUID, err error) {
 	return
 }
 
+// FeedSubscriberIDs returns the ids of all users subscribed to the feed
+func (r *APIPopRepository) FeedSubscriberIDs(feedID uuid.UUID) (userIDs []uuid.UUID, err error) {
+	userFeeds := []models.UserFeed{}
+	err = r.pop.Select("user_id").Where("feed_id = ?", feedID).All(&userFeeds)
+	if err != nil {
+		return
+	}
+
+	// a user can have the feed in several folders
+	seen := make(map[uuid.UUID]bool, len(userFeeds))
+	for _, userFeed := range userFeeds {
+		if !seen[userFeed.UserID] {
+			seen[userFeed.UserID] = true
+			userIDs = append(userIDs, userFeed.UserID)
+		}
+	}
+	return
+}
+
+// ChangeFeedSettings changes the fetcher options of a feed. They are shared by all subscribers, so only admins may change them.
+func (r *APIPopRepository) ChangeFeedSettings(claims *helpers.AuthClaims, feedID uuid.UUID, settings controller.FeedSettings) (err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
+	feed := models.Feed{}
+	if err = r.pop.Select("id").Find(&feed, feedID); err != nil {
+		return errors.New("feed doesn't exist")
+	}
+
+	feed.FetchFullArticle = settings.FetchFullArticle
+	err = r.pop.UpdateColumns(&feed, "fetch_full_article", "updated_at")
+	return
+}
+
+// feedRefreshNotification asks the worker to fetch a feed now. It's sent on the channel the worker listens to for feed changes.
+type feedRefreshNotification struct {
+	Table  string    `json:"table"`
+	Action string    `json:"action"`
+	FeedID uuid.UUID `json:"feed_id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// RefreshFeed asks the worker to fetch the subscribed feed as soon as possible
+func (r *APIPopRepository) RefreshFeed(claims *helpers.AuthClaims, feedID uuid.UUID) (err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
+	if _, err = r.subscribedFeedIDs(r.pop, claims.ID, []uuid.UUID{feedID}); err != nil {
+		return
+	}
+
+	return r.pop.Transaction(func(tx *pop.Connection) (err error) {
+		return requestFeedRefresh(tx, feedID, claims.ID)
+	})
+}
+
+// requestFeedRefresh makes the feed due now and notifies the worker on behalf of the user.
+// The request is also stored for workers leasing the feeds, they don't listen for the notification.
+func requestFeedRefresh(tx *pop.Connection, feedID uuid.UUID, userID uuid.UUID) (err error) {
+	payload, err := json.Marshal(feedRefreshNotification{
+		Table:  "feeds",
+		Action: "REFRESH",
+		FeedID: feedID,
+		UserID: userID,
+	})
+	if err != nil {
+		return
+	}
+
+	// workers leasing feeds from the db claim the feed next
+	now := time.Now().UTC()
+	err = tx.RawQuery("UPDATE feeds SET next_fetch_at = ? WHERE id = ?", now, feedID).Exec()
+	if err != nil {
+		return
+	}
+	// an earlier request of the user that wasn't answered yet is answered by the same fetch
+	err = tx.RawQuery(`INSERT INTO feed_refresh_requests (created_at, updated_at, feed_id, user_id) VALUES (?, ?, ?, ?)
+		ON CONFLICT (feed_id, user_id) DO NOTHING`, now, now, feedID, userID).Exec()
+	if err != nil {
+		return
+	}
+	// the notification is delivered on commit
+	return tx.RawQuery("SELECT pg_notify('feed_change', ?)", string(payload)).Exec()
+}
+
+// notifyFeedUpdate tells the worker that the feed changed like the feeds trigger does for its own columns
+func notifyFeedUpdate(tx *pop.Connection, feedID uuid.UUID) (err error) {
+	payload, err := json.Marshal(feedRefreshNotification{
+		Table:  "feeds",
+		Action: "UPDATE",
+		FeedID: feedID,
+	})
+	if err != nil {
+		return
+	}
+	return tx.RawQuery("SELECT pg_notify('feed_change', ?)", string(payload)).Exec()
+}
+
 // GetFeedByURL returns a feed with the given URL, an error otherwise
 func (r *APIPopRepository) GetFeedByURL(url string) (ret controller.Feed, err error) {
 	feed := models.Feed{}
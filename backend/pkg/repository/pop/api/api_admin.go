@@ -0,0 +1,177 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/spezifisch/rueder3/backend/pkg/api/controller"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+	"github.com/spezifisch/rueder3/backend/pkg/repository/pop/models"
+)
+
+// userFeedCount is used to query the subscription count of a user
+type userFeedCount struct {
+	ID        uuid.UUID `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+
+	AuthOrigin  string `db:"auth_origin"`
+	AuthSubject string `db:"auth_subject"`
+	FeedCount   int    `db:"feed_count"`
+}
+
+// Users returns all users with their subscription count
+func (r *APIPopRepository) Users() (ret []controller.AdminUser, err error) {
+	if r == nil || r.pop == nil {
+		err = errors.New("invalid repository")
+		return
+	}
+
+	users := []userFeedCount{}
+	err = r.pop.RawQuery(`SELECT users.id, users.created_at, users.auth_origin, users.auth_subject,
+			(SELECT COUNT(*) FROM user_feeds WHERE user_feeds.user_id = users.id) AS feed_count
+		FROM users ORDER BY users.created_at`).All(&users)
+	if err != nil {
+		return
+	}
+
+	ret = make([]controller.AdminUser, len(users))
+	for i, user := range users {
+		ret[i] = controller.AdminUser{
+			ID:          user.ID,
+			CreatedAt:   user.CreatedAt,
+			AuthOrigin:  user.AuthOrigin,
+			AuthSubject: user.AuthSubject,
+			FeedCount:   user.FeedCount,
+		}
+	}
+	return
+}
+
+// DeleteUser deletes the user with subscriptions, read state and labels. The labels with their assignments,
+// the password and the API tokens are removed by foreign key cascades and the scheduler is notified about the lost
+// subscriptions by the user_feeds trigger.
+func (r *APIPopRepository) DeleteUser(userID uuid.UUID) (err error) {
+	if r == nil || r.pop == nil {
+		return errors.New("invalid repository")
+	}
+
+	return r.pop.Transaction(func(tx *pop.Connection) (err error) {
+		user := models.User{}
+		if err = tx.Select("id").Find(&user, userID); err != nil {
+			return errors.New("user doesn't exist")
+		}
+
+		if err = tx.RawQuery("DELETE FROM user_states WHERE user_id = ?", userID).Exec(); err != nil {
+			return
+		}
+		if err = tx.RawQuery("DELETE FROM user_feeds WHERE user_id = ?", userID).Exec(); err != nil {
+			return
+		}
+
+		return tx.Destroy(&user)
+	})
+}
+
+// feedSubscriberCount is used to query the subscriber count of a feed
+type feedSubscriberCount struct {
+	FeedID          uuid.UUID `db:"feed_id"`
+	SubscriberCount int       `db:"subscriber_count"`
+}
+
+// FeedHealth returns all feeds with their fetcher state, optionally only the ones that don't work
+func (r *APIPopRepository) FeedHealth(brokenOnly bool) (ret []controller.AdminFeed, err error) {
+	if r == nil || r.pop == nil {
+		err = errors.New("invalid repository")
+		return
+	}
+
+	feeds := models.Feeds{}
+	err = r.pop.Select("id", "title", "feed_url", "fetched_at", "fetcher_state").Order("feed_url").All(&feeds)
+	if err != nil {
+		return
+	}
+
+	counts := []feedSubscriberCount{}
+	err = r.pop.RawQuery("SELECT feed_id, COUNT(*) AS subscriber_count FROM user_feeds GROUP BY feed_id").All(&counts)
+	if err != nil {
+		return
+	}
+	subscriberCounts := make(map[uuid.UUID]int, len(counts))
+	for _, count := range counts {
+		subscriberCounts[count.FeedID] = count.SubscriberCount
+	}
+
+	ret = []controller.AdminFeed{}
+	for _, feed := range feeds {
+		state := feed.FetcherState
+		if brokenOnly && state.Working && !state.Dead {
+			continue
+		}
+
+		ret = append(ret, controller.AdminFeed{
+			ID:              feed.ID,
+			Title:           feed.Title.String,
+			URL:             feed.FeedURL,
+			SubscriberCount: subscriberCounts[feed.ID],
+			FetchedAt:       feed.FetchedAt,
+			FetcherState: controller.AdminFetcherState{
+				FetcherState: controller.FetcherState{
+					Dead:        state.Dead,
+					Working:     state.Working,
+					LastSuccess: state.LastSuccess,
+					LastError:   state.LastError,
+					Message:     state.Message,
+				},
+				FailureCount: state.FailureCount,
+				BackoffUntil: state.BackoffUntil,
+			},
+		})
+	}
+	return
+}
+
+// ForceRefreshFeed asks the worker to fetch any feed as soon as possible
+func (r *APIPopRepository) ForceRefreshFeed(claims *helpers.AuthClaims, feedID uuid.UUID) (err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
+	return r.pop.Transaction(func(tx *pop.Connection) (err error) {
+		feed := models.Feed{}
+		if err = tx.Select("id").Find(&feed, feedID); err != nil {
+			return errors.New("feed doesn't exist")
+		}
+		return requestFeedRefresh(tx, feedID, claims.ID)
+	})
+}
+
+// ResetFeed clears the failures and backoff of a feed and asks the worker to fetch it as soon as possible
+func (r *APIPopRepository) ResetFeed(claims *helpers.AuthClaims, feedID uuid.UUID) (err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
+	return r.pop.Transaction(func(tx *pop.Connection) (err error) {
+		feed := models.Feed{}
+		if err = tx.Select("id", "fetcher_state").Find(&feed, feedID); err != nil {
+			return errors.New("feed doesn't exist")
+		}
+
+		feed.FetcherState.FailureCount = 0
+		feed.FetcherState.BackoffUntil = time.Time{}
+		feed.FetcherState.Dead = false
+		feed.FetcherState.Message = ""
+		if err = tx.UpdateColumns(&feed, "fetcher_state", "updated_at"); err != nil {
+			return
+		}
+
+		// the scheduler keeps the fetcher state of queued feeds in memory, so it has to reload them
+		if err = notifyFeedUpdate(tx, feedID); err != nil {
+			return
+		}
+
+		return requestFeedRefresh(tx, feedID, claims.ID)
+	})
+}
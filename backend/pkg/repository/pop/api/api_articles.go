@@ -0,0 +1,115 @@
+package api
+
+import (
+	"github.com/apex/log"
+	"github.com/gofrs/uuid"
+	"github.com/spezifisch/rueder3/backend/pkg/api/controller"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+	"github.com/spezifisch/rueder3/backend/pkg/repository/pop/models"
+)
+
+// articlePreviewColumns selects everything needed for toArticlePreviews.
+// The articles come from arbitrary feeds so feed_seq can't be calculated with one window function.
+const articlePreviewColumns = `articles.id, articles.seq, articles.feed_id, articles.posted_at, articles.title, articles.teaser,
+	(SELECT COUNT(*) FROM articles AS fa WHERE fa.feed_id = articles.feed_id AND fa.seq <= articles.seq) AS feed_seq`
+
+// searchCondition matches the query against the search vector that postgres generates from title, teaser and content
+const searchCondition = "articles.search_vector @@ websearch_to_tsquery('simple', ?)"
+
+// toArticlePreviews converts articles of arbitrary feeds, fetching the feed info with one query
+func (r *APIPopRepository) toArticlePreviews(articles models.Articles) (ret []controller.ArticlePreview, err error) {
+	ret = make([]controller.ArticlePreview, len(articles))
+	if len(articles) == 0 {
+		return
+	}
+
+	feedIDs := make([]uuid.UUID, 0, len(articles))
+	seenFeeds := make(map[uuid.UUID]bool)
+	for _, article := range articles {
+		if !seenFeeds[article.FeedID] {
+			seenFeeds[article.FeedID] = true
+			feedIDs = append(feedIDs, article.FeedID)
+		}
+	}
+
+	feeds := []models.Feed{}
+	if err = r.pop.Select("id", "title", "icon").Where("id in (?)", feedIDs).All(&feeds); err != nil {
+		log.WithError(err).Error("failed fetching feeds of articles")
+		return
+	}
+	feedsByID := make(map[uuid.UUID]*models.Feed, len(feeds))
+	for i := range feeds {
+		feedsByID[feeds[i].ID] = &feeds[i]
+	}
+
+	for i, article := range articles {
+		ret[i].ID = article.ID
+		ret[i].Seq = article.Seq
+		ret[i].FeedSeq = article.FeedSeq
+		ret[i].Time = article.PostedAt
+		ret[i].Title = article.Title.String
+		ret[i].Teaser = article.Teaser.String
+		if feed, ok := feedsByID[article.FeedID]; ok {
+			ret[i].FeedTitle = feed.Title.String
+			ret[i].FeedIcon = feed.Icon.String
+		}
+	}
+	return
+}
+
+// GetUserArticles returns the articles of the user's subscribed feeds, newest first.
+// If feedIDs is non-nil only articles of these feeds are returned.
+func (r *APIPopRepository) GetUserArticles(claims *helpers.AuthClaims, feedIDs []uuid.UUID, limit int, offset int) (ret []controller.ArticlePreview, err error) {
+	return r.subscribedArticles(claims, feedIDs, "", nil, limit, offset)
+}
+
+// SearchArticles returns the articles of the user's subscribed feeds that match the search query, newest first.
+// If feedIDs is non-nil the search is limited to these feeds.
+func (r *APIPopRepository) SearchArticles(claims *helpers.AuthClaims, query string, feedIDs []uuid.UUID, limit int, offset int) (ret []controller.ArticlePreview, err error) {
+	return r.subscribedArticles(claims, feedIDs, searchCondition, []interface{}{query}, limit, offset)
+}
+
+// subscribedArticles returns articles of the user's subscribed feeds with seq paging.
+// The optional condition is added to the WHERE clause.
+func (r *APIPopRepository) subscribedArticles(claims *helpers.AuthClaims, feedIDs []uuid.UUID, condition string, conditionArgs []interface{}, limit int, offset int) (ret []controller.ArticlePreview, err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+	if feedIDs != nil && len(feedIDs) == 0 {
+		// empty folder
+		ret = make([]controller.ArticlePreview, 0)
+		return
+	}
+
+	sql, args := subscribedArticlesQuery(claims.ID, feedIDs, condition, conditionArgs, limit, offset)
+	articles := models.Articles{}
+	if err = r.pop.RawQuery(sql, args...).All(&articles); err != nil {
+		log.WithError(err).Error("failed fetching articles of subscribed feeds")
+		return
+	}
+
+	return r.toArticlePreviews(articles)
+}
+
+// subscribedArticlesQuery builds the query for subscribedArticles
+func subscribedArticlesQuery(userID uuid.UUID, feedIDs []uuid.UUID, condition string, conditionArgs []interface{}, limit int, offset int) (sql string, args []interface{}) {
+	sql = "SELECT " + articlePreviewColumns + ` FROM articles
+		JOIN user_feeds ON user_feeds.feed_id = articles.feed_id AND user_feeds.user_id = ?
+		WHERE TRUE`
+	args = []interface{}{userID}
+	if condition != "" {
+		sql += " AND " + condition
+		args = append(args, conditionArgs...)
+	}
+	if feedIDs != nil {
+		sql += " AND articles.feed_id IN (?)"
+		args = append(args, feedIDs)
+	}
+	if offset > 0 {
+		sql += " AND articles.seq < ?"
+		args = append(args, offset)
+	}
+	sql += " ORDER BY articles.seq DESC LIMIT ?"
+	args = append(args, limit)
+	return
+}
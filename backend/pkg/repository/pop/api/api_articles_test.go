@@ -0,0 +1,62 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+	"github.com/spezifisch/rueder3/backend/pkg/repository/pop/models"
+)
+
+func Test_subscribedArticlesQuery(t *testing.T) {
+	feedID := uuid.Must(uuid.NewV4())
+
+	sql, args := subscribedArticlesQuery(testUserID, nil, "", nil, 10, 0)
+	assert.NotContains(t, sql, "search_vector")
+	assert.Equal(t, []interface{}{testUserID, 10}, args)
+
+	// the search is limited to the feeds and paged like the article list
+	sql, args = subscribedArticlesQuery(testUserID, []uuid.UUID{feedID}, searchCondition, []interface{}{"rueder -spam"}, 10, 42)
+	assert.Contains(t, sql, "AND "+searchCondition+" AND articles.feed_id IN (?) AND articles.seq < ?")
+	assert.Equal(t, []interface{}{testUserID, "rueder -spam", []uuid.UUID{feedID}, 42, 10}, args)
+	assert.Equal(t, len(args), strings.Count(sql, "?"))
+
+	// the search vector is never selected
+	assert.NotContains(t, strings.SplitN(sql, "FROM articles", 2)[0], "search_vector")
+}
+
+func TestAPI_SearchArticlesInvalid(t *testing.T) {
+	// fails before touching the database
+	r := &APIPopRepository{pop: &pop.Connection{}}
+	_, err := r.SearchArticles(nil, "rueder", nil, 10, 0)
+	assert.Error(t, err)
+	_, err = r.SearchArticles(&helpers.AuthClaims{}, "rueder", nil, 10, 0)
+	assert.Error(t, err)
+
+	// an empty folder has nothing to search
+	claims := &helpers.AuthClaims{ID: testUserID, Origin: "somewhere", Name: "someone", OriginName: "somewhere:someone"}
+	articles, err := r.SearchArticles(claims, "rueder", []uuid.UUID{}, 10, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, articles)
+}
+
+func Test_articleColumns(t *testing.T) {
+	// articleColumns selects all stored columns of models.Article, but not the search vector
+	columns := []string{}
+	articleType := reflect.TypeOf(models.Article{})
+	for i := 0; i < articleType.NumField(); i++ {
+		field := articleType.Field(i)
+		column := field.Tag.Get("db")
+		if column == "" || column == "-" || column == "feed_seq" {
+			continue
+		}
+		columns = append(columns, "articles."+column)
+	}
+	assert.Equal(t, strings.Join(columns, ", "), articleColumns)
+	assert.NotContains(t, articleColumns, "search_vector")
+}
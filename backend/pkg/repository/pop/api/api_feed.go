@@ -0,0 +1,132 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/spezifisch/rueder3/backend/pkg/api/controller"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+	"github.com/spezifisch/rueder3/backend/pkg/repository/pop/models"
+)
+
+// ChangeFeed changes the user's custom title and fetch preferences of a subscribed feed
+func (r *APIPopRepository) ChangeFeed(claims *helpers.AuthClaims, feedID uuid.UUID, changes controller.FeedChanges) (err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
+	err = r.pop.Transaction(func(tx *pop.Connection) (err error) {
+		if _, err = r.subscribedFeedIDs(tx, claims.ID, []uuid.UUID{feedID}); err != nil {
+			return
+		}
+
+		if changes.Title != nil {
+			if err = changeFolderFeedTitle(tx, claims.ID, feedID, *changes.Title); err != nil {
+				return
+			}
+		}
+		if changes.FetchFullArticle != nil {
+			err = tx.RawQuery("UPDATE user_feeds SET fetch_full_article = ? WHERE user_id = ? AND feed_id = ?",
+				*changes.FetchFullArticle, claims.ID, feedID).Exec()
+			if err != nil {
+				return
+			}
+			// the scheduler keeps the queued feeds in memory, so it has to reload them
+			err = notifyFeedUpdate(tx, feedID)
+		}
+		return
+	})
+	return
+}
+
+// changeFolderFeedTitle sets the title of the feed in the user's folders. An empty title resets it to the feed's title.
+func changeFolderFeedTitle(tx *pop.Connection, userID uuid.UUID, feedID uuid.UUID, title string) (err error) {
+	if title == "" {
+		feed := models.Feed{}
+		if err = tx.Select("id", "title").Find(&feed, feedID); err != nil {
+			return errors.New("feed doesn't exist")
+		}
+		title = feed.Title.String
+	}
+
+	user := models.User{}
+	if err = tx.Find(&user, userID); err != nil {
+		return errors.New("user doesn't exist")
+	}
+
+	found := false
+	for i := range user.Folders {
+		for j := range user.Folders[i].Feeds {
+			if user.Folders[i].Feeds[j].ID == feedID {
+				user.Folders[i].Feeds[j].Title = title
+				found = true
+			}
+		}
+	}
+	if !found {
+		return errors.New("feed not in folders")
+	}
+
+	return tx.UpdateColumns(&user, "folders")
+}
+
+// DeleteFeed deletes the feed and unsubscribes all users. Articles, their label assignments and tombstones are
+// removed by the foreign key cascades and the scheduler is notified by the feeds_notify_event trigger.
+func (r *APIPopRepository) DeleteFeed(feedID uuid.UUID) (subscriberIDs []uuid.UUID, err error) {
+	if r == nil || r.pop == nil {
+		err = errors.New("invalid repository")
+		return
+	}
+
+	err = r.pop.Transaction(func(tx *pop.Connection) (err error) {
+		feed := models.Feed{}
+		if err = tx.Select("id").Find(&feed, feedID); err != nil {
+			return errors.New("feed doesn't exist")
+		}
+
+		// remove the feed from the folders of its subscribers
+		users := models.Users{}
+		err = tx.Where("id IN (SELECT user_id FROM user_feeds WHERE feed_id = ?)", feedID).All(&users)
+		if err != nil {
+			return
+		}
+		for i := range users {
+			users[i].Folders = removeFolderFeed(users[i].Folders, feedID)
+			if err = tx.UpdateColumns(&users[i], "folders"); err != nil {
+				return
+			}
+			subscriberIDs = append(subscriberIDs, users[i].ID)
+		}
+
+		// user_feeds and the read state in user_states don't reference the feed with a foreign key
+		err = tx.RawQuery(`UPDATE user_states SET feed_states = feed_states - CAST(? AS text)
+			WHERE user_id IN (SELECT user_id FROM user_feeds WHERE feed_id = ?)`, feedID.String(), feedID).Exec()
+		if err != nil {
+			return
+		}
+		if err = tx.RawQuery("DELETE FROM user_feeds WHERE feed_id = ?", feedID).Exec(); err != nil {
+			return
+		}
+
+		return tx.Destroy(&feed)
+	})
+	if err != nil {
+		subscriberIDs = nil
+	}
+	return
+}
+
+// removeFolderFeed returns the folders without the feed
+func removeFolderFeed(folders models.Folders, feedID uuid.UUID) models.Folders {
+	for i := range folders {
+		feeds := folders[i].Feeds[:0]
+		for _, feed := range folders[i].Feeds {
+			if feed.ID != feedID {
+				feeds = append(feeds, feed)
+			}
+		}
+		folders[i].Feeds = feeds
+	}
+	return folders
+}
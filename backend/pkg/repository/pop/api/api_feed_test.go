@@ -0,0 +1,24 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/spezifisch/rueder3/backend/pkg/repository/pop/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_removeFolderFeed(t *testing.T) {
+	feedA := uuid.Must(uuid.NewV4())
+	feedB := uuid.Must(uuid.NewV4())
+	folders := models.Folders{
+		{Title: "one", Feeds: []models.LightFeed{{ID: feedA}, {ID: feedB}}},
+		{Title: "two", Feeds: []models.LightFeed{{ID: feedB}}},
+		{Title: "empty"},
+	}
+
+	got := removeFolderFeed(folders, feedB)
+	assert.Equal(t, []models.LightFeed{{ID: feedA}}, got[0].Feeds)
+	assert.Empty(t, got[1].Feeds)
+	assert.Empty(t, got[2].Feeds)
+}
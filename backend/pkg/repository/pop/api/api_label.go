@@ -0,0 +1,320 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/apex/log"
+	"github.com/gobuffalo/nulls"
+	"github.com/gofrs/uuid"
+	"github.com/spezifisch/rueder3/backend/pkg/api/controller"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+	"github.com/spezifisch/rueder3/backend/pkg/repository/pop/models"
+)
+
+// labelColorRegexp matches CSS hex colors like #abc or #aabbcc (optionally with alpha)
+var labelColorRegexp = regexp.MustCompile(`^#([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// labelResult is a label with the number of assigned articles
+type labelResult struct {
+	ID           uuid.UUID    `db:"id"`
+	Title        nulls.String `db:"title"`
+	Color        nulls.String `db:"color"`
+	ArticleCount int          `db:"article_count"`
+}
+
+// Labels returns all labels of the user
+func (r *APIPopRepository) Labels(claims *helpers.AuthClaims) (ret []controller.Label, err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
+	labels := []labelResult{}
+	err = r.pop.RawQuery(`SELECT labels.id, labels.title, labels.color, COUNT(article_labels.article_id) AS article_count
+		FROM labels LEFT JOIN article_labels ON article_labels.label_id = labels.id
+		WHERE labels.user_id = ? GROUP BY labels.id ORDER BY labels.title`, claims.ID).All(&labels)
+	if err != nil {
+		log.WithError(err).Error("failed fetching labels")
+		return
+	}
+
+	ret = make([]controller.Label, len(labels))
+	for i, label := range labels {
+		ret[i] = controller.Label{
+			ID:           label.ID,
+			Name:         label.Title.String,
+			Color:        label.Color.String,
+			ArticleCount: label.ArticleCount,
+		}
+	}
+	return
+}
+
+// AddLabel adds a new label for the user
+func (r *APIPopRepository) AddLabel(claims *helpers.AuthClaims, name string, color string) (ret controller.Label, err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+	if name, err = validateLabel(name, color); err != nil {
+		return
+	}
+
+	labelCount, err := r.pop.Where("user_id = ?", claims.ID).Count(&models.Label{})
+	if err != nil {
+		return
+	}
+	if labelCount >= r.labelCountLimit {
+		err = fmt.Errorf("label limit of %d reached", r.labelCountLimit)
+		return
+	}
+	if err = r.checkLabelNameUnused(claims.ID, uuid.Nil, name); err != nil {
+		return
+	}
+
+	label := models.Label{
+		UserID: claims.ID,
+		Title:  nulls.NewString(name),
+		Color:  nulls.NewString(color),
+	}
+	if err = r.pop.Create(&label); err != nil {
+		log.WithError(err).Error("failed creating label")
+		return
+	}
+
+	ret = controller.Label{
+		ID:    label.ID,
+		Name:  name,
+		Color: color,
+	}
+	return
+}
+
+// ChangeLabel changes name and color of the user's label
+func (r *APIPopRepository) ChangeLabel(claims *helpers.AuthClaims, labelID uuid.UUID, name string, color string) (ret controller.Label, err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+	if name, err = validateLabel(name, color); err != nil {
+		return
+	}
+
+	label, err := r.findLabel(claims.ID, labelID)
+	if err != nil {
+		return
+	}
+	if err = r.checkLabelNameUnused(claims.ID, labelID, name); err != nil {
+		return
+	}
+
+	label.Title = nulls.NewString(name)
+	label.Color = nulls.NewString(color)
+	if err = r.pop.UpdateColumns(&label, "title", "color", "updated_at"); err != nil {
+		log.WithError(err).Error("failed updating label")
+		return
+	}
+
+	articleCount, err := r.pop.Where("label_id = ?", labelID).Count(&models.ArticleLabel{})
+	if err != nil {
+		return
+	}
+
+	ret = controller.Label{
+		ID:           label.ID,
+		Name:         name,
+		Color:        color,
+		ArticleCount: articleCount,
+	}
+	return
+}
+
+// DeleteLabel deletes the user's label. The articles are only unassigned.
+func (r *APIPopRepository) DeleteLabel(claims *helpers.AuthClaims, labelID uuid.UUID) (err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
+	label, err := r.findLabel(claims.ID, labelID)
+	if err != nil {
+		return
+	}
+
+	// assignments are removed by the foreign key cascade
+	return r.pop.Destroy(&label)
+}
+
+// AssignLabel assigns the user's label to an article
+func (r *APIPopRepository) AssignLabel(claims *helpers.AuthClaims, labelID uuid.UUID, articleID uuid.UUID) (err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
+	if _, err = r.findLabel(claims.ID, labelID); err != nil {
+		return
+	}
+	article := models.Article{}
+	if err = r.pop.Select("id").Find(&article, articleID); err != nil {
+		err = errors.New("article doesn't exist")
+		return
+	}
+
+	now := time.Now().UTC()
+	return r.pop.RawQuery(`INSERT INTO article_labels (created_at, updated_at, label_id, article_id)
+		VALUES (?, ?, ?, ?) ON CONFLICT (label_id, article_id) DO NOTHING`, now, now, labelID, articleID).Exec()
+}
+
+// UnassignLabel removes the user's label from an article
+func (r *APIPopRepository) UnassignLabel(claims *helpers.AuthClaims, labelID uuid.UUID, articleID uuid.UUID) (err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
+	if _, err = r.findLabel(claims.ID, labelID); err != nil {
+		return
+	}
+
+	return r.pop.RawQuery("DELETE FROM article_labels WHERE label_id = ? AND article_id = ?", labelID, articleID).Exec()
+}
+
+// GetLabelArticles returns the articles with the user's label, newest first
+func (r *APIPopRepository) GetLabelArticles(claims *helpers.AuthClaims, labelID uuid.UUID, limit int, offset int) (ret []controller.ArticlePreview, err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
+	if _, err = r.findLabel(claims.ID, labelID); err != nil {
+		return
+	}
+
+	query := "SELECT " + articlePreviewColumns + ` FROM articles
+		JOIN article_labels ON article_labels.article_id = articles.id
+		WHERE article_labels.label_id = ?`
+	args := []interface{}{labelID}
+	if offset > 0 {
+		query += " AND articles.seq < ?"
+		args = append(args, offset)
+	}
+	query += " ORDER BY articles.seq DESC LIMIT ?"
+	args = append(args, limit)
+
+	articles := models.Articles{}
+	if err = r.pop.RawQuery(query, args...).All(&articles); err != nil {
+		log.WithError(err).Error("failed fetching label articles")
+		return
+	}
+
+	return r.toArticlePreviews(articles)
+}
+
+// findLabel returns the label if it belongs to the user
+func (r *APIPopRepository) findLabel(userID uuid.UUID, labelID uuid.UUID) (label models.Label, err error) {
+	err = r.pop.Where("user_id = ?", userID).Find(&label, labelID)
+	if err != nil {
+		err = errors.New("label doesn't exist")
+	}
+	return
+}
+
+// checkLabelNameUnused returns an error if the user has another label with this name
+func (r *APIPopRepository) checkLabelNameUnused(userID uuid.UUID, labelID uuid.UUID, name string) error {
+	exists, err := r.pop.Where("user_id = ?", userID).Where("title = ?", name).Where("id != ?", labelID).Exists(&models.Label{})
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.New("label already exists")
+	}
+	return nil
+}
+
+// validateLabel checks name and color of a label and returns the cleaned up name
+func validateLabel(name string, color string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return name, errors.New("label name is empty")
+	}
+	if utf8.RuneCountInString(name) > 1024 {
+		return name, errors.New("label name is too long")
+	}
+	if color != "" && !labelColorRegexp.MatchString(color) {
+		return name, errors.New("invalid label color")
+	}
+	return name, nil
+}
+
+// articleLabelResult is a label assignment of the user
+type articleLabelResult struct {
+	ArticleID uuid.UUID `db:"article_id"`
+	LabelID   uuid.UUID `db:"label_id"`
+}
+
+// LabelledArticles returns all articles with at least one of the user's labels, including their content
+func (r *APIPopRepository) LabelledArticles(claims *helpers.AuthClaims) (ret []controller.LabelledArticle, err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
+	assignments := []articleLabelResult{}
+	err = r.pop.RawQuery(`SELECT article_labels.article_id, article_labels.label_id FROM article_labels
+		JOIN labels ON labels.id = article_labels.label_id
+		WHERE labels.user_id = ?`, claims.ID).All(&assignments)
+	if err != nil {
+		log.WithError(err).Error("failed fetching label assignments")
+		return
+	}
+	ret = []controller.LabelledArticle{}
+	if len(assignments) == 0 {
+		return
+	}
+
+	labelIDs := make(map[uuid.UUID][]uuid.UUID)
+	articleIDs := []uuid.UUID{}
+	for _, assignment := range assignments {
+		if _, ok := labelIDs[assignment.ArticleID]; !ok {
+			articleIDs = append(articleIDs, assignment.ArticleID)
+		}
+		labelIDs[assignment.ArticleID] = append(labelIDs[assignment.ArticleID], assignment.LabelID)
+	}
+
+	articles := models.Articles{}
+	err = r.pop.Select("id", "feed_id", "posted_at", "link", "thumbnail", "image", "image_title", "title", "teaser", "content").
+		Where("id in (?)", articleIDs).Order("seq").All(&articles)
+	if err != nil {
+		log.WithError(err).Error("failed fetching labelled articles")
+		return
+	}
+
+	// fetch the feed info with one query
+	feedIDs := []uuid.UUID{}
+	seenFeeds := make(map[uuid.UUID]bool)
+	for _, article := range articles {
+		if !seenFeeds[article.FeedID] {
+			seenFeeds[article.FeedID] = true
+			feedIDs = append(feedIDs, article.FeedID)
+		}
+	}
+	feeds := models.Feeds{}
+	if err = r.pop.Select("id", "title", "feed_url").Where("id in (?)", feedIDs).All(&feeds); err != nil {
+		log.WithError(err).Error("failed fetching feeds of labelled articles")
+		return
+	}
+	feedsByID := make(map[uuid.UUID]*models.Feed, len(feeds))
+	for i := range feeds {
+		feedsByID[feeds[i].ID] = &feeds[i]
+	}
+
+	for i := range articles {
+		feed, ok := feedsByID[articles[i].FeedID]
+		if !ok {
+			feed = &models.Feed{ID: articles[i].FeedID}
+		}
+		ret = append(ret, controller.LabelledArticle{
+			Article:  toArticle(&articles[i], feed),
+			LabelIDs: labelIDs[articles[i].ID],
+		})
+	}
+	return
+}
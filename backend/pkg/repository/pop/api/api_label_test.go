@@ -0,0 +1,38 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		label    string
+		color    string
+		wantName string
+		wantErr  bool
+	}{
+		{"valid", "important", "#d0f806", "important", false},
+		{"short color", "favorite", "#F59", "favorite", false},
+		{"no color", "read later", "", "read later", false},
+		{"trimmed", "  spaces ", "#10B981", "spaces", false},
+		{"empty name", "   ", "#10B981", "", true},
+		{"long name", strings.Repeat("x", 1025), "", "", true},
+		{"color name", "red", "red", "", true},
+		{"color injection", "red", "#fff;background:url(x)", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateLabel(tt.label, tt.color)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantName, got)
+		})
+	}
+}
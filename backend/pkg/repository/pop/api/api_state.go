@@ -0,0 +1,306 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/spezifisch/rueder3/backend/pkg/api/controller"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+	"github.com/spezifisch/rueder3/backend/pkg/repository/pop/models"
+)
+
+// seqResult is used to select a single seq value
+type seqResult struct {
+	Seq int `db:"seq"`
+}
+
+// UserState returns the read state of all feeds of the user
+func (r *APIPopRepository) UserState(claims *helpers.AuthClaims) (ret controller.UserState, err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
+	states := []models.UserState{}
+	err = r.pop.Where("user_id = ?", claims.ID).All(&states)
+	if err != nil {
+		return
+	}
+
+	ret.FeedStates = make(map[uuid.UUID]controller.UserFeedState)
+	if len(states) == 0 {
+		// nothing read yet
+		return
+	}
+	for feedID, feedState := range states[0].FeedStates {
+		ret.FeedStates[feedID] = toControllerUserFeedState(&feedState)
+	}
+	return
+}
+
+// FolderFeedIDs returns the ids of all feeds in the user's folder
+func (r *APIPopRepository) FolderFeedIDs(claims *helpers.AuthClaims, folderID uuid.UUID) (feedIDs []uuid.UUID, err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+
+	user := models.User{}
+	err = r.pop.Select("id", "folders").Find(&user, claims.ID)
+	if err != nil {
+		err = errors.New("user doesn't exist")
+		return
+	}
+
+	for _, folder := range user.Folders {
+		if !helpers.IsSameUUID(folder.ID, folderID) {
+			continue
+		}
+
+		feedIDs = make([]uuid.UUID, len(folder.Feeds))
+		for i, feed := range folder.Feeds {
+			feedIDs[i] = feed.ID
+		}
+		return
+	}
+
+	err = errors.New("folder doesn't exist")
+	return
+}
+
+// MarkArticles marks the articles with the given seqs as read or unread.
+// Only articles of the given feeds are considered. The changed feed states are returned.
+func (r *APIPopRepository) MarkArticles(claims *helpers.AuthClaims, feedIDs []uuid.UUID, seqs []int, read bool) (ret controller.UserState, err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+	if len(seqs) == 0 {
+		err = errors.New("no articles given")
+		return
+	}
+	if len(seqs) > r.markArticlesLimit {
+		err = errors.New("too many articles")
+		return
+	}
+
+	ret.FeedStates = make(map[uuid.UUID]controller.UserFeedState)
+	err = r.pop.Transaction(func(tx *pop.Connection) (err error) {
+		subscribedIDs, err := r.subscribedFeedIDs(tx, claims.ID, feedIDs)
+		if err != nil {
+			return
+		}
+
+		// find out which feeds the articles belong to
+		articles := []models.Article{}
+		err = tx.Select("seq", "feed_id").Where("seq in (?)", seqs).Where("feed_id in (?)", subscribedIDs).All(&articles)
+		if err != nil {
+			return
+		}
+		if len(articles) == 0 {
+			return errors.New("articles not found")
+		}
+		feedSeqs := make(map[uuid.UUID][]int)
+		for _, article := range articles {
+			feedSeqs[article.FeedID] = append(feedSeqs[article.FeedID], article.Seq)
+		}
+
+		state, err := r.lockUserState(tx, claims.ID)
+		if err != nil {
+			return
+		}
+
+		for feedID, articleSeqs := range feedSeqs {
+			feedState := state.FeedStates[feedID]
+			feedState.FeedID = feedID
+
+			if read {
+				feedState.MarkRead(articleSeqs)
+				if err = r.compactUserFeedState(tx, &feedState); err != nil {
+					return
+				}
+			} else {
+				var coveredSeqs []int
+				if coveredSeqs, err = r.coveredSeqs(tx, &feedState, articleSeqs); err != nil {
+					return
+				}
+				feedState.MarkUnread(articleSeqs, coveredSeqs)
+			}
+
+			state.FeedStates[feedID] = feedState
+			ret.FeedStates[feedID] = toControllerUserFeedState(&feedState)
+		}
+
+		return tx.UpdateColumns(&state, "feed_states", "updated_at")
+	})
+	return
+}
+
+// MarkAllRead marks all articles of the given feeds up to untilSeq as read.
+// If untilSeq is 0 all currently existing articles are marked. The changed feed states are returned.
+func (r *APIPopRepository) MarkAllRead(claims *helpers.AuthClaims, feedIDs []uuid.UUID, untilSeq int) (ret controller.UserState, err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+	if untilSeq < 0 {
+		err = errors.New("invalid seq")
+		return
+	}
+
+	ret.FeedStates = make(map[uuid.UUID]controller.UserFeedState)
+	if len(feedIDs) == 0 {
+		// empty folder
+		return
+	}
+
+	err = r.pop.Transaction(func(tx *pop.Connection) (err error) {
+		subscribedIDs, err := r.subscribedFeedIDs(tx, claims.ID, feedIDs)
+		if err != nil {
+			return
+		}
+
+		if untilSeq == 0 {
+			// seqs are global, so the newest article of all feeds is good enough for each feed
+			newest := seqResult{}
+			err = tx.RawQuery("SELECT COALESCE(MAX(seq), 0) AS seq FROM articles WHERE feed_id IN (?)", subscribedIDs).First(&newest)
+			if err != nil {
+				return
+			}
+			untilSeq = newest.Seq
+		}
+
+		state, err := r.lockUserState(tx, claims.ID)
+		if err != nil {
+			return
+		}
+
+		for _, feedID := range subscribedIDs {
+			feedState := state.FeedStates[feedID]
+			feedState.FeedID = feedID
+			feedState.MarkAllRead(untilSeq)
+
+			state.FeedStates[feedID] = feedState
+			ret.FeedStates[feedID] = toControllerUserFeedState(&feedState)
+		}
+
+		return tx.UpdateColumns(&state, "feed_states", "updated_at")
+	})
+	return
+}
+
+func (r *APIPopRepository) checkRepositoryAndClaims(claims *helpers.AuthClaims) error {
+	if r == nil || r.pop == nil {
+		return errors.New("invalid repository")
+	}
+	// check login
+	if claims == nil || !claims.IsValid() {
+		return errors.New("invalid claims")
+	}
+	return nil
+}
+
+// subscribedFeedIDs returns the subset of the given feeds that the user is subscribed to
+func (r *APIPopRepository) subscribedFeedIDs(tx *pop.Connection, userID uuid.UUID, feedIDs []uuid.UUID) (ret []uuid.UUID, err error) {
+	if len(feedIDs) == 0 {
+		err = errors.New("no feeds given")
+		return
+	}
+
+	userFeeds := []models.UserFeed{}
+	err = tx.Where("user_id = ?", userID).Where("feed_id in (?)", feedIDs).All(&userFeeds)
+	if err != nil {
+		return
+	}
+	if len(userFeeds) == 0 {
+		err = errors.New("not subscribed to feed")
+		return
+	}
+
+	ret = make([]uuid.UUID, len(userFeeds))
+	for i, uf := range userFeeds {
+		ret[i] = uf.FeedID
+	}
+	return
+}
+
+// lockUserState returns the user's state row and locks it until the end of the transaction.
+// The row is created if it doesn't exist yet.
+func (r *APIPopRepository) lockUserState(tx *pop.Connection, userID uuid.UUID) (state models.UserState, err error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return
+	}
+	now := time.Now().UTC()
+	err = tx.RawQuery(`INSERT INTO user_states (id, created_at, updated_at, user_id, feed_states)
+		VALUES (?, ?, ?, ?, '{}') ON CONFLICT (user_id) DO NOTHING`, id, now, now, userID).Exec()
+	if err != nil {
+		return state, fmt.Errorf("creating user state failed: %s", err)
+	}
+
+	err = tx.RawQuery("SELECT * FROM user_states WHERE user_id = ? FOR UPDATE", userID).First(&state)
+	if err != nil {
+		return
+	}
+	if state.FeedStates == nil {
+		state.FeedStates = make(models.UserFeedStates)
+	}
+	return
+}
+
+// compactUserFeedState moves ReadAllUntil forward to the oldest unread article of the feed,
+// so the list of read articles doesn't grow forever
+func (r *APIPopRepository) compactUserFeedState(tx *pop.Connection, feedState *models.UserFeedState) (err error) {
+	if len(feedState.ReadArticles) == 0 {
+		return
+	}
+
+	oldestUnread := seqResult{}
+	err = tx.RawQuery("SELECT COALESCE(MIN(seq), 0) AS seq FROM articles WHERE feed_id = ? AND seq > ? AND seq NOT IN (?)",
+		feedState.FeedID, feedState.ReadAllUntil, feedState.ReadArticles).First(&oldestUnread)
+	if err != nil {
+		return
+	}
+
+	if oldestUnread.Seq == 0 {
+		// everything is read
+		newest := feedState.ReadArticles[len(feedState.ReadArticles)-1]
+		feedState.MarkAllRead(newest)
+		return
+	}
+
+	feedState.MarkAllRead(oldestUnread.Seq - 1)
+	return
+}
+
+// coveredSeqs returns the seqs of all articles of the feed that are newer than the oldest given seq
+// and implicitly read by ReadAllUntil
+func (r *APIPopRepository) coveredSeqs(tx *pop.Connection, feedState *models.UserFeedState, seqs []int) (ret []int, err error) {
+	oldest := seqs[0]
+	for _, seq := range seqs {
+		if seq < oldest {
+			oldest = seq
+		}
+	}
+	if oldest > feedState.ReadAllUntil {
+		return
+	}
+
+	articles := []models.Article{}
+	err = tx.Select("seq").Where("feed_id = ?", feedState.FeedID).Where("seq > ?", oldest).Where("seq <= ?", feedState.ReadAllUntil).All(&articles)
+	if err != nil {
+		return
+	}
+
+	ret = make([]int, len(articles))
+	for i, article := range articles {
+		ret[i] = article.Seq
+	}
+	return
+}
+
+func toControllerUserFeedState(feedState *models.UserFeedState) controller.UserFeedState {
+	return controller.UserFeedState{
+		ReadAllUntil: feedState.ReadAllUntil,
+		ReadArticles: feedState.ReadArticles,
+	}
+}
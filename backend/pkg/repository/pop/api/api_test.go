@@ -8,6 +8,7 @@ import (
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
 	"github.com/spezifisch/rueder3/backend/pkg/api/controller"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,6 +25,13 @@ func TestAPI_Feeds(t *testing.T) {
 	testPop, err := pop.Connect("test")
 	assert.NoError(t, err)
 
+	mockClaims := helpers.AuthClaims{
+		ID:         testUserID,
+		Origin:     "somewhere",
+		Name:       "someone",
+		OriginName: "somewhere:someone",
+	}
+
 	type fields struct {
 		pop                  *pop.Connection
 		folderCountLimit     int
@@ -32,6 +40,7 @@ func TestAPI_Feeds(t *testing.T) {
 	tests := []struct {
 		name      string
 		fields    fields
+		claims    *helpers.AuthClaims
 		wantFeeds []controller.Feed
 		wantErr   bool
 	}{
@@ -40,9 +49,19 @@ func TestAPI_Feeds(t *testing.T) {
 			fields: fields{
 				pop: testPop,
 			},
+			claims:    &mockClaims,
 			wantFeeds: []controller.Feed{},
 			wantErr:   false,
 		},
+		{
+			name: "claims nil",
+			fields: fields{
+				pop: testPop,
+			},
+			claims:    nil,
+			wantFeeds: nil,
+			wantErr:   true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -51,7 +70,7 @@ func TestAPI_Feeds(t *testing.T) {
 				folderCountLimit:     tt.fields.folderCountLimit,
 				folderFeedCountLimit: tt.fields.folderFeedCountLimit,
 			}
-			gotFeeds, err := r.Feeds()
+			gotFeeds, err := r.Feeds(tt.claims)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("APIPopRepository.Feeds() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gofrs/uuid"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+)
+
+// apiTokenUser is the user a personal API token belongs to
+type apiTokenUser struct {
+	ID          uuid.UUID `db:"id"`
+	AuthOrigin  string    `db:"auth_origin"`
+	AuthSubject string    `db:"auth_subject"`
+}
+
+// ValidateAPIToken returns the claims of the user the personal API token belongs to.
+// API tokens always get the user role, admins have to log in to use the admin API.
+func (r *APIPopRepository) ValidateAPIToken(token string) (claims *helpers.AuthClaims, err error) {
+	if r == nil || r.pop == nil {
+		err = errors.New("invalid repository")
+		return
+	}
+
+	users := []apiTokenUser{}
+	err = r.pop.RawQuery(`SELECT users.id, users.auth_origin, users.auth_subject FROM api_tokens
+		JOIN users ON users.id = api_tokens.user_id
+		WHERE api_tokens.token_hash = ?`, helpers.HashAPIToken(token)).All(&users)
+	if err != nil {
+		return
+	}
+	if len(users) != 1 {
+		err = errors.New("invalid API token")
+		return
+	}
+
+	user := users[0]
+	claims = &helpers.AuthClaims{
+		ID:         user.ID,
+		Origin:     user.AuthOrigin,
+		Name:       user.AuthSubject,
+		OriginName: user.AuthOrigin + ":" + user.AuthSubject,
+		Role:       helpers.RoleUser,
+	}
+	return
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/apex/log"
 	mapset "github.com/deckarep/golang-set"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
 	"github.com/spezifisch/rueder3/backend/pkg/api/controller"
 	"github.com/spezifisch/rueder3/backend/pkg/helpers"
@@ -33,6 +35,18 @@ func (r *APIPopRepository) Folders(claims *helpers.AuthClaims) (ret []controller
 
 	folders := user.Folders
 
+	// get current info, article and unread counts of all feeds at once
+	var feedIDs []uuid.UUID
+	for _, folder := range folders {
+		for _, feed := range folder.Feeds {
+			feedIDs = append(feedIDs, feed.ID)
+		}
+	}
+	feedInfos, err := r.folderFeedInfos(claims.ID, feedIDs)
+	if err != nil {
+		return
+	}
+
 	// update folders struct if we change feed titles
 	updateFolders := false
 
@@ -49,24 +63,27 @@ func (r *APIPopRepository) Folders(claims *helpers.AuthClaims) (ret []controller
 			folderFeed.Title = feed.Title
 			folderFeed.Icon = feed.Icon
 
-			// get updated article count and other info
-			var currentFeed controller.Feed
-			currentFeed, err = r.GetFeed(feed.ID)
-			if err != nil {
-				return
+			currentFeed, ok := feedInfos[feed.ID]
+			if !ok {
+				log.WithField("feed_id", feed.ID).Warn("feed in folder doesn't exist")
+				continue
 			}
 
-			if folderFeed.Title == "" && currentFeed.Title != "" {
-				log.WithField("feed_id", folderFeed.ID).Infof("old feed title '%s', new title '%s'", folderFeed.Title, currentFeed.Title)
+			if folderFeed.Title == "" && currentFeed.Title.String != "" {
+				log.WithField("feed_id", folderFeed.ID).Infof("old feed title '%s', new title '%s'", folderFeed.Title, currentFeed.Title.String)
 
-				folderFeed.Title = currentFeed.Title
+				folderFeed.Title = currentFeed.Title.String
+				folders[i].Feeds[feedIdx].Title = currentFeed.Title.String
 				updateFolders = true
 			}
 
-			folderFeed.Icon = currentFeed.Icon
-			folderFeed.URL = currentFeed.URL
-			folderFeed.SiteURL = currentFeed.SiteURL
+			folderFeed.Icon = currentFeed.Icon.String
+			folderFeed.URL = currentFeed.FeedURL
+			folderFeed.SiteURL = currentFeed.SiteURL.String
 			folderFeed.ArticleCount = currentFeed.ArticleCount
+			folderFeed.UnreadCount = currentFeed.UnreadCount
+
+			ret[i].UnreadCount += currentFeed.UnreadCount
 		}
 	}
 
@@ -81,6 +98,48 @@ func (r *APIPopRepository) Folders(claims *helpers.AuthClaims) (ret []controller
 	return
 }
 
+// folderFeedInfo is a feed with its article count and the user's unread count
+type folderFeedInfo struct {
+	ID           uuid.UUID    `db:"id"`
+	Title        nulls.String `db:"title"`
+	Icon         nulls.String `db:"icon"`
+	FeedURL      string       `db:"feed_url"`
+	SiteURL      nulls.String `db:"site_url"`
+	ArticleCount int          `db:"article_count"`
+	UnreadCount  int          `db:"unread_count"`
+}
+
+// folderFeedInfos returns the feeds with their article and unread counts for the user in one query.
+// The unread count is calculated from the feed's state in user_states like UserFeedState.IsRead does.
+func (r *APIPopRepository) folderFeedInfos(userID uuid.UUID, feedIDs []uuid.UUID) (ret map[uuid.UUID]folderFeedInfo, err error) {
+	ret = make(map[uuid.UUID]folderFeedInfo, len(feedIDs))
+	if len(feedIDs) == 0 {
+		return
+	}
+
+	feeds := []folderFeedInfo{}
+	err = r.pop.RawQuery(`SELECT feeds.id, feeds.title, feeds.icon, feeds.feed_url, feeds.site_url,
+			COUNT(articles.id) AS article_count,
+			COUNT(articles.id) FILTER (WHERE
+				articles.seq > COALESCE((user_states.feed_states->(feeds.id::text)->>'read_all_until')::bigint, 0)
+				AND NOT COALESCE(user_states.feed_states->(feeds.id::text)->'read_articles' @> to_jsonb(articles.seq), FALSE)
+			) AS unread_count
+		FROM feeds
+		LEFT JOIN articles ON articles.feed_id = feeds.id
+		LEFT JOIN user_states ON user_states.user_id = ?
+		WHERE feeds.id IN (?)
+		GROUP BY feeds.id`, userID, feedIDs).All(&feeds)
+	if err != nil {
+		log.WithError(err).Error("failed fetching folder feeds")
+		return
+	}
+
+	for _, feed := range feeds {
+		ret[feed.ID] = feed
+	}
+	return
+}
+
 // ChangeFolders saves the folder structure for a user
 func (r *APIPopRepository) ChangeFolders(claims *helpers.AuthClaims, folders []controller.Folder) (err error) {
 	if r == nil || r.pop == nil {
@@ -98,9 +157,41 @@ func (r *APIPopRepository) ChangeFolders(claims *helpers.AuthClaims, folders []c
 		return
 	}
 
+	return r.changeFolders(r.pop, claims, folders)
+}
+
+// ImportFolders creates the new feeds and saves the folder structure that uses them in one transaction,
+// so that no feeds without subscribers are left behind if the folders are invalid
+func (r *APIPopRepository) ImportFolders(claims *helpers.AuthClaims, folders []controller.Folder, newFeeds []controller.NewFeed) (err error) {
+	if err = r.checkRepositoryAndClaims(claims); err != nil {
+		return
+	}
+	if folders == nil {
+		err = errors.New("invalid folders")
+		return
+	}
+
+	return r.pop.Transaction(func(tx *pop.Connection) (err error) {
+		for _, newFeed := range newFeeds {
+			feed := models.Feed{
+				ID:          newFeed.ID,
+				FeedURL:     newFeed.URL,
+				FetchDelayS: 60 * 60,
+			}
+			if err = tx.Create(&feed); err != nil {
+				return fmt.Errorf("adding feed failed: %s", err)
+			}
+		}
+
+		return r.changeFolders(tx, claims, folders)
+	})
+}
+
+// changeFolders validates and saves the folders using the given connection or transaction
+func (r *APIPopRepository) changeFolders(tx *pop.Connection, claims *helpers.AuthClaims, folders []controller.Folder) (err error) {
 	// get user folder list
 	user := models.User{}
-	err = r.pop.Find(&user, claims.ID)
+	err = tx.Find(&user, claims.ID)
 	if err != nil {
 		err = errors.New("user doesn't exist")
 		return
@@ -112,6 +203,8 @@ func (r *APIPopRepository) ChangeFolders(claims *helpers.AuthClaims, folders []c
 
 	// build a hashmap so we can ensure feeds are only subscribed to once.
 	subscribedFeeds := mapset.NewSet(uuid.UUID{})
+	// the same feed ids in order for fetching the feeds
+	feedIDs := []uuid.UUID{}
 	// for comparisons against empty uuids
 	nullUUID := uuid.NullUUID{}
 	// the sanitized folders struct for the DB
@@ -146,40 +239,73 @@ func (r *APIPopRepository) ChangeFolders(claims *helpers.AuthClaims, folders []c
 				return
 			}
 			foldersJSON[folderIdx].Feeds[feedIdx].ID = feed.ID
+			// allow custom title, the default title is set below
+			foldersJSON[folderIdx].Feeds[feedIdx].Title = feed.Title
 
-			feedInfo := models.Feed{}
-			err := r.pop.Find(&feedInfo, feed.ID)
-			if err != nil {
+			subscribedFeeds.Add(feed.ID)
+			feedIDs = append(feedIDs, feed.ID)
+		}
+	}
+
+	// check that all feeds exist and get their default titles and icons with one query
+	feedInfos, err := r.feedsByID(tx, feedIDs)
+	if err != nil {
+		return
+	}
+	for folderIdx := range foldersJSON {
+		for feedIdx := range foldersJSON[folderIdx].Feeds {
+			feed := &foldersJSON[folderIdx].Feeds[feedIdx]
+			feedInfo, ok := feedInfos[feed.ID]
+			if !ok {
 				return fmt.Errorf("feed doesn't exist: %s", feed.ID)
 			}
 			// this feed is good
 
-			if feed.Title != "" {
-				// allow custom title
-				foldersJSON[folderIdx].Feeds[feedIdx].Title = feed.Title
-			} else {
+			if feed.Title == "" {
 				// use default title
-				foldersJSON[folderIdx].Feeds[feedIdx].Title = feedInfo.Title.String
+				feed.Title = feedInfo.Title.String
 			}
-			foldersJSON[folderIdx].Feeds[feedIdx].Icon = feedInfo.Icon.String
-
-			subscribedFeeds.Add(feed.ID)
+			feed.Icon = feedInfo.Icon.String
 		}
 	}
 
 	// update folders in db
 	user.Folders = foldersJSON
-	err = r.pop.UpdateColumns(&user, "folders")
+	err = tx.UpdateColumns(&user, "folders")
 	if err != nil {
 		return fmt.Errorf("UpdateColumns failed: %s", err)
 	}
 
 	// update list of subscribed feeds to detect unsubscribed feeds (in the feed worker)
-	err = r.updateUserFeeds(&user, &subscribedFeeds)
+	err = r.updateUserFeeds(tx, &user, &subscribedFeeds)
+	return
+}
+
+// FolderLimits returns the maximum number of folders per user and feeds per folder
+func (r *APIPopRepository) FolderLimits() (folderCount int, folderFeedCount int) {
+	return r.folderCountLimit, r.folderFeedCountLimit
+}
+
+// feedsByID returns id, title and icon of the given feeds. Feeds that don't exist are missing in the result.
+func (r *APIPopRepository) feedsByID(tx *pop.Connection, feedIDs []uuid.UUID) (ret map[uuid.UUID]models.Feed, err error) {
+	ret = make(map[uuid.UUID]models.Feed, len(feedIDs))
+	if len(feedIDs) == 0 {
+		return
+	}
+
+	feeds := []models.Feed{}
+	err = tx.Select("id", "title", "icon").Where("id in (?)", feedIDs).All(&feeds)
+	if err != nil {
+		return ret, fmt.Errorf("fetching feeds failed: %s", err)
+	}
+
+	for _, feed := range feeds {
+		ret[feed.ID] = feed
+	}
 	return
 }
 
-func (r APIPopRepository) updateUserFeeds(user *models.User, feedIDs *mapset.Set) (err error) {
+func (r APIPopRepository) updateUserFeeds(tx *pop.Connection, user *models.User, feedIDs *mapset.Set) (err error) {
 	if user == nil || feedIDs == nil {
 		return errors.New("nil user or feedIDs")
 	}
@@ -188,7 +314,7 @@ func (r APIPopRepository) updateUserFeeds(user *models.User, feedIDs *mapset.Set
 	// see: https://github.com/gobuffalo/pop/issues/136
 	// therefore we do it ourself...
 	userFeeds := []models.UserFeed{}
-	err = r.pop.Where("user_id = ?", user.ID).All(&userFeeds)
+	err = tx.Where("user_id = ?", user.ID).All(&userFeeds)
 	if err != nil {
 		return fmt.Errorf("updateUserFeeds failed#1: %s", err)
 	}
@@ -203,7 +329,7 @@ func (r APIPopRepository) updateUserFeeds(user *models.User, feedIDs *mapset.Set
 	deleteUserFeeds := existingUserFeeds.Difference(*feedIDs)
 	if deleteUserFeeds.Cardinality() > 0 {
 		log.WithField("delete", deleteUserFeeds).WithField("count", deleteUserFeeds.Cardinality()).Debug("deleting user_feeds")
-		err = r.pop.RawQuery("DELETE FROM user_feeds WHERE user_id = ? AND feed_id in (?)", user.ID.String(), deleteUserFeeds.ToSlice()).Exec()
+		err = tx.RawQuery("DELETE FROM user_feeds WHERE user_id = ? AND feed_id in (?)", user.ID.String(), deleteUserFeeds.ToSlice()).Exec()
 		if err != nil {
 			return fmt.Errorf("updateUserFeeds failed#2: %s", err)
 		}
@@ -214,7 +340,7 @@ func (r APIPopRepository) updateUserFeeds(user *models.User, feedIDs *mapset.Set
 	if addUserFeeds.Cardinality() > 0 {
 		log.WithField("add", addUserFeeds).WithField("count", addUserFeeds.Cardinality()).Debug("adding user_feeds")
 		addingUserFeed := toUserFeeds(user.ID, &addUserFeeds)
-		err = r.pop.Create(addingUserFeed)
+		err = tx.Create(addingUserFeed)
 		if err != nil {
 			return fmt.Errorf("updateUserFeeds failed#3: %s", err)
 		}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/spezifisch/rueder3/backend/pkg/api/controller"
+	"github.com/spezifisch/rueder3/backend/pkg/helpers"
+	"github.com/spezifisch/rueder3/backend/pkg/repository/pop/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// the benchmarks need the actual test db because copyist can't replay a varying number of queries:
+//  docker-compose up db
+//  go test -run ^$ -bench . ./pkg/repository/pop/api/
+
+// numbers of subscribed feeds to benchmark with. the query count shouldn't depend on it.
+var benchmarkFeedCounts = []int{10, 100, 1000}
+
+const benchmarkArticlesPerFeed = 20
+
+func BenchmarkAPIUser_Folders(b *testing.B) {
+	conn := openBenchmarkDB(b)
+
+	for _, feedCount := range benchmarkFeedCounts {
+		b.Run(fmt.Sprintf("feeds=%d", feedCount), func(b *testing.B) {
+			r, claims, folders := seedBenchmarkDB(b, conn, feedCount)
+			assert.NoError(b, r.ChangeFolders(claims, folders))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ret, err := r.Folders(claims)
+				if err != nil || len(ret) != len(folders) {
+					b.Fatalf("Folders() failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAPIUser_ChangeFolders(b *testing.B) {
+	conn := openBenchmarkDB(b)
+
+	for _, feedCount := range benchmarkFeedCounts {
+		b.Run(fmt.Sprintf("feeds=%d", feedCount), func(b *testing.B) {
+			r, claims, folders := seedBenchmarkDB(b, conn, feedCount)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := r.ChangeFolders(claims, folders); err != nil {
+					b.Fatalf("ChangeFolders() failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// openBenchmarkDB recreates the test db and connects to it without copyist.
+// The benchmark is skipped if postgres isn't running.
+func openBenchmarkDB(b *testing.B) *pop.Connection {
+	conn, err := pop.Connect("test_nocopyist")
+	if err != nil {
+		b.Skipf("test db not configured: %v", err)
+	}
+	details := conn.Dialect.Details()
+	dbConn, err := net.DialTimeout("tcp", net.JoinHostPort(details.Host, details.Port), time.Second)
+	if err != nil {
+		b.Skipf("postgres not running: %v", err)
+	}
+	dbConn.Close()
+
+	setupTestDB()
+
+	conn, err = pop.Connect("test_nocopyist")
+	if err != nil {
+		b.Fatalf("connect failed: %v", err)
+	}
+	return conn
+}
+
+// seedBenchmarkDB creates the test user with the given number of feeds with articles
+// and returns folders of up to 100 feeds each containing all of them
+func seedBenchmarkDB(b *testing.B, conn *pop.Connection, feedCount int) (r *APIPopRepository, claims *helpers.AuthClaims, folders []controller.Folder) {
+	assert.NoError(b, conn.TruncateAll())
+
+	createUser(b, conn, testUserID)
+	claims = &helpers.AuthClaims{
+		ID:         testUserID,
+		Origin:     "somewhere",
+		Name:       "someone",
+		OriginName: "somewhere:someone",
+	}
+
+	err := conn.RawQuery(`INSERT INTO feeds (id, created_at, updated_at, fetched_at, fetch_delay_s, fetcher_state, feed_url, title)
+		SELECT gen_random_uuid(), now(), now(), now(), 3600, '{}', 'https://example.com/feed/' || i, 'Feed ' || i
+		FROM generate_series(1, ?) AS i`, feedCount).Exec()
+	assert.NoError(b, err)
+	err = conn.RawQuery(`INSERT INTO articles (id, created_at, updated_at, feed_id, site_guid, posted_at, content)
+		SELECT gen_random_uuid(), now(), now(), feeds.id, feeds.id || '-' || i, now(), '{}'
+		FROM feeds, generate_series(1, ?) AS i`, benchmarkArticlesPerFeed).Exec()
+	assert.NoError(b, err)
+
+	feeds := []models.Feed{}
+	assert.NoError(b, conn.Select("id").All(&feeds))
+
+	for i, feed := range feeds {
+		if i%100 == 0 {
+			folders = append(folders, controller.Folder{
+				ID:    uuid.Must(uuid.NewV4()),
+				Title: fmt.Sprintf("Folder %d", len(folders)),
+			})
+		}
+		folder := &folders[len(folders)-1]
+		folder.Feeds = append(folder.Feeds, controller.Feed{ID: feed.ID})
+	}
+
+	r = &APIPopRepository{
+		pop:                  conn,
+		folderCountLimit:     100,
+		folderFeedCountLimit: 1000,
+		markArticlesLimit:    1000,
+		labelCountLimit:      100,
+	}
+	return
+}
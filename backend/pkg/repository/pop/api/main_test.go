@@ -87,7 +87,7 @@ func beforeEachTest(t *testing.T) {
 	createUser(t, conn, testUserID)
 }
 
-func createUser(t *testing.T, pop *pop.Connection, userID uuid.UUID) {
+func createUser(t testing.TB, pop *pop.Connection, userID uuid.UUID) {
 	u := &models.User{
 		ID: userID,
 	}
@@ -1,12 +1,14 @@
 package authbackend
 
 import (
+	"context"
 	"errors"
 
 	"github.com/apex/log"
 	"github.com/gobuffalo/pop/v6"
 
 	"github.com/spezifisch/rueder3/backend/pkg/authbackend/controller"
+	"github.com/spezifisch/rueder3/backend/pkg/health"
 	"github.com/spezifisch/rueder3/backend/pkg/repository/pop/models"
 )
 
@@ -29,6 +31,19 @@ func NewAuthBackendPopRepository(db string) *AuthBackendPopRepository {
 	}
 }
 
+// Ping checks if the database is usable
+func (r *AuthBackendPopRepository) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), health.CheckTimeout)
+	defer cancel()
+
+	return r.pop.WithContext(ctx).RawQuery("SELECT 1").Exec()
+}
+
+// Close closes the database connection
+func (r *AuthBackendPopRepository) Close() error {
+	return r.pop.Close()
+}
+
 func (r *AuthBackendPopRepository) getUser(authOrigin, authSubject string) (user models.User, exists bool, err error) {
 	users := []models.User{}
 	err = r.pop.Select("id").Where("auth_origin = ?", authOrigin).Where("auth_subject = ?", authSubject).Limit(1).All(&users)
@@ -0,0 +1,96 @@
+package authbackend
+
+import (
+	"errors"
+
+	"github.com/apex/log"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/pkg/authbackend/controller"
+	"github.com/spezifisch/rueder3/backend/pkg/repository/pop/models"
+)
+
+// CreateLocalUser creates a user with a password, it fails with controller.ErrUsernameTaken if the user exists
+func (r *AuthBackendPopRepository) CreateLocalUser(username string, passwordHash string) (ret controller.User, err error) {
+	err = r.pop.Transaction(func(tx *pop.Connection) (err error) {
+		exists, err := tx.Where("auth_origin = ?", controller.LocalAuthOrigin).Where("auth_subject = ?", username).
+			Exists(&models.User{})
+		if err != nil {
+			return
+		}
+		if exists {
+			return controller.ErrUsernameTaken
+		}
+
+		log.WithField("origin", controller.LocalAuthOrigin).WithField("sub", username).Info("creating new local user")
+		user := models.User{
+			AuthOrigin:  controller.LocalAuthOrigin,
+			AuthSubject: username,
+		}
+		verrs, err := tx.ValidateAndCreate(&user)
+		if err != nil {
+			return
+		}
+		if verrs.HasAny() {
+			return verrs
+		}
+
+		password := models.UserPassword{
+			UserID:       user.ID,
+			PasswordHash: passwordHash,
+		}
+		if err = tx.Create(&password); err != nil {
+			return
+		}
+
+		ret = controller.User{
+			ID:          user.ID,
+			AuthOrigin:  user.AuthOrigin,
+			AuthSubject: user.AuthSubject,
+		}
+		return
+	})
+	return
+}
+
+// localUserResult is a local user with the password hash
+type localUserResult struct {
+	ID           uuid.UUID `db:"id"`
+	AuthSubject  string    `db:"auth_subject"`
+	PasswordHash string    `db:"password_hash"`
+}
+
+// LocalUser returns the local user with the password hash
+func (r *AuthBackendPopRepository) LocalUser(username string) (ret controller.User, passwordHash string, err error) {
+	users := []localUserResult{}
+	err = r.pop.RawQuery(`SELECT users.id, users.auth_subject, user_passwords.password_hash FROM users
+		JOIN user_passwords ON user_passwords.user_id = users.id
+		WHERE users.auth_origin = ? AND users.auth_subject = ?`, controller.LocalAuthOrigin, username).All(&users)
+	if err != nil {
+		return
+	}
+	if len(users) != 1 {
+		err = errors.New("user doesn't exist")
+		return
+	}
+
+	ret = controller.User{
+		ID:          users[0].ID,
+		AuthOrigin:  controller.LocalAuthOrigin,
+		AuthSubject: users[0].AuthSubject,
+	}
+	passwordHash = users[0].PasswordHash
+	return
+}
+
+// ChangePasswordHash sets a new password hash for the local user
+func (r *AuthBackendPopRepository) ChangePasswordHash(userID uuid.UUID, passwordHash string) (err error) {
+	password := models.UserPassword{}
+	if err = r.pop.Where("user_id = ?", userID).First(&password); err != nil {
+		return errors.New("user has no password")
+	}
+
+	password.PasswordHash = passwordHash
+	return r.pop.UpdateColumns(&password, "password_hash", "updated_at")
+}
@@ -0,0 +1,56 @@
+package authbackend
+
+import (
+	"errors"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/pkg/authbackend/controller"
+	"github.com/spezifisch/rueder3/backend/pkg/repository/pop/models"
+)
+
+// APITokens returns the personal API tokens of the user, oldest first
+func (r *AuthBackendPopRepository) APITokens(userID uuid.UUID) (ret []controller.APIToken, err error) {
+	tokens := models.APITokens{}
+	if err = r.pop.Where("user_id = ?", userID).Order("created_at").All(&tokens); err != nil {
+		return
+	}
+
+	ret = make([]controller.APIToken, len(tokens))
+	for i, token := range tokens {
+		ret[i] = toAPIToken(&token)
+	}
+	return
+}
+
+// AddAPIToken stores the hash of a new personal API token
+func (r *AuthBackendPopRepository) AddAPIToken(userID uuid.UUID, name string, tokenHash string) (ret controller.APIToken, err error) {
+	token := models.APIToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: tokenHash,
+	}
+	if err = r.pop.Create(&token); err != nil {
+		return
+	}
+
+	ret = toAPIToken(&token)
+	return
+}
+
+// DeleteAPIToken revokes the user's personal API token
+func (r *AuthBackendPopRepository) DeleteAPIToken(userID uuid.UUID, tokenID uuid.UUID) (err error) {
+	token := models.APIToken{}
+	if err = r.pop.Where("user_id = ?", userID).Find(&token, tokenID); err != nil {
+		return errors.New("token doesn't exist")
+	}
+	return r.pop.Destroy(&token)
+}
+
+func toAPIToken(token *models.APIToken) controller.APIToken {
+	return controller.APIToken{
+		ID:        token.ID,
+		Name:      token.Name,
+		CreatedAt: token.CreatedAt,
+	}
+}
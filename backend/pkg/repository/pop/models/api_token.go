@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// APIToken is a long-lived personal access token of a user. Only the hash of the token is stored.
+type APIToken struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	UserID uuid.UUID `json:"user_id" db:"user_id"`
+	User   *User     `json:"user,omitempty" belongs_to:"user"`
+
+	Name      string `json:"name" db:"name"`
+	TokenHash string `json:"-" db:"token_hash"`
+}
+
+// Table gives pop the name of the database table
+func (a APIToken) Table() string {
+	return "api_tokens"
+}
+
+// String is not required by pop and may be deleted
+func (a APIToken) String() string {
+	ja, _ := json.Marshal(a)
+	return string(ja)
+}
+
+// APITokens is not required by pop and may be deleted
+type APITokens []APIToken
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// UnlabelledArticleCondition matches articles that have no label assigned.
+// Labelled articles are kept by the user on purpose, so anything removing old articles has to add this to its WHERE clause.
+const UnlabelledArticleCondition = "NOT EXISTS (SELECT 1 FROM article_labels WHERE article_labels.article_id = articles.id)"
+
+// ArticleLabel is used by pop to map your article_labels database table to your go code.
+type ArticleLabel struct {
+	ID        int       `json:"-" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	LabelID uuid.UUID `json:"label_id" db:"label_id"`
+	Label   *Label    `json:"label,omitempty" belongs_to:"label"`
+
+	ArticleID uuid.UUID `json:"article_id" db:"article_id"`
+	Article   *Article  `json:"article,omitempty" belongs_to:"article"`
+}
+
+// Table gives pop the name of the database table
+func (a ArticleLabel) Table() string {
+	return "article_labels"
+}
+
+// String is not required by pop and may be deleted
+func (a ArticleLabel) String() string {
+	ja, _ := json.Marshal(a)
+	return string(ja)
+}
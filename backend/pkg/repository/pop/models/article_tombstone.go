@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// ArticleTombstone remembers the GUID of a pruned article so that it isn't added again while it's still in the feed.
+type ArticleTombstone struct {
+	ID        int       `json:"-" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	FeedID   uuid.UUID `json:"feed_id" db:"feed_id"`
+	SiteGUID string    `json:"site_guid" db:"site_guid"`
+}
+
+// Table gives pop the name of the database table
+func (a ArticleTombstone) Table() string {
+	return "article_tombstones"
+}
+
+// String is not required by pop and may be deleted
+func (a ArticleTombstone) String() string {
+	ja, _ := json.Marshal(a)
+	return string(ja)
+}
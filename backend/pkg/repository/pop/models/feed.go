@@ -21,6 +21,12 @@ type Feed struct {
 	FetchDelayS int       `json:"fetch_delay_s" db:"fetch_delay_s"`
 	// for both fetcher and frontend
 	FetcherState FetcherState `json:"fetcher_state" db:"fetcher_state"`
+	// download the linked article page instead of using the feed content
+	FetchFullArticle bool `json:"fetch_full_article" db:"fetch_full_article"`
+	// set by the scheduler's cleanup when the last user unsubscribed
+	OrphanedAt nulls.Time `json:"orphaned_at" db:"orphaned_at"`
+	// next_fetch_at and leased_until are left out on purpose. they are only accessed with raw queries
+	// by the scheduler repository, and new feeds have to get the next_fetch_at default of the db.
 
 	FeedURL string       `json:"feed_url" db:"feed_url"`
 	SiteURL nulls.String `json:"site_url" db:"site_url"`
@@ -9,10 +9,13 @@ import (
 // FetcherState contains status info that interests both backend workers and frontend
 type FetcherState struct {
 	// internal
-	ETag         string `json:"etag,omitempty"`
-	LastModified string `json:"last_modified,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FailureCount int       `json:"failure_count,omitempty"`
+	BackoffUntil time.Time `json:"backoff_until,omitempty"`
 
 	// things the frontend is interested in
+	Dead        bool      `json:"dead,omitempty"`
 	Working     bool      `json:"working"`
 	LastSuccess time.Time `json:"last_success,omitempty"`
 	LastError   time.Time `json:"last_error,omitempty"`
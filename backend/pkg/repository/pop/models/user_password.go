@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// UserPassword is the password of a user with a local account. Users of other auth origins don't have one.
+type UserPassword struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	UserID uuid.UUID `json:"user_id" db:"user_id"`
+	User   *User     `json:"user,omitempty" belongs_to:"user"`
+
+	// argon2id hash in PHC string format
+	PasswordHash string `json:"-" db:"password_hash"`
+}
+
+// Table gives pop the name of the database table
+func (p UserPassword) Table() string {
+	return "user_passwords"
+}
+
+// String is not required by pop and may be deleted
+func (p UserPassword) String() string {
+	jp, _ := json.Marshal(p)
+	return string(jp)
+}
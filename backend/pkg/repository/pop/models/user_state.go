@@ -3,27 +3,34 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"sort"
+	"time"
 
 	"github.com/gofrs/uuid"
 )
 
 // UserState contains feed and article state info shared between all clients of the same user
 type UserState struct {
-	ID int `json:"-" db:"id"`
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 
 	UserID uuid.UUID `json:"user_id" db:"user_id"`
-	User   *User     `json:"user" belongs_to:"user"`
+	User   *User     `json:"user,omitempty" belongs_to:"user"`
 
-	FeedStates map[uuid.UUID]UserFeedState `json:"feed_states"`
+	FeedStates UserFeedStates `json:"feed_states" db:"feed_states"`
 }
 
-// Value implements the driver.Value interface
-func (u UserState) Value() (driver.Value, error) {
+// UserFeedStates maps feed ids to the user's state of this feed
+type UserFeedStates map[uuid.UUID]UserFeedState
+
+// Value implements the driver.Valuer interface
+func (u UserFeedStates) Value() (driver.Value, error) {
 	return json.Marshal(u)
 }
 
 // Scan implements the sql.Scanner interface
-func (u *UserState) Scan(value interface{}) error {
+func (u *UserFeedStates) Scan(value interface{}) error {
 	if value == nil {
 		return nil
 	}
@@ -35,8 +42,84 @@ func (u *UserState) Scan(value interface{}) error {
 type UserFeedState struct {
 	FeedID uuid.UUID `json:"feed_id"`
 
-	// the following fields all refer to article sequence numbers (the n'th article in the feed when ordered by CreatedAt)
+	// the following fields all refer to the global article sequence numbers (articles.seq).
+	// unlike feed_seq they don't change when other articles of the feed are removed.
 	LastUpdateAt int   `json:"last_update_at"`
 	ReadAllUntil int   `json:"read_all_until"`
 	ReadArticles []int `json:"read_articles"`
 }
+
+// IsRead returns true if the article with the given seq is marked as read
+func (s *UserFeedState) IsRead(seq int) bool {
+	if seq <= s.ReadAllUntil {
+		return true
+	}
+	idx := sort.SearchInts(s.ReadArticles, seq)
+	return idx < len(s.ReadArticles) && s.ReadArticles[idx] == seq
+}
+
+// MarkRead adds the given article seqs to the list of read articles
+func (s *UserFeedState) MarkRead(seqs []int) {
+	for _, seq := range seqs {
+		if !s.IsRead(seq) {
+			s.ReadArticles = append(s.ReadArticles, seq)
+			sort.Ints(s.ReadArticles)
+		}
+	}
+}
+
+// MarkUnread removes the given article seqs from the read articles.
+// articleSeqs has to contain the seqs of the feed's articles that are covered by ReadAllUntil
+// and newer than the oldest given seq, because they need to be listed individually afterwards.
+func (s *UserFeedState) MarkUnread(seqs []int, articleSeqs []int) {
+	if len(seqs) == 0 {
+		return
+	}
+
+	oldest := seqs[0]
+	for _, seq := range seqs {
+		if seq < oldest {
+			oldest = seq
+		}
+	}
+
+	if oldest <= s.ReadAllUntil {
+		// articles between the oldest unread one and ReadAllUntil stay read
+		for _, seq := range articleSeqs {
+			if seq > oldest && seq <= s.ReadAllUntil {
+				s.ReadArticles = append(s.ReadArticles, seq)
+			}
+		}
+		s.ReadAllUntil = oldest - 1
+	}
+
+	unread := make(map[int]bool, len(seqs))
+	for _, seq := range seqs {
+		unread[seq] = true
+	}
+	readArticles := make([]int, 0, len(s.ReadArticles))
+	for _, seq := range s.ReadArticles {
+		if !unread[seq] && seq > s.ReadAllUntil {
+			readArticles = append(readArticles, seq)
+		}
+	}
+	sort.Ints(readArticles)
+	s.ReadArticles = readArticles
+}
+
+// MarkAllRead marks all articles up to and including the given seq as read
+func (s *UserFeedState) MarkAllRead(until int) {
+	if until <= s.ReadAllUntil {
+		return
+	}
+	s.ReadAllUntil = until
+
+	// the list only needs to contain articles newer than ReadAllUntil
+	readArticles := make([]int, 0, len(s.ReadArticles))
+	for _, seq := range s.ReadArticles {
+		if seq > until {
+			readArticles = append(readArticles, seq)
+		}
+	}
+	s.ReadArticles = readArticles
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserFeedState_MarkRead(t *testing.T) {
+	s := UserFeedState{ReadAllUntil: 10}
+	s.MarkRead([]int{15, 5, 12, 15})
+
+	assert.Equal(t, 10, s.ReadAllUntil)
+	assert.Equal(t, []int{12, 15}, s.ReadArticles, "already read and duplicate seqs shouldn't be added")
+	assert.True(t, s.IsRead(3))
+	assert.True(t, s.IsRead(12))
+	assert.False(t, s.IsRead(13))
+}
+
+func TestUserFeedState_MarkUnread(t *testing.T) {
+	tests := []struct {
+		name            string
+		state           UserFeedState
+		seqs            []int
+		articleSeqs     []int
+		wantUntil       int
+		wantReadArticle []int
+	}{
+		{
+			name:            "unread from list",
+			state:           UserFeedState{ReadAllUntil: 10, ReadArticles: []int{12, 15}},
+			seqs:            []int{12},
+			wantUntil:       10,
+			wantReadArticle: []int{15},
+		},
+		{
+			name:            "unread below read all",
+			state:           UserFeedState{ReadAllUntil: 10, ReadArticles: []int{15}},
+			seqs:            []int{4},
+			articleSeqs:     []int{6, 8, 10},
+			wantUntil:       3,
+			wantReadArticle: []int{6, 8, 10, 15},
+		},
+		{
+			name:            "unread several below read all",
+			state:           UserFeedState{ReadAllUntil: 10},
+			seqs:            []int{8, 4},
+			articleSeqs:     []int{6, 8, 10},
+			wantUntil:       3,
+			wantReadArticle: []int{6, 10},
+		},
+		{
+			name:            "unread unknown article",
+			state:           UserFeedState{ReadAllUntil: 10, ReadArticles: []int{12}},
+			seqs:            []int{20},
+			wantUntil:       10,
+			wantReadArticle: []int{12},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.state
+			s.MarkUnread(tt.seqs, tt.articleSeqs)
+			assert.Equal(t, tt.wantUntil, s.ReadAllUntil)
+			assert.Equal(t, tt.wantReadArticle, s.ReadArticles)
+			for _, seq := range tt.seqs {
+				assert.False(t, s.IsRead(seq))
+			}
+		})
+	}
+}
+
+func TestUserFeedState_MarkAllRead(t *testing.T) {
+	s := UserFeedState{ReadAllUntil: 10, ReadArticles: []int{12, 15, 20}}
+	s.MarkAllRead(15)
+	assert.Equal(t, 15, s.ReadAllUntil)
+	assert.Equal(t, []int{20}, s.ReadArticles)
+
+	// never goes backwards
+	s.MarkAllRead(5)
+	assert.Equal(t, 15, s.ReadAllUntil)
+	assert.Equal(t, []int{20}, s.ReadArticles)
+}
@@ -8,6 +8,13 @@ import (
 	"github.com/gofrs/uuid"
 )
 
+// SubscribedFeedCondition matches feeds that at least one user has subscribed to
+const SubscribedFeedCondition = "EXISTS (SELECT 1 FROM user_feeds WHERE user_feeds.feed_id = feeds.id)"
+
+// FullArticleRequestedCondition matches feeds where at least one subscriber wants the full article pages.
+// user_feeds.fetch_full_article is only used in queries and isn't part of UserFeed.
+const FullArticleRequestedCondition = "EXISTS (SELECT 1 FROM user_feeds WHERE user_feeds.feed_id = feeds.id AND user_feeds.fetch_full_article)"
+
 // UserFeed is used by pop to map your user_feeds database table to your go code.
 type UserFeed struct {
 	ID int `json:"-" db:"id"`
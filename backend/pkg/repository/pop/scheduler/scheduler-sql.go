@@ -7,11 +7,12 @@ var postgresDropExistingTriggerSQL = `
 DROP TRIGGER IF EXISTS feeds_notify_event ON feeds;
 `
 
-// sql to call notify_event whenever a new is inserted into `feeds`
+// sql to call notify_event whenever a feed is deleted or changed.
+// inserted feeds don't have subscribers yet, they are announced by the user_feeds trigger below.
 // based on: https://coussej.github.io/2015/09/15/Listening-to-generic-JSON-notifications-from-PostgreSQL-in-Go/
 var postgresCreateTriggerSQL = `
 CREATE TRIGGER feeds_notify_event
-AFTER INSERT OR DELETE OR UPDATE OF feed_url ON feeds
+AFTER DELETE OR UPDATE OF feed_url, fetch_full_article ON feeds
     FOR EACH ROW EXECUTE PROCEDURE notify_event();
 `
 
@@ -50,9 +51,59 @@ CREATE OR REPLACE FUNCTION notify_event() RETURNS TRIGGER AS $$
 $$ LANGUAGE plpgsql;
 `
 
-// this is for deserializing the notification json above
+// sql to drop an already existing subscription trigger
+var postgresDropExistingSubscriptionTriggerSQL = `
+DROP TRIGGER IF EXISTS user_feeds_notify_event ON user_feeds;
+`
+
+// sql to call notify_subscription_event whenever somebody subscribes or unsubscribes
+var postgresCreateSubscriptionTriggerSQL = `
+CREATE TRIGGER user_feeds_notify_event
+AFTER INSERT OR DELETE ON user_feeds
+    FOR EACH ROW EXECUTE PROCEDURE notify_subscription_event();
+`
+
+// sql to define a function that sends a notification on the feed_change channel when a feed gets its
+// first subscriber (action INSERT) or loses its last one or one who wanted the full articles (action DELETE)
+var postgresCreateSubscriptionNotifyFunctionSQL = `
+CREATE OR REPLACE FUNCTION notify_subscription_event() RETURNS TRIGGER AS $$
+
+    DECLARE
+        changed_feed_id uuid;
+        subscriber_count int;
+        notification json;
+    BEGIN
+
+        IF (TG_OP = 'DELETE') THEN
+            changed_feed_id = OLD.feed_id;
+        ELSE
+            changed_feed_id = NEW.feed_id;
+        END IF;
+
+        SELECT COUNT(*) INTO subscriber_count FROM user_feeds WHERE feed_id = changed_feed_id;
+
+        -- Only notify if the feed changes between subscribed and unsubscribed, or if a subscriber who wanted
+        -- the full articles is gone.
+        IF (TG_OP = 'INSERT' AND subscriber_count = 1) OR (TG_OP = 'DELETE' AND (subscriber_count = 0 OR OLD.fetch_full_article)) THEN
+            notification = json_build_object(
+                              'table', TG_TABLE_NAME,
+                              'action', TG_OP,
+                              'feed_id', changed_feed_id);
+
+            PERFORM pg_notify('feed_change', notification::text);
+        END IF;
+
+        RETURN NULL;
+    END;
+
+$$ LANGUAGE plpgsql;
+`
+
+// this is for deserializing the notification json above and the refresh requests sent by the api
 type postgresNotificationPayload struct {
 	Table  string    `json:"table"`
 	Action string    `json:"action"`
 	FeedID uuid.UUID `json:"feed_id"`
+	// only set for REFRESH
+	UserID uuid.UUID `json:"user_id"`
 }
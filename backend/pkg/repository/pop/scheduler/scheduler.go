@@ -3,22 +3,44 @@ package scheduler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/apex/log"
+	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
 	"github.com/jackc/pgx/v4"
 
+	"github.com/spezifisch/rueder3/backend/pkg/health"
 	"github.com/spezifisch/rueder3/backend/pkg/helpers"
 	"github.com/spezifisch/rueder3/backend/pkg/repository/pop/models"
 	"github.com/spezifisch/rueder3/backend/pkg/worker/scheduler"
 )
 
+// pruneBatchSize is the maximum number of articles deleted by a single query
+const pruneBatchSize = 10000
+
+// countResult is used to scan COUNT(*) queries
+type countResult struct {
+	Count int `db:"count"`
+}
+
 // SchedulerPopRepository internal state
 type SchedulerPopRepository struct {
 	pop *pop.Connection
 	pgx *pgx.Conn
+	// 1 while the pgx connection is listening for feed changes, accessed atomically
+	listening int32
+
+	// cancelled by Close to stop the listener
+	listenerContext context.Context
+	stopListener    context.CancelFunc
+	// closed when the listener goroutine has stopped
+	listenerDone chan struct{}
 }
 
 // NewSchedulerPopRepository returns a SchedulerRepository that wraps a pop DB
@@ -30,16 +52,43 @@ func NewSchedulerPopRepository(db string) *SchedulerPopRepository {
 		return nil
 	}
 
+	listenerContext, stopListener := context.WithCancel(context.Background())
 	return &SchedulerPopRepository{
 		pop: popTx,
 		pgx: nil, // will connect later
+
+		listenerContext: listenerContext,
+		stopListener:    stopListener,
+	}
+}
+
+// Close stops the feed change listener and closes the database connections
+func (r *SchedulerPopRepository) Close() {
+	r.stopListener()
+	if r.listenerDone != nil {
+		<-r.listenerDone
+	} else if r.pgx != nil {
+		r.pgx.Close(context.Background())
+		r.pgx = nil
+	}
+
+	if err := r.pop.Close(); err != nil {
+		log.WithError(err).Error("failed closing db connection")
 	}
 }
 
-// Feeds returns the list of feeds to fetch next for the scheduler
+// Ping checks if the database is usable
+func (r *SchedulerPopRepository) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), health.CheckTimeout)
+	defer cancel()
+
+	return r.pop.WithContext(ctx).RawQuery("SELECT 1").Exec()
+}
+
+// Feeds returns the list of feeds to fetch next for the scheduler. Feeds without subscribers are skipped.
 func (r *SchedulerPopRepository) Feeds() (ret []scheduler.Feed, err error) {
 	feeds := models.Feeds{}
-	err = r.pop.All(&feeds)
+	err = r.pop.Where(models.SubscribedFeedCondition).All(&feeds)
 	if err != nil {
 		return
 	}
@@ -67,6 +116,12 @@ func (r *SchedulerPopRepository) GetFeed(feedID uuid.UUID) (wanted scheduler.Fee
 func (r *SchedulerPopRepository) toSchedulerFeed(feed *models.Feed) scheduler.Feed {
 	articleCount, _ := r.pop.Where("feed_id = ?", feed.ID).Select("0 as feed_seq").Count(&models.Article{})
 
+	// subscribers can ask for the article pages too
+	fetchFullArticle := feed.FetchFullArticle
+	if !fetchFullArticle {
+		fetchFullArticle, _ = r.pop.Where("id = ? AND "+models.FullArticleRequestedCondition, feed.ID).Exists(&models.Feed{})
+	}
+
 	return scheduler.Feed{
 		ID:        feed.ID,
 		CreatedAt: feed.CreatedAt,
@@ -81,16 +136,22 @@ func (r *SchedulerPopRepository) toSchedulerFeed(feed *models.Feed) scheduler.Fe
 			ETag:         feed.FetcherState.ETag,
 			LastModified: feed.FetcherState.LastModified,
 
+			FailureCount: feed.FetcherState.FailureCount,
+			BackoffUntil: feed.FetcherState.BackoffUntil,
+			Dead:         feed.FetcherState.Dead,
+
 			Working:     feed.FetcherState.Working,
 			LastSuccess: feed.FetcherState.LastSuccess,
 			LastError:   feed.FetcherState.LastError,
 			Message:     feed.FetcherState.Message,
 		},
-		ArticleCount: articleCount,
+		FetchFullArticle: fetchFullArticle,
+		ArticleCount:     articleCount,
 	}
 }
 
-// UpdateFeedInfo updates metadata and sets the fetched timestamp for the feed
+// UpdateFeedInfo updates metadata and sets the fetched timestamp for the feed. This also schedules the next fetch
+// and ends the feed's lease.
 func (r *SchedulerPopRepository) UpdateFeedInfo(feedID uuid.UUID, updatedFeed *scheduler.Feed) (err error) {
 	feed := models.Feed{
 		ID:          feedID,
@@ -99,7 +160,10 @@ func (r *SchedulerPopRepository) UpdateFeedInfo(feedID uuid.UUID, updatedFeed *s
 		FetcherState: models.FetcherState{
 			ETag:         updatedFeed.FetcherState.ETag,
 			LastModified: updatedFeed.FetcherState.LastModified,
+			FailureCount: updatedFeed.FetcherState.FailureCount,
+			BackoffUntil: updatedFeed.FetcherState.BackoffUntil,
 
+			Dead:        updatedFeed.FetcherState.Dead,
 			Working:     updatedFeed.FetcherState.Working,
 			LastSuccess: updatedFeed.FetcherState.LastSuccess,
 			LastError:   updatedFeed.FetcherState.LastError,
@@ -110,18 +174,107 @@ func (r *SchedulerPopRepository) UpdateFeedInfo(feedID uuid.UUID, updatedFeed *s
 		Title:   helpers.NullStringify(updatedFeed.Title),
 		Icon:    helpers.NullStringify(updatedFeed.Icon),
 	}
-	err = r.pop.UpdateColumns(&feed,
-		"fetched_at",
-		"fetch_delay_s",
-		"fetcher_state",
-		"feed_url",
-		"site_url",
-		"title",
-		"icon",
-	)
+	// dead feeds aren't fetched anymore
+	nextFetchAt := nulls.Time{}
+	if !updatedFeed.FetcherState.Dead {
+		nextFetchAt = nulls.NewTime(scheduler.NextFetchAt(updatedFeed).UTC())
+	}
+
+	err = r.pop.Transaction(func(tx *pop.Connection) (err error) {
+		err = tx.UpdateColumns(&feed,
+			"fetched_at",
+			"fetch_delay_s",
+			"fetcher_state",
+			"feed_url",
+			"site_url",
+			"title",
+			"icon",
+		)
+		if err != nil {
+			return
+		}
+
+		// the scheduling columns are only used by ClaimFeeds and aren't part of the model
+		return tx.RawQuery("UPDATE feeds SET next_fetch_at = ?, leased_until = NULL WHERE id = ?", nextFetchAt, feedID).Exec()
+	})
 	return
 }
 
+// ClaimFeeds leases due feeds that aren't leased by another worker instance.
+// Concurrent claims skip the rows locked by each other instead of waiting.
+func (r *SchedulerPopRepository) ClaimFeeds(limit int, leaseDuration time.Duration) (ret []scheduler.Feed, err error) {
+	now := time.Now().UTC()
+
+	claimed := models.Feeds{}
+	err = r.pop.RawQuery(`UPDATE feeds SET leased_until = ? WHERE id IN (
+			SELECT id FROM feeds
+			WHERE next_fetch_at <= ? AND (leased_until IS NULL OR leased_until < ?) AND `+models.SubscribedFeedCondition+`
+			ORDER BY next_fetch_at LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		) RETURNING id`, now.Add(leaseDuration), now, now, limit).All(&claimed)
+	if err != nil || len(claimed) == 0 {
+		return
+	}
+
+	feedIDs := make([]uuid.UUID, len(claimed))
+	for i, feed := range claimed {
+		feedIDs[i] = feed.ID
+	}
+
+	feeds := models.Feeds{}
+	err = r.pop.Where("id in (?)", feedIDs).All(&feeds)
+	if err != nil {
+		return
+	}
+
+	ret = make([]scheduler.Feed, len(feeds))
+	for i, feed := range feeds {
+		ret[i] = r.toSchedulerFeed(&feed)
+	}
+	return
+}
+
+// userIDResult is used to query user ids
+type userIDResult struct {
+	UserID uuid.UUID `db:"user_id"`
+}
+
+// TakeRefreshRequests removes the refresh requests for the feed that were made before the given time and returns
+// the users who made them
+func (r *SchedulerPopRepository) TakeRefreshRequests(feedID uuid.UUID, before time.Time) (userIDs []uuid.UUID, err error) {
+	requests := []userIDResult{}
+	err = r.pop.RawQuery("DELETE FROM feed_refresh_requests WHERE feed_id = ? AND created_at <= ? RETURNING user_id",
+		feedID, before.UTC()).All(&requests)
+	if err != nil {
+		return
+	}
+
+	userIDs = make([]uuid.UUID, len(requests))
+	for i, request := range requests {
+		userIDs[i] = request.UserID
+	}
+	return
+}
+
+// ReleaseFeeds ends the lease of the given feeds without fetching them
+func (r *SchedulerPopRepository) ReleaseFeeds(feedIDs []uuid.UUID) (err error) {
+	if len(feedIDs) == 0 {
+		return
+	}
+	return r.pop.RawQuery("UPDATE feeds SET leased_until = NULL WHERE id in (?)", feedIDs).Exec()
+}
+
+// RenewLeases extends the lease of the given feeds. Feeds whose lease ended in the meantime aren't touched,
+// another instance might have claimed them already.
+func (r *SchedulerPopRepository) RenewLeases(feedIDs []uuid.UUID, leaseDuration time.Duration) (err error) {
+	if len(feedIDs) == 0 {
+		return
+	}
+	now := time.Now().UTC()
+	return r.pop.RawQuery("UPDATE feeds SET leased_until = ? WHERE id in (?) AND leased_until >= ?",
+		now.Add(leaseDuration), feedIDs, now).Exec()
+}
+
 // CheckExistingArticles returns bool=true for every given SiteGUID that already exists
 func (r *SchedulerPopRepository) CheckExistingArticles(feedID uuid.UUID, articleGUIDs []string) (exists []bool, err error) {
 	exists = make([]bool, len(articleGUIDs))
@@ -151,12 +304,92 @@ func (r *SchedulerPopRepository) CheckExistingArticles(feedID uuid.UUID, article
 	for _, article := range articles {
 		if idx, ok := guidIndices[article.SiteGUID]; ok {
 			exists[idx] = true
+			delete(guidIndices, article.SiteGUID)
+		}
+	}
+
+	// pruned articles must not come back as new ones
+	missingGUIDs := make([]string, 0, len(guidIndices))
+	for guid, idx := range guidIndices {
+		if !exists[idx] {
+			missingGUIDs = append(missingGUIDs, guid)
+		}
+	}
+	if len(missingGUIDs) == 0 {
+		return
+	}
+
+	tombstones := []models.ArticleTombstone{}
+	err = r.pop.Select("site_guid").Where("site_guid in (?)", missingGUIDs).Where("feed_id = ?", feedID).All(&tombstones)
+	if err != nil {
+		return
+	}
+
+	for _, tombstone := range tombstones {
+		if idx, ok := guidIndices[tombstone.SiteGUID]; ok {
+			exists[idx] = true
 		}
 	}
 
 	return
 }
 
+// PruneArticles deletes articles older than maxAge or exceeding maxPerFeed per feed, zero disables a limit.
+// The newest keepNewest articles of each feed and labelled articles are kept.
+// The GUIDs of deleted articles are kept as tombstones for CheckExistingArticles.
+func (r *SchedulerPopRepository) PruneArticles(maxAge time.Duration, maxPerFeed int, keepNewest int) (pruned int, err error) {
+	var limits []string
+	var args []interface{}
+	if maxAge > 0 {
+		limits = append(limits, "articles.posted_at < ?")
+		args = append(args, time.Now().UTC().Add(-maxAge))
+	}
+	if maxPerFeed > 0 {
+		limits = append(limits, "articles.feed_rank > ?")
+		args = append(args, maxPerFeed)
+	}
+	if len(limits) == 0 {
+		return
+	}
+
+	// the articles are ranked including labelled ones, so labelling doesn't change which are the newest.
+	// the ranked subquery is called articles for UnlabelledArticleCondition.
+	query := `WITH pruned AS (
+		DELETE FROM articles WHERE id IN (
+			SELECT articles.id FROM (
+				SELECT id, posted_at, row_number() OVER (PARTITION BY feed_id ORDER BY seq DESC) AS feed_rank FROM articles
+			) AS articles
+			WHERE ` + models.UnlabelledArticleCondition + ` AND articles.feed_rank > ? AND (` + strings.Join(limits, " OR ") + `)
+			LIMIT ?
+		) RETURNING feed_id, site_guid
+	), tombstones AS (
+		INSERT INTO article_tombstones (created_at, updated_at, feed_id, site_guid)
+		SELECT ?, ?, feed_id, site_guid FROM pruned WHERE site_guid != ''
+		ON CONFLICT (feed_id, site_guid) DO NOTHING
+	)
+	SELECT COUNT(*) AS count FROM pruned`
+
+	// delete in batches to keep the transactions small
+	for {
+		now := time.Now().UTC()
+		batchArgs := append([]interface{}{keepNewest}, args...)
+		batchArgs = append(batchArgs, pruneBatchSize, now, now)
+
+		result := []countResult{}
+		if err = r.pop.RawQuery(query, batchArgs...).All(&result); err != nil {
+			return
+		}
+		if len(result) == 0 {
+			return
+		}
+
+		pruned += result[0].Count
+		if result[0].Count < pruneBatchSize {
+			return
+		}
+	}
+}
+
 // AddArticle stores the given article
 func (r *SchedulerPopRepository) AddArticle(feedID uuid.UUID, a *scheduler.Article) (err error) {
 	var enclosures []models.ArticleEnclosure = nil
@@ -190,8 +423,83 @@ func (r *SchedulerPopRepository) AddArticle(feedID uuid.UUID, a *scheduler.Artic
 	return
 }
 
+// seqResult is used to query a single article seq
+type seqResult struct {
+	Seq int `db:"seq"`
+}
+
+// LatestArticleSeq returns the highest article seq of the feed, 0 if it has no articles
+func (r *SchedulerPopRepository) LatestArticleSeq(feedID uuid.UUID) (seq int, err error) {
+	latest := seqResult{}
+	err = r.pop.RawQuery("SELECT COALESCE(MAX(seq), 0) AS seq FROM articles WHERE feed_id = ?", feedID).First(&latest)
+	seq = latest.Seq
+	return
+}
+
+// refreshRequestRetention is how long refresh requests that no worker has answered are kept
+const refreshRequestRetention = 24 * time.Hour
+
+// CleanupOrphanedFeeds marks feeds without subscribers as orphaned and removes feeds that have been orphaned
+// for longer than retention together with their articles. Old refresh requests are removed too. Feeds with labelled articles are archived instead:
+// the feed, its labelled articles and the tombstones of the deleted ones are kept.
+func (r *SchedulerPopRepository) CleanupOrphanedFeeds(retention time.Duration) (deleted int, archived int, err error) {
+	err = r.pop.Transaction(func(tx *pop.Connection) (err error) {
+		now := time.Now().UTC()
+
+		// update orphaned_at for feeds that lost their last subscriber or got resubscribed
+		err = tx.RawQuery("UPDATE feeds SET orphaned_at = ? WHERE orphaned_at IS NULL AND NOT "+models.SubscribedFeedCondition, now).Exec()
+		if err != nil {
+			return fmt.Errorf("marking orphaned feeds failed: %s", err)
+		}
+		err = tx.RawQuery("UPDATE feeds SET orphaned_at = NULL WHERE orphaned_at IS NOT NULL AND " + models.SubscribedFeedCondition).Exec()
+		if err != nil {
+			return fmt.Errorf("unmarking subscribed feeds failed: %s", err)
+		}
+
+		// refresh requests are only taken by leasing workers, the others get them from the listener
+		err = tx.RawQuery("DELETE FROM feed_refresh_requests WHERE created_at < ?", now.Add(-refreshRequestRetention)).Exec()
+		if err != nil {
+			return fmt.Errorf("deleting old refresh requests failed: %s", err)
+		}
+
+		expiredFeeds := []models.Feed{}
+		err = tx.Select("id").Where("orphaned_at < ?", now.Add(-retention)).All(&expiredFeeds)
+		if err != nil || len(expiredFeeds) == 0 {
+			return
+		}
+		feedIDs := make([]uuid.UUID, len(expiredFeeds))
+		for i, feed := range expiredFeeds {
+			feedIDs[i] = feed.ID
+		}
+
+		// the subscription check is repeated in case somebody subscribed in the meantime.
+		// like in PruneArticles the GUIDs are kept as tombstones, so that resubscribing to an archived feed
+		// doesn't bring the deleted articles back as unread.
+		err = tx.RawQuery(`WITH orphaned AS (
+			DELETE FROM articles WHERE feed_id IN (
+				SELECT id FROM feeds WHERE id in (?) AND NOT `+models.SubscribedFeedCondition+`
+			) AND `+models.UnlabelledArticleCondition+`
+			RETURNING feed_id, site_guid
+		)
+		INSERT INTO article_tombstones (created_at, updated_at, feed_id, site_guid)
+		SELECT ?, ?, feed_id, site_guid FROM orphaned WHERE site_guid != ''
+		ON CONFLICT (feed_id, site_guid) DO NOTHING`, feedIDs, now, now).Exec()
+		if err != nil {
+			return fmt.Errorf("deleting articles failed: %s", err)
+		}
+		deleted, err = tx.RawQuery(`DELETE FROM feeds WHERE id in (?) AND NOT `+models.SubscribedFeedCondition+`
+			AND NOT EXISTS (SELECT 1 FROM articles WHERE articles.feed_id = feeds.id)`, feedIDs).ExecWithCount()
+		if err != nil {
+			return fmt.Errorf("deleting feeds failed: %s", err)
+		}
+		archived = len(feedIDs) - deleted
+		return
+	})
+	return
+}
+
 // RunFeedChangeListener adds a postgres table insert listener for the feed table
-func (r *SchedulerPopRepository) RunFeedChangeListener(addedFeeds chan<- uuid.UUID, needRehash chan<- bool) (err error) {
+func (r *SchedulerPopRepository) RunFeedChangeListener(addedFeeds chan<- uuid.UUID, needRehash chan<- bool, refreshRequests chan<- scheduler.RefreshRequest) (err error) {
 	if r.pop.Dialect.Name() != "postgres" {
 		log.WithField("dialect", r.pop.Dialect.Name()).Error("RunAddFeedListener only supports postgres. Can't use AddFeed notifications.")
 		return
@@ -209,6 +517,9 @@ func (r *SchedulerPopRepository) RunFeedChangeListener(addedFeeds chan<- uuid.UU
 		postgresDropExistingTriggerSQL,
 		postgresCreateNotifyFunctionSQL,
 		postgresCreateTriggerSQL,
+		postgresDropExistingSubscriptionTriggerSQL,
+		postgresCreateSubscriptionNotifyFunctionSQL,
+		postgresCreateSubscriptionTriggerSQL,
 	} {
 		_, err := r.pgx.Exec(context.Background(), query)
 		if err != nil {
@@ -217,14 +528,31 @@ func (r *SchedulerPopRepository) RunFeedChangeListener(addedFeeds chan<- uuid.UU
 	}
 
 	// add listener for our channel
-	_, err = r.pgx.Exec(context.Background(), "LISTEN feed_change")
-	if err != nil {
+	if err = r.listen(); err != nil {
 		return
 	}
 
 	log.Info("added feed_change trigger and listener")
 
-	go r.addFeedListener(addedFeeds, needRehash)
+	r.listenerDone = make(chan struct{})
+	go r.addFeedListener(addedFeeds, needRehash, refreshRequests)
+	return
+}
+
+// PingListener checks if the connection listening for feed changes is up
+func (r *SchedulerPopRepository) PingListener() error {
+	if atomic.LoadInt32(&r.listening) == 0 {
+		return errors.New("not listening for feed changes")
+	}
+	return nil
+}
+
+// listen subscribes the pgx connection to the feed_change channel
+func (r *SchedulerPopRepository) listen() (err error) {
+	_, err = r.pgx.Exec(context.Background(), "LISTEN feed_change")
+	if err == nil {
+		atomic.StoreInt32(&r.listening, 1)
+	}
 	return
 }
 
@@ -244,8 +572,29 @@ func (r *SchedulerPopRepository) connectListener() (reconnectable bool) {
 	return true
 }
 
-func (r *SchedulerPopRepository) addFeedListener(addedFeeds chan<- uuid.UUID, needRehash chan<- bool) {
-	for {
+func (r *SchedulerPopRepository) addFeedListener(addedFeeds chan<- uuid.UUID, needRehash chan<- bool, refreshRequests chan<- scheduler.RefreshRequest) {
+	ctx := r.listenerContext
+	defer close(r.listenerDone)
+	defer func() {
+		atomic.StoreInt32(&r.listening, 0)
+		if r.pgx != nil {
+			r.pgx.Close(context.Background())
+			r.pgx = nil
+		}
+		log.Info("listener stopped")
+	}()
+
+	// retry waits before reconnecting, it returns false if the listener is stopped in the meantime
+	retry := func() bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(10 * time.Second):
+			return true
+		}
+	}
+
+	for ctx.Err() == nil {
 		if r.pgx == nil {
 			// db disconnected
 			if reconnectable := r.connectListener(); !reconnectable {
@@ -255,14 +604,37 @@ func (r *SchedulerPopRepository) addFeedListener(addedFeeds chan<- uuid.UUID, ne
 			if r.pgx == nil {
 				// still not connected
 				log.Error("listener: retrying in 10s")
-				time.Sleep(10 * time.Second)
+				if !retry() {
+					return
+				}
 				continue
 			}
+			// a new connection doesn't listen yet
+			if err := r.listen(); err != nil {
+				log.WithError(err).Error("listener: LISTEN failed, retrying in 10s")
+				r.pgx.Close(context.Background())
+				r.pgx = nil
+				if !retry() {
+					return
+				}
+				continue
+			}
+			// changes while we were disconnected are lost
+			select {
+			case needRehash <- true:
+			case <-ctx.Done():
+				return
+			}
 		}
 
-		notification, err := r.pgx.WaitForNotification(context.Background())
+		notification, err := r.pgx.WaitForNotification(ctx)
 		if err != nil {
+			if ctx.Err() != nil {
+				// stopped by Close
+				return
+			}
 			log.WithError(err).Error("WaitForNotification failed, closing db connection")
+			atomic.StoreInt32(&r.listening, 0)
 			r.pgx.Close(context.Background())
 			r.pgx = nil
 			continue
@@ -278,15 +650,28 @@ func (r *SchedulerPopRepository) addFeedListener(addedFeeds chan<- uuid.UUID, ne
 			continue
 		}
 
+		// the scheduler doesn't receive anymore once it's shutting down
 		switch payload.Action {
 		case "INSERT":
-			addedFeeds <- payload.FeedID
+			select {
+			case addedFeeds <- payload.FeedID:
+			case <-ctx.Done():
+			}
 		case "UPDATE":
 			fallthrough
 		case "DELETE":
 			fallthrough
 		case "TRUNCATE":
-			needRehash <- true
+			select {
+			case needRehash <- true:
+			case <-ctx.Done():
+			}
+		case "REFRESH":
+			// sent by the api, not by a trigger
+			select {
+			case refreshRequests <- scheduler.RefreshRequest{FeedID: payload.FeedID, UserID: payload.UserID}:
+			case <-ctx.Done():
+			}
 		default:
 			log.WithField("action", payload.Action).Warn("ignoring payload with unhandled action")
 		}
@@ -1,10 +1,18 @@
 package rabbitmq
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+
 	"github.com/apex/log"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// userEventsExchange routes the user events by user id. It's a new name because the exchange used to be a fanout
+// called "user_events" and rabbitmq refuses to redeclare an existing exchange with another type.
+const userEventsExchange = "user_events_direct"
+
 // struct for setup code shared between publisher/consumer side
 type rabbitMQConnection struct {
 	connection *amqp.Connection
@@ -33,10 +41,41 @@ func rabbitMQConnect(addr string) (ret rabbitMQConnection, err error) {
 	return
 }
 
-// declareUserEventsExchange creates the exchange between rueder api and events api
+// pingConnection checks if the connection and channel are still open
+func pingConnection(connection *amqp.Connection, channel *amqp.Channel) error {
+	if connection == nil || connection.IsClosed() {
+		return errors.New("rabbitmq connection closed")
+	}
+	if channel == nil || channel.IsClosed() {
+		return errors.New("rabbitmq channel closed")
+	}
+	return nil
+}
+
+// declareUserEventsExchange creates the exchange between rueder api and events api.
+// Messages are routed by user id.
 func (r *rabbitMQConnection) declareUserEventsExchange() (err error) {
 	err = r.channel.ExchangeDeclare(
-		"user_events", // name
+		userEventsExchange, // name
+		"direct",           // type
+		false,              // durable
+		false,              // auto-deleted
+		false,              // internal
+		false,              // no-wait
+		nil,                // arguments
+	)
+	if err != nil {
+		log.WithError(err).Error("couldn't declare exchange")
+		return nil
+	}
+
+	return
+}
+
+// declareFeedEventsExchange creates the exchange between worker and events api
+func (r *rabbitMQConnection) declareFeedEventsExchange() (err error) {
+	err = r.channel.ExchangeDeclare(
+		"feed_events", // name
 		"fanout",      // type
 		false,         // durable
 		false,         // auto-deleted
@@ -46,8 +85,28 @@ func (r *rabbitMQConnection) declareUserEventsExchange() (err error) {
 	)
 	if err != nil {
 		log.WithError(err).Error("couldn't declare exchange")
-		return nil
+		return
+	}
+
+	return
+}
+
+// publishJSON serializes the message and publishes it to the exchange
+func publishJSON(ctx context.Context, channel *amqp.Channel, exchange string, routingKey string, message interface{}) (err error) {
+	messageBody, err := json.Marshal(message)
+	if err != nil {
+		return
 	}
 
+	err = channel.PublishWithContext(
+		ctx,
+		exchange,   // exchange
+		routingKey, // routing key
+		false,      // mandatory
+		false,      // immediate
+		amqp.Publishing{
+			ContentType: "application/json", // doesn't matter because rabbitmq ignores it
+			Body:        messageBody,
+		})
 	return
 }
@@ -1,6 +1,7 @@
 package rabbitmq
 
 import (
+	"context"
 	"encoding/json"
 
 	"github.com/apex/log"
@@ -28,6 +29,10 @@ func NewEventConsumerRepository(addr string) *EventConsumerRepository {
 	if err != nil {
 		return nil
 	}
+	err = r.declareFeedEventsExchange()
+	if err != nil {
+		return nil
+	}
 
 	return &EventConsumerRepository{
 		connection: r.connection,
@@ -49,9 +54,9 @@ func (r *EventConsumerRepository) ConnectUser(uuid uuid.UUID) (ret controller.Us
 	}
 
 	err = r.channel.QueueBind(
-		q.Name,        // queue name
-		uuid.String(), // routing key: this way we only receive data meant for this user
-		"user_events", // exchange
+		q.Name,             // queue name
+		uuid.String(),      // routing key: this way we only receive data meant for this user
+		userEventsExchange, // exchange
 		false,
 		nil,
 	)
@@ -105,6 +110,74 @@ func (r *EventConsumerRepository) ConnectUser(uuid uuid.UUID) (ret controller.Us
 	return
 }
 
+// ConsumeFeedEvents returns the events published by the workers. All events api instances share the same queue
+// so each event is only handled once.
+func (r *EventConsumerRepository) ConsumeFeedEvents() (ret <-chan common.FeedEventMessage, err error) {
+	q, err := r.channel.QueueDeclare(
+		"feed_events", // name
+		false,         // durable
+		false,         // delete when unused
+		false,         // exclusive
+		false,         // no-wait
+		nil,           // arguments
+	)
+	if err != nil {
+		return
+	}
+
+	err = r.channel.QueueBind(
+		q.Name,        // queue name
+		"",            // routing key
+		"feed_events", // exchange
+		false,
+		nil,
+	)
+	if err != nil {
+		return
+	}
+
+	msgs, err := r.channel.Consume(
+		q.Name, // queue
+		"",     // consumer
+		true,   // auto-ack
+		false,  // exclusive
+		false,  // no-local
+		false,  // no-wait
+		nil,    // args
+	)
+	if err != nil {
+		return
+	}
+
+	// wrap messages in our own data type. the channel is closed with the rabbitmq channel.
+	ownChannel := make(chan common.FeedEventMessage)
+	go func() {
+		defer close(ownChannel)
+		for msg := range msgs {
+			message := common.FeedEventMessage{}
+			err := json.Unmarshal(msg.Body, &message)
+			if err != nil {
+				log.WithError(err).Error("failed deserializing feed event")
+				continue
+			}
+			ownChannel <- message
+		}
+	}()
+
+	ret = ownChannel
+	return
+}
+
+// PublishUserEvent sends the message to all connected clients of the user
+func (r *EventConsumerRepository) PublishUserEvent(userID uuid.UUID, payload common.UserEventMessage) error {
+	return publishJSON(context.Background(), r.channel, userEventsExchange, userID.String(), payload)
+}
+
+// Ping checks if rabbitmq is usable
+func (r *EventConsumerRepository) Ping() error {
+	return pingConnection(r.connection, r.channel)
+}
+
 func (r *EventConsumerRepository) Close() {
 	if r.channel != nil {
 		r.channel.Close()
@@ -2,10 +2,12 @@ package rabbitmq
 
 import (
 	"context"
-	"encoding/json"
 
 	"github.com/apex/log"
+	"github.com/gofrs/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/spezifisch/rueder3/backend/internal/common"
 	"github.com/spezifisch/rueder3/backend/pkg/api/controller"
 )
 
@@ -16,6 +18,7 @@ type EventPublisherRepository struct {
 	context    context.Context
 
 	eventInput          chan controller.UserEventEnvelope
+	feedEventInput      chan common.FeedEventMessage
 	eventClose          chan struct{}
 	eventHandlerRunning bool
 }
@@ -32,12 +35,17 @@ func NewEventPublisherRepository(addr string) *EventPublisherRepository {
 	if err != nil {
 		return nil
 	}
+	err = r.declareFeedEventsExchange()
+	if err != nil {
+		return nil
+	}
 
 	ownChannel := make(chan controller.UserEventEnvelope)
 	closeChannel := make(chan struct{})
 	return &EventPublisherRepository{
 		// internal endpoints of channels
 		eventInput:          ownChannel,
+		feedEventInput:      make(chan common.FeedEventMessage),
 		eventClose:          closeChannel,
 		eventHandlerRunning: false,
 
@@ -52,6 +60,19 @@ func (r *EventPublisherRepository) Publish(envelope *controller.UserEventEnvelop
 	r.eventInput <- *envelope
 }
 
+// PublishUserEvent puts the message in the event queue to be sent to all connected clients of the user
+func (r *EventPublisherRepository) PublishUserEvent(userID uuid.UUID, payload common.UserEventMessage) {
+	r.eventInput <- controller.UserEventEnvelope{
+		UserID:  userID,
+		Payload: payload,
+	}
+}
+
+// PublishFeedEvent puts the message in the event queue to be sent to all subscribers of the feed
+func (r *EventPublisherRepository) PublishFeedEvent(message *common.FeedEventMessage) {
+	r.feedEventInput <- *message
+}
+
 // HandleEvents should be run as a goroutine to handle passing messages to rabbitmq
 func (r *EventPublisherRepository) HandleEvents() {
 	if r.eventHandlerRunning {
@@ -67,6 +88,12 @@ func (r *EventPublisherRepository) HandleEvents() {
 			if err != nil {
 				log.WithError(err).WithField("userID", envelope.UserID).Error("couldn't publish message")
 			}
+		case message := <-r.feedEventInput:
+			// the events api looks up the subscribers of the feed
+			err := publishJSON(r.context, r.channel, "feed_events", "", message)
+			if err != nil {
+				log.WithError(err).WithField("feedID", message.FeedID).Error("couldn't publish feed event")
+			}
 		case <-r.eventClose:
 			r.eventHandlerRunning = false
 			return
@@ -77,23 +104,12 @@ func (r *EventPublisherRepository) HandleEvents() {
 func (r *EventPublisherRepository) publishUserEvent(envelope controller.UserEventEnvelope) (err error) {
 	// use userid as routing key so all connected clients (if any) of this user receive the same event
 	routingKey := envelope.UserID.String()
-	// serialize payload
-	messageBody, err := json.Marshal(envelope.Payload)
-	if err != nil {
-		return
-	}
+	return publishJSON(r.context, r.channel, userEventsExchange, routingKey, envelope.Payload)
+}
 
-	err = r.channel.PublishWithContext(
-		r.context,
-		"user_events", // exchange
-		routingKey,    // routing key (user id)
-		false,         // mandatory
-		false,         // immediate
-		amqp.Publishing{
-			ContentType: "application/json", // doesn't matter because rabbitmq ignores it
-			Body:        messageBody,
-		})
-	return
+// Ping checks if rabbitmq is usable
+func (r *EventPublisherRepository) Ping() error {
+	return pingConnection(r.connection, r.channel)
 }
 
 // Close connection to rabbitmq
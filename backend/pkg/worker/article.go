@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+
+	readability "github.com/go-shiori/go-readability"
+
+	"github.com/spezifisch/rueder3/backend/pkg/httputil"
+)
+
+// errArticleTooLarge is returned by fetchFullArticle if the page exceeds FullArticleMaxSize
+var errArticleTooLarge = errors.New("article page too large")
+
+// publicArticleClient downloads article pages. The links come from feeds, so only public addresses are allowed,
+// also as redirect targets. The request context has the actual timeout.
+var publicArticleClient = httputil.NewPublicHTTPClient(DefaultFeedWorkerConfig.FullArticleTimeout)
+
+// fetchFullArticle downloads the article page and extracts its main content with readability.
+// The returned html is unsanitized and has to go through the same sanitizer as the feed content.
+func (p FeedWorkerPool) fetchFullArticle(link string) (content string, err error) {
+	pageURL, err := url.Parse(link)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.FullArticleTimeout)
+	defer cancel()
+
+	// the article pages are usually on the feed's host, so they get the same limits
+	release, err := p.pageLimiter.acquire(ctx, pageURL.Hostname())
+	if err != nil {
+		return
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("User-Agent", p.config.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	client := p.articleClient
+	if client == nil {
+		client = publicArticleClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = fmt.Errorf("http error: %s", resp.Status)
+		return
+	}
+	if mediaType, _, e := mime.ParseMediaType(resp.Header.Get("Content-Type")); e == nil &&
+		mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		err = fmt.Errorf("not an html page: %s", mediaType)
+		return
+	}
+	if resp.ContentLength > p.config.FullArticleMaxSize {
+		err = errArticleTooLarge
+		return
+	}
+
+	// read one byte more than allowed to notice if the page is too large
+	body, err := io.ReadAll(io.LimitReader(resp.Body, p.config.FullArticleMaxSize+1))
+	if err != nil {
+		return
+	}
+	if int64(len(body)) > p.config.FullArticleMaxSize {
+		err = errArticleTooLarge
+		return
+	}
+
+	// the final URL after redirects is needed to resolve relative links
+	if resp.Request != nil && resp.Request.URL != nil {
+		pageURL = resp.Request.URL
+	}
+
+	parser := readability.NewParser()
+	article, err := parser.Parse(bytes.NewReader(body), pageURL)
+	if err != nil {
+		return
+	}
+
+	content = article.Content
+	return
+}
@@ -0,0 +1,205 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/spezifisch/rueder3/backend/pkg/httputil"
+	"github.com/spezifisch/rueder3/backend/pkg/worker/scheduler"
+)
+
+const testArticlePage = `<!DOCTYPE html>
+<html><head><title>Some Article</title></head>
+<body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<article>
+<h1>Some Article</h1>
+<p>This is the first paragraph of the article text, long enough for readability to consider it the main content of the page.</p>
+<p>This is the second paragraph with a <a href="/relative">relative link</a> and even more text so that the scoring works out fine.</p>
+<p>And a third paragraph, because articles usually have more than two paragraphs and the extractor likes some text to work with.</p>
+</article>
+<footer>Copyright somebody</footer>
+</body></html>`
+
+func TestFeedWorkerPool_fetchFullArticle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte(testArticlePage))
+		case "/large":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(strings.Repeat("<p>too much</p>", 1000)))
+		case "/pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			_, _ = w.Write([]byte("%PDF-1.4"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := FeedWorkerPool{
+		config: DefaultFeedWorkerConfig,
+		// the test server is on localhost
+		articleClient: &http.Client{},
+	}
+	p.config.FullArticleTimeout = 5 * time.Second
+	p.config.FullArticleMaxSize = 4096
+
+	content, err := p.fetchFullArticle(server.URL + "/article")
+	assert.NoError(t, err)
+	assert.Contains(t, content, "first paragraph of the article text")
+	assert.NotContains(t, content, "About")
+	// relative links are resolved against the page URL
+	assert.Contains(t, content, server.URL+"/relative")
+
+	_, err = p.fetchFullArticle(server.URL + "/large")
+	assert.ErrorIs(t, err, errArticleTooLarge)
+
+	_, err = p.fetchFullArticle(server.URL + "/pdf")
+	assert.Error(t, err)
+
+	_, err = p.fetchFullArticle(server.URL + "/missing")
+	assert.Error(t, err)
+}
+
+// redirectTransport answers requests for example.com with a redirect to target and sends all others on
+type redirectTransport struct {
+	target string
+	next   http.RoundTripper
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == "example.com" {
+		return &http.Response{
+			StatusCode: http.StatusFound,
+			Header:     http.Header{"Location": []string{t.target}},
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+	return t.next.RoundTrip(req)
+}
+
+func TestFeedWorkerPool_fetchFullArticlePrivate(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(testArticlePage))
+	}))
+	defer server.Close()
+
+	p := FeedWorkerPool{
+		config: DefaultFeedWorkerConfig,
+	}
+	p.config.FullArticleTimeout = 5 * time.Second
+
+	// feeds can't make the worker fetch local pages
+	_, err := p.fetchFullArticle(server.URL + "/article")
+	assert.ErrorIs(t, err, httputil.ErrForbiddenAddress)
+	_, err = p.fetchFullArticle(strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/article")
+	assert.ErrorIs(t, err, httputil.ErrForbiddenAddress)
+
+	// not even by redirecting there from a public page
+	p.articleClient = &http.Client{Transport: &redirectTransport{
+		target: server.URL + "/article",
+		next:   publicArticleClient.Transport,
+	}}
+	_, err = p.fetchFullArticle("http://example.com/article")
+	assert.ErrorIs(t, err, httputil.ErrForbiddenAddress)
+
+	assert.Zero(t, atomic.LoadInt32(&requests))
+}
+
+func TestFeedWorkerPool_processArticles_FullArticleLimit(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(testArticlePage))
+	}))
+	defer server.Close()
+
+	// five new articles, the newest is the last one
+	feed := &gofeed.Feed{}
+	posted := time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		published := posted.Add(time.Duration(i) * time.Hour)
+		feed.Items = append(feed.Items, &gofeed.Item{
+			GUID:            fmt.Sprintf("article-%d", i),
+			Link:            fmt.Sprintf("%s/article-%d", server.URL, i),
+			Title:           fmt.Sprintf("Article %d", i),
+			Content:         "feed content",
+			PublishedParsed: &published,
+		})
+	}
+
+	repo := &mockRepository{
+		t:              t,
+		allArticlesNew: true,
+	}
+	p := FeedWorkerPool{
+		config:        DefaultFeedWorkerConfig,
+		repository:    repo,
+		articleClient: &http.Client{},
+	}
+	p.config.FullArticleMaxPerFetch = 2
+	p.SetHostLimits(scheduler.HostLimitConfig{MaxConcurrentPerHost: 1, MinHostInterval: 10 * time.Millisecond})
+
+	added, err := p.processArticles(&scheduler.Feed{FetchFullArticle: true}, feed)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, added)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// only the two newest articles got their page
+	for i, article := range repo.addedArticles {
+		if i < 3 {
+			assert.Equal(t, "feed content", article.Text, article.Title)
+		} else {
+			assert.Contains(t, article.Text, "first paragraph of the article text", article.Title)
+		}
+	}
+}
+
+func Test_pageLimiter(t *testing.T) {
+	l := newPageLimiter(scheduler.HostLimitConfig{MaxConcurrentPerHost: 1, MinHostInterval: 50 * time.Millisecond})
+
+	start := time.Now()
+	release, err := l.acquire(context.Background(), "Example.com")
+	assert.NoError(t, err)
+
+	// the host is busy until the first download is done
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	_, err = l.acquire(ctx, "example.com")
+	cancel()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// other hosts aren't limited
+	otherRelease, err := l.acquire(context.Background(), "example.org")
+	assert.NoError(t, err)
+	otherRelease()
+
+	// after the first download the interval still has to pass
+	release()
+	release, err = l.acquire(context.Background(), "example.com")
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	release()
+
+	// without limiter nothing is limited
+	var noLimiter *pageLimiter
+	release, err = noLimiter.acquire(context.Background(), "example.com")
+	assert.NoError(t, err)
+	release()
+}
@@ -6,13 +6,16 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"net/http"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/apex/log"
 	"github.com/mmcdole/gofeed"
+	"github.com/spezifisch/rueder3/backend/internal/common"
 	"github.com/spezifisch/rueder3/backend/pkg/helpers"
 	"github.com/spezifisch/rueder3/backend/pkg/worker/scheduler"
 	"github.com/sym01/htmlsanitizer"
@@ -22,6 +25,17 @@ import (
 type FeedWorkerPool struct {
 	config     FeedWorkerConfig
 	repository scheduler.Repository
+	// optional, tells subscribers about new articles and fetch errors
+	feedEventPublisher FeedEventPublisher
+	// optional, applies the host limits to article pages
+	pageLimiter *pageLimiter
+	// downloads article pages, nil uses publicArticleClient. Only tests need another one.
+	articleClient *http.Client
+}
+
+// FeedEventPublisher sends events about a feed to all of its subscribers
+type FeedEventPublisher interface {
+	PublishFeedEvent(*common.FeedEventMessage)
 }
 
 // FeedWorkerConfig configures fetching parameters
@@ -31,6 +45,15 @@ type FeedWorkerConfig struct {
 	MinimumFetchDelay time.Duration
 	MaximumFetchDelay time.Duration
 	FetchJitterS      int
+	// upper limit for delays requested by servers with Retry-After
+	MaximumRetryAfter time.Duration
+
+	// limits for downloading article pages of feeds with FetchFullArticle.
+	// the timeout includes waiting for the host limits.
+	FullArticleTimeout time.Duration
+	FullArticleMaxSize int64
+	// maximum number of article pages downloaded per feed fetch
+	FullArticleMaxPerFetch int
 }
 
 // DefaultFeedWorkerConfig has usable default values
@@ -41,6 +64,11 @@ var DefaultFeedWorkerConfig = FeedWorkerConfig{
 	MinimumFetchDelay: 15 * time.Minute,
 	MaximumFetchDelay: 12 * time.Hour,
 	FetchJitterS:      30,
+	MaximumRetryAfter: 48 * time.Hour,
+
+	FullArticleTimeout:     20 * time.Second,
+	FullArticleMaxSize:     5 * 1024 * 1024,
+	FullArticleMaxPerFetch: 10,
 }
 
 // NewFeedWorkerPool creates a worker pool with the given Repo backend
@@ -51,6 +79,17 @@ func NewFeedWorkerPool(repository scheduler.Repository) *FeedWorkerPool {
 	}
 }
 
+// SetFeedEventPublisher enables events for new articles and fetch errors. Call it before starting the workers.
+func (p *FeedWorkerPool) SetFeedEventPublisher(publisher FeedEventPublisher) {
+	p.feedEventPublisher = publisher
+}
+
+// SetHostLimits applies the scheduler's host limits to the article pages of feeds with FetchFullArticle.
+// Call it before starting the workers.
+func (p *FeedWorkerPool) SetHostLimits(config scheduler.HostLimitConfig) {
+	p.pageLimiter = newPageLimiter(config)
+}
+
 // StartWorker is launches as a goroutine that fetches feeds
 func (p FeedWorkerPool) StartWorker(id int, feeds <-chan scheduler.Feed, doneFeeds chan<- scheduler.Feed) {
 	workerLog := log.WithField("worker", id)
@@ -69,26 +108,144 @@ func (p FeedWorkerPool) StartWorker(id int, feeds <-chan scheduler.Feed, doneFee
 		// report back that it's done
 		doneFeeds <- feed
 	}
+
+	workerLog.Info("FeedWorker stopped")
 }
 
-func (p FeedWorkerPool) fetchFeedURL(url string) (feed *gofeed.Feed, err error) {
+// errNotModified is returned by fetchFeedURL if the server answered with 304 Not Modified
+var errNotModified = errors.New("feed not modified")
+
+// maxRedirects is the same limit as the one of http.DefaultClient
+const maxRedirects = 10
+
+// httpStatusError is returned by fetchFeedURL if the server answered with an unsuccessful status code
+type httpStatusError struct {
+	gofeed.HTTPError
+	// when the server wants to be asked again according to Retry-After, zero if not given
+	RetryAt time.Time
+}
+
+// cachingValidators are sent with the next request so that the server can answer 304 Not Modified
+type cachingValidators struct {
+	ETag         string
+	LastModified string
+}
+
+// fetchFeedURL downloads and parses a feed. The given caching validators are sent with the request,
+// the ones from the response are returned.
+// If the request was only redirected permanently (301/308) the final URL is returned as movedURL.
+func (p FeedWorkerPool) fetchFeedURL(url string, sent cachingValidators) (feed *gofeed.Feed, movedURL string, received cachingValidators, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), p.config.HTTPTimeout)
 	defer cancel()
 
+	permanentRedirect := true
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.Response == nil || (req.Response.StatusCode != http.StatusMovedPermanently && req.Response.StatusCode != http.StatusPermanentRedirect) {
+				permanentRedirect = false
+			}
+			return nil
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("User-Agent", p.config.UserAgent)
+	if sent.ETag != "" {
+		req.Header.Set("If-None-Match", sent.ETag)
+	}
+	if sent.LastModified != "" {
+		req.Header.Set("If-Modified-Since", sent.LastModified)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if permanentRedirect && resp.Request.URL.String() != url {
+		movedURL = resp.Request.URL.String()
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		// servers may send updated validators with a 304
+		received = sent
+		updateCachingValidators(&received, resp.Header)
+		err = errNotModified
+		return
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		statusErr := httpStatusError{
+			HTTPError: gofeed.HTTPError{
+				StatusCode: resp.StatusCode,
+				Status:     resp.Status,
+			},
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			statusErr.RetryAt = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		err = statusErr
+		return
+	}
+
 	fp := gofeed.NewParser()
-	fp.UserAgent = p.config.UserAgent
+	feed, err = fp.Parse(resp.Body)
+	if err != nil {
+		return
+	}
 
-	feed, err = fp.ParseURLWithContext(url, ctx)
+	// only validators of responses we could actually parse are returned
+	received.ETag = resp.Header.Get("ETag")
+	received.LastModified = resp.Header.Get("Last-Modified")
 	return
 }
 
-func (p FeedWorkerPool) fetchFeedTryingHTTPS(f *scheduler.Feed) (feed *gofeed.Feed, err error) {
+// parseRetryAfter returns the time given by a Retry-After header, which is either in seconds or a date.
+// It returns the zero time if the header is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return time.Time{}
+		}
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date
+	}
+	return time.Time{}
+}
+
+// updateCachingValidators takes over the caching headers that are present in the given response headers
+func updateCachingValidators(validators *cachingValidators, header http.Header) {
+	if etag := header.Get("ETag"); etag != "" {
+		validators.ETag = etag
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		validators.LastModified = lastModified
+	}
+}
+
+// fetchFeedTryingHTTPS fetches the feed and returns the caching validators for the next fetch. They aren't
+// stored in the feed yet, because they must only be used once the articles are stored.
+func (p FeedWorkerPool) fetchFeedTryingHTTPS(f *scheduler.Feed) (feed *gofeed.Feed, validators cachingValidators, err error) {
 	// if it's an HTTP URL try using HTTPS first
 	if helpers.IsHTTPURL(f.FeedURL) {
 		feedLog := log.WithField("feed_id", f.ID)
 
+		// the caching validators belong to the http URL, so we don't send them here
 		httpsURL := helpers.RewriteToHTTPS(f.FeedURL)
-		feed, err = p.fetchFeedURL(httpsURL)
+		var movedURL string
+		feed, movedURL, validators, err = p.fetchFeedURL(httpsURL, cachingValidators{})
 		if err != nil {
 			feedLog.Info("feed was not reachable via https")
 		} else {
@@ -96,6 +253,9 @@ func (p FeedWorkerPool) fetchFeedTryingHTTPS(f *scheduler.Feed) (feed *gofeed.Fe
 				feedLog.Info("feed was reachable via https, updating feed info")
 				// the change gets saved at the end of fetchFeed
 				f.FeedURL = httpsURL
+				if movedURL != "" {
+					f.FeedURL = movedURL
+				}
 				return
 			}
 
@@ -104,15 +264,31 @@ func (p FeedWorkerPool) fetchFeedTryingHTTPS(f *scheduler.Feed) (feed *gofeed.Fe
 		}
 	}
 
-	feed, err = p.fetchFeedURL(f.FeedURL)
+	feed, movedURL, validators, err := p.fetchFeedURL(f.FeedURL, cachingValidators{
+		ETag:         f.FetcherState.ETag,
+		LastModified: f.FetcherState.LastModified,
+	})
+	if movedURL != "" && (err == nil || errors.Is(err, errNotModified)) {
+		// like the https upgrade this gets saved at the end of fetchFeed
+		log.WithFields(log.Fields{"feed_id": f.ID, "from": f.FeedURL, "to": movedURL}).Info("feed moved permanently, updating feed url")
+		f.FeedURL = movedURL
+	}
 	return
 }
 
 func (p FeedWorkerPool) fetchFeed(f *scheduler.Feed) (err error) {
 	fetchedAt := time.Now()
 	f.FetcherState.FetchedAt = fetchedAt // ensure it's updated to avoid endless immediate requeueing of the job
+	wasWorking := f.FetcherState.Working
+
+	outcome := fetchOutcomeSuccess
+	defer func() {
+		metricFetches.WithLabelValues(outcome).Inc()
+		metricFetchDuration.Observe(time.Since(fetchedAt).Seconds())
+	}()
 
 	if !helpers.IsURL(f.FeedURL) {
+		outcome = fetchOutcomeInvalidURL
 		f.FetcherState.Working = false
 		f.FetcherState.LastError = time.Now().Round(time.Second)
 		f.FetcherState.Message = "Invalid URL"
@@ -120,39 +296,184 @@ func (p FeedWorkerPool) fetchFeed(f *scheduler.Feed) (err error) {
 		if e := p.repository.UpdateFeedInfo(f.ID, f); e != nil {
 			log.WithError(e).Error("couldn't update feed info after url error")
 		}
+		if wasWorking {
+			p.publishFeedError(f)
+		}
 
 		err = errors.New("feed has invalid url")
 		return
 	}
 
 	// fetch feed
-	parsedFeed, err := p.fetchFeedTryingHTTPS(f)
+	parsedFeed, validators, err := p.fetchFeedTryingHTTPS(f)
+	if errors.Is(err, errNotModified) {
+		// nothing new, but the feed works
+		outcome = fetchOutcomeNotModified
+		log.WithField("feed_id", f.ID).Info("feed not modified")
+		p.markFeedWorking(f)
+		f.FetcherState.ETag = validators.ETag
+		f.FetcherState.LastModified = validators.LastModified
+
+		err = p.repository.UpdateFeedInfo(f.ID, f)
+		return
+	}
 	if err != nil {
-		f.FetcherState.Working = false
-		f.FetcherState.LastError = time.Now().Round(time.Second)
-		f.FetcherState.Message = fmt.Sprintf("Fetcher Error: %s", err)
+		outcome = fetchErrorOutcome(err)
+		p.markFeedFailed(f, err)
 
 		if e := p.repository.UpdateFeedInfo(f.ID, f); e != nil {
 			log.WithError(e).Error("couldn't update feed info after fetcher error")
 		}
+		if wasWorking {
+			p.publishFeedError(f)
+		}
 
 		return // with original err
 	}
 
 	// parse articles
-	p.processArticles(f, parsedFeed)
+	addedCount, articlesErr := p.processArticles(f, parsedFeed)
+	metricNewArticles.Observe(float64(addedCount))
+
+	// a 304 for this response would skip the articles that couldn't be stored,
+	// so its validators are only used if all of them were stored
+	if articlesErr == nil {
+		f.FetcherState.ETag = validators.ETag
+		f.FetcherState.LastModified = validators.LastModified
+	} else {
+		outcome = fetchOutcomeError
+		f.FetcherState.ETag = ""
+		f.FetcherState.LastModified = ""
+	}
 
 	// write updated feed info to repository
 	p.updateFeedFields(f, parsedFeed)
 
 	err = p.repository.UpdateFeedInfo(f.ID, f)
+	if addedCount > 0 {
+		p.publishNewArticles(f, addedCount)
+	}
+	if err == nil {
+		err = articlesErr
+	}
 	return
 }
 
-func (p FeedWorkerPool) updateFeedFields(storedFeed *scheduler.Feed, parsedFeed *gofeed.Feed) {
-	storedFeed.FetcherState.Working = true
-	storedFeed.FetcherState.LastSuccess = time.Now().Round(time.Second)
+// publishNewArticles tells the subscribers of the feed that articles were added
+func (p FeedWorkerPool) publishNewArticles(f *scheduler.Feed, count int) {
+	if p.feedEventPublisher == nil {
+		return
+	}
+
+	maxSeq, err := p.repository.LatestArticleSeq(f.ID)
+	if err != nil {
+		log.WithError(err).WithField("feed_id", f.ID).Error("couldn't get latest article seq")
+		return
+	}
+
+	p.feedEventPublisher.PublishFeedEvent(&common.FeedEventMessage{
+		FeedID: f.ID,
+		Payload: common.UserEventMessage{
+			Type: common.MessageTypeNewArticles,
+			Data: map[string]string{
+				"feed_id": f.ID.String(),
+				"count":   strconv.Itoa(count),
+				"max_seq": strconv.Itoa(maxSeq),
+			},
+		},
+	})
+}
+
+// publishFeedError tells the subscribers of the feed that it stopped working
+func (p FeedWorkerPool) publishFeedError(f *scheduler.Feed) {
+	if p.feedEventPublisher == nil {
+		return
+	}
+
+	p.feedEventPublisher.PublishFeedEvent(&common.FeedEventMessage{
+		FeedID: f.ID,
+		Payload: common.UserEventMessage{
+			Type: common.MessageTypeFeedError,
+			Data: map[string]string{
+				"feed_id": f.ID.String(),
+				"message": f.FetcherState.Message,
+			},
+		},
+	})
+}
+
+// fetchErrorOutcome categorizes fetch errors for metricFetches
+func fetchErrorOutcome(err error) string {
+	var statusErr httpStatusError
+	if !errors.As(err, &statusErr) {
+		return fetchOutcomeError
+	}
+
+	switch statusErr.StatusCode {
+	case http.StatusGone:
+		return fetchOutcomeGone
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return fetchOutcomeRateLimited
+	default:
+		return fetchOutcomeHTTPError
+	}
+}
+
+// markFeedWorking updates the feed state after a successful fetch
+func (p FeedWorkerPool) markFeedWorking(f *scheduler.Feed) {
+	f.FetcherState.Working = true
+	f.FetcherState.LastSuccess = time.Now().Round(time.Second)
+	f.FetcherState.FailureCount = 0
+	f.FetcherState.BackoffUntil = time.Time{}
+	f.FetcherState.Dead = false
+
+	// update fetch delay
+	p.updateFetchDelay(f)
+}
+
+// markFeedFailed updates the feed state after a failed fetch and delays the next fetch
+func (p FeedWorkerPool) markFeedFailed(f *scheduler.Feed, err error) {
+	f.FetcherState.Working = false
+	f.FetcherState.LastError = time.Now().Round(time.Second)
+	f.FetcherState.Message = fmt.Sprintf("Fetcher Error: %s", err)
+	f.FetcherState.FailureCount++
+
+	var statusErr httpStatusError
+	isStatusErr := errors.As(err, &statusErr)
+	if isStatusErr && statusErr.StatusCode == http.StatusGone {
+		// the scheduler won't queue it again
+		f.FetcherState.Dead = true
+		return
+	}
 
+	// double the delay for every consecutive failure
+	delay := time.Duration(f.FetcherState.FetchDelayS) * time.Second
+	if delay < p.config.MinimumFetchDelay {
+		delay = p.config.MinimumFetchDelay
+	}
+	for i := 1; i < f.FetcherState.FailureCount && delay < p.config.MaximumFetchDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.config.MaximumFetchDelay {
+		delay = p.config.MaximumFetchDelay
+	}
+	backoffUntil := f.FetcherState.FetchedAt.Add(delay)
+
+	// respect Retry-After within reason
+	if isStatusErr && !statusErr.RetryAt.IsZero() {
+		retryAt := statusErr.RetryAt
+		if maxRetryAt := f.FetcherState.FetchedAt.Add(p.config.MaximumRetryAfter); retryAt.After(maxRetryAt) {
+			retryAt = maxRetryAt
+		}
+		if retryAt.After(backoffUntil) {
+			backoffUntil = retryAt
+		}
+	}
+
+	f.FetcherState.BackoffUntil = backoffUntil
+}
+
+func (p FeedWorkerPool) updateFeedFields(storedFeed *scheduler.Feed, parsedFeed *gofeed.Feed) {
 	if parsedFeed.Title != "" {
 		storedFeed.Title = parsedFeed.Title
 	}
@@ -169,8 +490,7 @@ func (p FeedWorkerPool) updateFeedFields(storedFeed *scheduler.Feed, parsedFeed
 	// also old.FeedURL might have been updated in fetchFeedTryingHTTPS.
 	// we don't need to do more in this case.
 
-	// update fetch delay
-	p.updateFetchDelay(storedFeed)
+	p.markFeedWorking(storedFeed)
 }
 
 func (p FeedWorkerPool) updateFetchDelay(feed *scheduler.Feed) {
@@ -234,7 +554,9 @@ func reverseFeedItems(feed *gofeed.Feed) {
 var regexpStyle = regexp.MustCompile(`<style[\S\s]+?<\/style>*`)
 var regexpScript = regexp.MustCompile(`<script[\S\s]+?<\/script>`)
 
-func (p FeedWorkerPool) processArticles(f *scheduler.Feed, feed *gofeed.Feed) {
+// processArticles adds the new articles of the feed and returns how many were added.
+// It returns an error if any of the new articles couldn't be stored.
+func (p FeedWorkerPool) processArticles(f *scheduler.Feed, feed *gofeed.Feed) (addedCount int, err error) {
 	if feed == nil || feed.Items == nil || len(feed.Items) == 0 {
 		log.WithField("id", f.ID).Info("no articles")
 		return
@@ -278,9 +600,18 @@ func (p FeedWorkerPool) processArticles(f *scheduler.Feed, feed *gofeed.Feed) {
 	exists, err := p.repository.CheckExistingArticles(f.ID, guids)
 	if err != nil {
 		log.WithError(err).Error("failed checking existing articles")
+		err = fmt.Errorf("checking existing articles failed: %w", err)
 		return
 	}
 
+	// only the newest articles get their page downloaded, the others keep the feed content
+	fullArticleSkip := -p.config.FullArticleMaxPerFetch
+	for i := range feed.Items {
+		if !exists[i] && guids[i] != "" {
+			fullArticleSkip++
+		}
+	}
+
 	// parse articles
 	for i, item := range feed.Items {
 		if exists[i] || guids[i] == "" {
@@ -325,15 +656,24 @@ func (p FeedWorkerPool) processArticles(f *scheduler.Feed, feed *gofeed.Feed) {
 			}
 		}
 
+		articleLog := log.WithFields(log.Fields{
+			"feed": f.ID,
+			"guid": article.SiteGUID})
+
+		// replace the feed's content with the article page if wanted
+		if f.FetchFullArticle && newArticleCount > fullArticleSkip && helpers.IsURL(article.Link) {
+			if text, err := p.fetchFullArticle(article.Link); err != nil {
+				articleLog.WithError(err).WithField("link", article.Link).Warn("failed fetching full article, using feed content")
+			} else if text != "" {
+				article.RawText = text
+			}
+		}
+
 		// set content to teaser if content is empty
 		if article.RawText == "" {
 			article.RawText = article.RawTeaser
 		}
 
-		articleLog := log.WithFields(log.Fields{
-			"feed": f.ID,
-			"guid": article.SiteGUID})
-
 		// strip all html from title and teaser
 		{
 			s := htmlsanitizer.NewHTMLSanitizer()
@@ -384,4 +724,10 @@ func (p FeedWorkerPool) processArticles(f *scheduler.Feed, feed *gofeed.Feed) {
 		"failed": failedArticleCount,
 		"broken": brokenArticleCount}).
 		Infof("got %d articles", articleCount)
+
+	addedCount = newArticleCount - failedArticleCount
+	if failedArticleCount > 0 {
+		err = fmt.Errorf("adding %d of %d new articles failed", failedArticleCount, newArticleCount)
+	}
+	return
 }
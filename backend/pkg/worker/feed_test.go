@@ -2,11 +2,15 @@ package worker
 
 import (
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/mmcdole/gofeed"
+	"github.com/spezifisch/rueder3/backend/internal/common"
 	"github.com/spezifisch/rueder3/backend/pkg/worker/scheduler"
 	"github.com/stretchr/testify/assert"
 )
@@ -50,6 +54,7 @@ type mockRepository struct {
 
 	// repository data
 	addedArticles []scheduler.Article
+	updatedFeeds  []scheduler.Feed
 }
 
 func (m *mockRepository) Feeds() ([]scheduler.Feed, error) {
@@ -58,11 +63,18 @@ func (m *mockRepository) Feeds() ([]scheduler.Feed, error) {
 func (m *mockRepository) GetFeed(feedID uuid.UUID) (scheduler.Feed, error) {
 	return scheduler.Feed{}, errors.New("not implemented")
 }
-func (m *mockRepository) RunFeedChangeListener(addedFeeds chan<- uuid.UUID, needRehash chan<- bool) (err error) {
+func (m *mockRepository) RunFeedChangeListener(addedFeeds chan<- uuid.UUID, needRehash chan<- bool, refreshRequests chan<- scheduler.RefreshRequest) (err error) {
 	return errors.New("not implemented")
 }
+func (m *mockRepository) CleanupOrphanedFeeds(retention time.Duration) (deleted int, archived int, err error) {
+	return 0, 0, errors.New("not implemented")
+}
+func (m *mockRepository) PruneArticles(maxAge time.Duration, maxPerFeed int, keepNewest int) (pruned int, err error) {
+	return 0, errors.New("not implemented")
+}
 func (m *mockRepository) UpdateFeedInfo(feedID uuid.UUID, updatedFeed *scheduler.Feed) (err error) {
-	return errors.New("not implemented")
+	m.updatedFeeds = append(m.updatedFeeds, *updatedFeed)
+	return nil
 }
 func (m *mockRepository) CheckExistingArticles(feedID uuid.UUID, articleGUIDs []string) (exists []bool, err error) {
 	if m.failCheckExistingArticles {
@@ -89,6 +101,17 @@ func (m *mockRepository) AddArticle(feedID uuid.UUID, article *scheduler.Article
 	m.addedArticles = append(m.addedArticles, *article)
 	return nil
 }
+func (m *mockRepository) LatestArticleSeq(feedID uuid.UUID) (seq int, err error) {
+	return len(m.addedArticles), nil
+}
+
+type mockFeedEventPublisher struct {
+	events []common.FeedEventMessage
+}
+
+func (m *mockFeedEventPublisher) PublishFeedEvent(message *common.FeedEventMessage) {
+	m.events = append(m.events, *message)
+}
 
 func TestFeedWorkerPool_processArticles(t *testing.T) {
 	f := &scheduler.Feed{}
@@ -249,3 +272,278 @@ func TestFeedWorkerPool_processArticles(t *testing.T) {
 		})
 	}
 }
+
+func TestFeedWorkerPool_fetchFeed_ConditionalGet(t *testing.T) {
+	feedData, err := os.ReadFile("../../test/data/golem.xml")
+	assert.NoError(t, err)
+
+	const etag = `"golem-1"`
+	const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag && r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		_, _ = w.Write(feedData)
+	}))
+	defer server.Close()
+
+	repo := &mockRepository{
+		t:              t,
+		allArticlesNew: true,
+	}
+	p := FeedWorkerPool{
+		config:     DefaultFeedWorkerConfig,
+		repository: repo,
+	}
+	p.config.HTTPTimeout = 5 * time.Second
+	// the https upgrade attempt fails because the test server only speaks http
+	f := &scheduler.Feed{
+		FeedURL: server.URL,
+	}
+
+	// first fetch gets the full feed and stores the validators
+	assert.NoError(t, p.fetchFeed(f))
+	assert.Equal(t, 3, len(repo.addedArticles))
+	assert.Equal(t, 1, len(repo.updatedFeeds))
+	assert.Equal(t, etag, repo.updatedFeeds[0].FetcherState.ETag)
+	assert.Equal(t, lastModified, repo.updatedFeeds[0].FetcherState.LastModified)
+	assert.True(t, repo.updatedFeeds[0].FetcherState.Working)
+
+	// second fetch sends them and gets a 304
+	f.FetcherState.Working = false
+	assert.NoError(t, p.fetchFeed(f))
+	assert.Equal(t, 3, len(repo.addedArticles), "no new articles expected")
+	assert.Equal(t, 2, len(repo.updatedFeeds))
+	assert.True(t, repo.updatedFeeds[1].FetcherState.Working)
+	assert.Equal(t, etag, repo.updatedFeeds[1].FetcherState.ETag)
+}
+
+func TestFeedWorkerPool_fetchFeed_ConditionalGetAfterFailure(t *testing.T) {
+	feedData, err := os.ReadFile("../../test/data/golem.xml")
+	assert.NoError(t, err)
+
+	const etag = `"golem-1"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write(feedData)
+	}))
+	defer server.Close()
+
+	repo := &mockRepository{
+		t:              t,
+		allArticlesNew: true,
+		failAddArticle: true,
+	}
+	p := FeedWorkerPool{
+		config:     DefaultFeedWorkerConfig,
+		repository: repo,
+	}
+	p.config.HTTPTimeout = 5 * time.Second
+	f := &scheduler.Feed{
+		FeedURL: server.URL,
+	}
+
+	// the articles couldn't be stored, so the validators must not be used
+	assert.Error(t, p.fetchFeed(f))
+	assert.Equal(t, 0, len(repo.addedArticles))
+	assert.Equal(t, 1, len(repo.updatedFeeds))
+	assert.Empty(t, repo.updatedFeeds[0].FetcherState.ETag)
+
+	// so the next fetch gets the full feed again
+	repo.failAddArticle = false
+	assert.NoError(t, p.fetchFeed(f))
+	assert.Equal(t, 3, len(repo.addedArticles))
+	assert.Equal(t, etag, repo.updatedFeeds[1].FetcherState.ETag)
+}
+
+func TestFeedWorkerPool_fetchFeed_Redirects(t *testing.T) {
+	feedData, err := os.ReadFile("../../test/data/golem.xml")
+	assert.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle("/moved", http.RedirectHandler("/permanent", http.StatusMovedPermanently))
+	mux.Handle("/permanent", http.RedirectHandler("/feed", http.StatusPermanentRedirect))
+	mux.Handle("/temporary", http.RedirectHandler("/feed", http.StatusFound))
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(feedData)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p := FeedWorkerPool{
+		config: DefaultFeedWorkerConfig,
+		repository: &mockRepository{
+			t:              t,
+			allArticlesNew: true,
+		},
+	}
+	p.config.HTTPTimeout = 5 * time.Second
+
+	// a chain of permanent redirects updates the url
+	f := &scheduler.Feed{FeedURL: server.URL + "/moved"}
+	assert.NoError(t, p.fetchFeed(f))
+	assert.Equal(t, server.URL+"/feed", f.FeedURL)
+
+	// a temporary redirect doesn't
+	f = &scheduler.Feed{FeedURL: server.URL + "/temporary"}
+	assert.NoError(t, p.fetchFeed(f))
+	assert.Equal(t, server.URL+"/temporary", f.FeedURL)
+}
+
+func TestFeedWorkerPool_fetchFeed_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/busy":
+			w.Header().Set("Retry-After", "7200")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	p := FeedWorkerPool{
+		config:     DefaultFeedWorkerConfig,
+		repository: &mockRepository{t: t},
+	}
+	p.config.HTTPTimeout = 5 * time.Second
+
+	f := &scheduler.Feed{FeedURL: server.URL + "/gone"}
+	assert.Error(t, p.fetchFeed(f))
+	assert.True(t, f.FetcherState.Dead)
+	assert.False(t, f.FetcherState.Working)
+
+	f = &scheduler.Feed{FeedURL: server.URL + "/busy"}
+	assert.Error(t, p.fetchFeed(f))
+	assert.False(t, f.FetcherState.Dead)
+	assert.Equal(t, 1, f.FetcherState.FailureCount)
+	assert.WithinDuration(t, f.FetcherState.FetchedAt.Add(2*time.Hour), f.FetcherState.BackoffUntil, time.Second)
+
+	f = &scheduler.Feed{FeedURL: server.URL + "/error"}
+	assert.Error(t, p.fetchFeed(f))
+	assert.Equal(t, f.FetcherState.FetchedAt.Add(p.config.MinimumFetchDelay), f.FetcherState.BackoffUntil)
+}
+
+func TestFeedWorkerPool_fetchFeed_FeedEvents(t *testing.T) {
+	feedData, err := os.ReadFile("../../test/data/golem.xml")
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(feedData)
+	}))
+	defer server.Close()
+
+	repo := &mockRepository{
+		t:              t,
+		allArticlesNew: true,
+	}
+	publisher := &mockFeedEventPublisher{}
+	p := &FeedWorkerPool{
+		config:     DefaultFeedWorkerConfig,
+		repository: repo,
+	}
+	p.config.HTTPTimeout = 5 * time.Second
+	p.SetFeedEventPublisher(publisher)
+
+	feedID := uuid.Must(uuid.NewV4())
+	f := &scheduler.Feed{
+		ID:      feedID,
+		FeedURL: server.URL + "/feed",
+	}
+
+	// new articles are announced
+	assert.NoError(t, p.fetchFeed(f))
+	if assert.Equal(t, 1, len(publisher.events)) {
+		event := publisher.events[0]
+		assert.Equal(t, feedID, event.FeedID)
+		assert.Equal(t, common.MessageTypeNewArticles, event.Payload.Type)
+		assert.Equal(t, feedID.String(), event.Payload.Data["feed_id"])
+		assert.Equal(t, "3", event.Payload.Data["count"])
+		assert.Equal(t, "3", event.Payload.Data["max_seq"])
+	}
+
+	// nothing new, nothing to announce
+	repo.allArticlesNew = false
+	assert.NoError(t, p.fetchFeed(f))
+	assert.Equal(t, 1, len(publisher.events))
+
+	// the feed breaks
+	f.FeedURL = server.URL + "/error"
+	assert.Error(t, p.fetchFeed(f))
+	if assert.Equal(t, 2, len(publisher.events)) {
+		event := publisher.events[1]
+		assert.Equal(t, common.MessageTypeFeedError, event.Payload.Type)
+		assert.Equal(t, f.FetcherState.Message, event.Payload.Data["message"])
+	}
+
+	// it's only announced when it stops working
+	assert.Error(t, p.fetchFeed(f))
+	assert.Equal(t, 2, len(publisher.events))
+}
+
+func TestFeedWorkerPool_markFeedFailed_Backoff(t *testing.T) {
+	p := FeedWorkerPool{
+		config: DefaultFeedWorkerConfig,
+	}
+	fetchedAt := time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC)
+	f := &scheduler.Feed{
+		FetcherState: scheduler.FeedFetcherState{
+			FetchedAt:   fetchedAt,
+			FetchDelayS: 3600,
+		},
+	}
+
+	wantDelays := []time.Duration{1 * time.Hour, 2 * time.Hour, 4 * time.Hour, 8 * time.Hour, 12 * time.Hour, 12 * time.Hour}
+	for i, wantDelay := range wantDelays {
+		p.markFeedFailed(f, errors.New("some error"))
+		assert.Equal(t, i+1, f.FetcherState.FailureCount)
+		assert.Equal(t, fetchedAt.Add(wantDelay), f.FetcherState.BackoffUntil, "failure %d", i+1)
+	}
+
+	// a Retry-After later than the backoff wins but is limited
+	p.markFeedFailed(f, httpStatusError{RetryAt: fetchedAt.Add(24 * time.Hour)})
+	assert.Equal(t, fetchedAt.Add(24*time.Hour), f.FetcherState.BackoffUntil)
+	p.markFeedFailed(f, httpStatusError{RetryAt: fetchedAt.Add(30 * 24 * time.Hour)})
+	assert.Equal(t, fetchedAt.Add(p.config.MaximumRetryAfter), f.FetcherState.BackoffUntil)
+
+	// success resets it
+	p.repository = &mockRepository{t: t}
+	p.markFeedWorking(f)
+	assert.Equal(t, 0, f.FetcherState.FailureCount)
+	assert.True(t, f.FetcherState.BackoffUntil.IsZero())
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, now.Add(120*time.Second), parseRetryAfter("120", now))
+	assert.Equal(t, time.Date(2022, 2, 1, 8, 0, 0, 0, time.UTC), parseRetryAfter("Tue, 01 Feb 2022 08:00:00 GMT", now).UTC())
+	assert.True(t, parseRetryAfter("", now).IsZero())
+	assert.True(t, parseRetryAfter("-5", now).IsZero())
+	assert.True(t, parseRetryAfter("soon", now).IsZero())
+}
+
+func Test_fetchErrorOutcome(t *testing.T) {
+	statusErr := func(code int) error {
+		return httpStatusError{HTTPError: gofeed.HTTPError{StatusCode: code}}
+	}
+
+	assert.Equal(t, fetchOutcomeGone, fetchErrorOutcome(statusErr(http.StatusGone)))
+	assert.Equal(t, fetchOutcomeRateLimited, fetchErrorOutcome(statusErr(http.StatusTooManyRequests)))
+	assert.Equal(t, fetchOutcomeRateLimited, fetchErrorOutcome(statusErr(http.StatusServiceUnavailable)))
+	assert.Equal(t, fetchOutcomeHTTPError, fetchErrorOutcome(statusErr(http.StatusNotFound)))
+	assert.Equal(t, fetchOutcomeError, fetchErrorOutcome(errors.New("connection refused")))
+}
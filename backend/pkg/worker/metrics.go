@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// fetch outcomes for metricFetches
+const (
+	fetchOutcomeSuccess     = "success"
+	fetchOutcomeNotModified = "not_modified"
+	fetchOutcomeInvalidURL  = "invalid_url"
+	fetchOutcomeGone        = "gone"
+	fetchOutcomeRateLimited = "rate_limited"
+	fetchOutcomeHTTPError   = "http_error"
+	fetchOutcomeError       = "error"
+)
+
+var (
+	metricFetches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rueder",
+		Subsystem: "worker",
+		Name:      "fetches_total",
+		Help:      "Number of feed fetches by outcome.",
+	}, []string{"outcome"})
+
+	metricFetchDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "rueder",
+		Subsystem: "worker",
+		Name:      "fetch_duration_seconds",
+		Help:      "Duration of feed fetches including article processing.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	})
+
+	metricNewArticles = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "rueder",
+		Subsystem: "worker",
+		Name:      "new_articles_per_fetch",
+		Help:      "Number of articles added by a successful feed fetch.",
+		Buckets:   []float64{0, 1, 2, 5, 10, 20, 50, 100},
+	})
+)
@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"strconv"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/internal/common"
+	"github.com/spezifisch/rueder3/backend/pkg/worker/scheduler"
+)
+
+// UserEventNotifier implements a scheduler.RefreshNotifier that sends the results as user events to the event api
+type UserEventNotifier struct {
+	publisher scheduler.UserEventPublisher
+}
+
+// NewUserEventNotifier creates a notifier that publishes with the given publisher
+func NewUserEventNotifier(publisher scheduler.UserEventPublisher) *UserEventNotifier {
+	return &UserEventNotifier{
+		publisher: publisher,
+	}
+}
+
+// FeedRefreshed sends the fetcher state of the refreshed feed to the user
+func (n *UserEventNotifier) FeedRefreshed(userID uuid.UUID, feed *scheduler.Feed) {
+	n.publisher.PublishUserEvent(userID, common.UserEventMessage{
+		Type: common.MessageTypeFeedRefreshed,
+		Data: map[string]string{
+			"feed_id": feed.ID.String(),
+			"working": strconv.FormatBool(feed.FetcherState.Working),
+			"message": feed.FetcherState.Message,
+		},
+	})
+}
@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spezifisch/rueder3/backend/pkg/worker/scheduler"
+)
+
+// pageLimiter keeps track of the article page downloads per host. It's shared by all workers.
+type pageLimiter struct {
+	config scheduler.HostLimitConfig
+
+	mutex sync.Mutex
+	// number of downloads in progress per host
+	active map[string]int
+	// when the last download from a host was started
+	lastStart map[string]time.Time
+}
+
+func newPageLimiter(config scheduler.HostLimitConfig) *pageLimiter {
+	return &pageLimiter{
+		config:    config,
+		active:    make(map[string]int),
+		lastStart: make(map[string]time.Time),
+	}
+}
+
+// acquire waits until a download from the host may start. release has to be called when the download is done.
+// A nil pageLimiter doesn't limit anything.
+func (l *pageLimiter) acquire(ctx context.Context, host string) (release func(), err error) {
+	release = func() {}
+	host = strings.ToLower(host)
+	if l == nil || host == "" {
+		return
+	}
+
+	for {
+		delay := l.tryStart(host, time.Now())
+		if delay == 0 {
+			release = func() { l.done(host) }
+			return
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// tryStart records the download if it can start now, otherwise it returns how long to wait
+func (l *pageLimiter) tryStart(host string, now time.Time) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var delay time.Duration
+	if l.config.MinHostInterval > 0 {
+		if last, ok := l.lastStart[host]; ok {
+			delay = last.Add(l.config.MinHostInterval).Sub(now)
+		}
+	}
+	if l.config.MaxConcurrentPerHost > 0 && l.active[host] >= l.config.MaxConcurrentPerHost {
+		// we don't know when one of the running downloads is done, so check again a bit later
+		busyDelay := l.config.MinHostInterval
+		if busyDelay < time.Second {
+			busyDelay = time.Second
+		}
+		if busyDelay > delay {
+			delay = busyDelay
+		}
+	}
+	if delay > 0 {
+		return delay
+	}
+
+	l.active[host]++
+	l.lastStart[host] = now
+
+	// forget hosts that don't need to be spaced anymore so the map doesn't grow forever
+	for h, last := range l.lastStart {
+		if l.active[h] == 0 && now.Sub(last) > l.config.MinHostInterval {
+			delete(l.lastStart, h)
+		}
+	}
+	return 0
+}
+
+func (l *pageLimiter) done(host string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.active[host]--
+	if l.active[host] <= 0 {
+		delete(l.active, host)
+	}
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// HostLimitConfig configures how politely feeds on the same host are fetched. Zero values disable the respective limit.
+type HostLimitConfig struct {
+	// maximum number of feeds of the same host that are fetched at the same time
+	MaxConcurrentPerHost int
+	// minimum time between starting two fetches of the same host
+	MinHostInterval time.Duration
+}
+
+// hostLimiter keeps track of the fetches per host
+type hostLimiter struct {
+	config HostLimitConfig
+
+	// number of fetches in progress per host
+	active map[string]int
+	// when the last fetch of a host was started
+	lastStart map[string]time.Time
+	// host of each feed that is being fetched. the feed url can change while fetching.
+	jobHosts map[uuid.UUID]string
+}
+
+func newHostLimiter(config HostLimitConfig) *hostLimiter {
+	return &hostLimiter{
+		config:    config,
+		active:    make(map[string]int),
+		lastStart: make(map[string]time.Time),
+		jobHosts:  make(map[uuid.UUID]string),
+	}
+}
+
+// Delay returns how long the fetch of the feed has to wait because of its host, 0 if it can start now
+func (l *hostLimiter) Delay(feed *Feed, now time.Time) time.Duration {
+	host := feedHost(feed)
+	if host == "" {
+		return 0
+	}
+
+	var delay time.Duration
+	if l.config.MinHostInterval > 0 {
+		if last, ok := l.lastStart[host]; ok {
+			delay = last.Add(l.config.MinHostInterval).Sub(now)
+		}
+	}
+	if l.config.MaxConcurrentPerHost > 0 && l.active[host] >= l.config.MaxConcurrentPerHost {
+		// we don't know when one of the running fetches is done, so check again a bit later
+		busyDelay := l.config.MinHostInterval
+		if busyDelay < time.Second {
+			busyDelay = time.Second
+		}
+		if busyDelay > delay {
+			delay = busyDelay
+		}
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// Start records that the feed is being fetched now
+func (l *hostLimiter) Start(feed *Feed, now time.Time) {
+	host := feedHost(feed)
+	if host == "" {
+		return
+	}
+
+	l.active[host]++
+	l.lastStart[host] = now
+	l.jobHosts[feed.ID] = host
+
+	// forget hosts that don't need to be spaced anymore so the map doesn't grow forever
+	for h, last := range l.lastStart {
+		if l.active[h] == 0 && now.Sub(last) > l.config.MinHostInterval {
+			delete(l.lastStart, h)
+		}
+	}
+}
+
+// Done records that the feed's fetch is finished
+func (l *hostLimiter) Done(feedID uuid.UUID) {
+	host, ok := l.jobHosts[feedID]
+	if !ok {
+		return
+	}
+	delete(l.jobHosts, feedID)
+
+	l.active[host]--
+	if l.active[host] <= 0 {
+		delete(l.active, host)
+	}
+}
+
+// feedHost returns the lowercase hostname of the feed url or an empty string if it has none
+func feedHost(feed *Feed) string {
+	u, err := url.Parse(feed.FeedURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostLimiter(t *testing.T) {
+	l := newHostLimiter(HostLimitConfig{
+		MaxConcurrentPerHost: 2,
+		MinHostInterval:      10 * time.Second,
+	})
+	now := time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	feedA := &Feed{ID: uuid.Must(uuid.NewV4()), FeedURL: "https://www.reddit.com/r/golang/.rss"}
+	feedB := &Feed{ID: uuid.Must(uuid.NewV4()), FeedURL: "https://WWW.reddit.com/r/rust/.rss"}
+	feedC := &Feed{ID: uuid.Must(uuid.NewV4()), FeedURL: "https://www.reddit.com/r/python/.rss"}
+	other := &Feed{ID: uuid.Must(uuid.NewV4()), FeedURL: "https://github.com/golang/go/releases.atom"}
+
+	assert.Equal(t, time.Duration(0), l.Delay(feedA, now))
+	l.Start(feedA, now)
+
+	// same host has to wait for the interval, other hosts don't
+	assert.Equal(t, 10*time.Second, l.Delay(feedB, now))
+	assert.Equal(t, 4*time.Second, l.Delay(feedB, now.Add(6*time.Second)))
+	assert.Equal(t, time.Duration(0), l.Delay(other, now))
+
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, time.Duration(0), l.Delay(feedB, now))
+	l.Start(feedB, now)
+
+	// two fetches of the host are running
+	now = now.Add(time.Minute)
+	assert.Equal(t, 10*time.Second, l.Delay(feedC, now))
+
+	l.Done(feedA.ID)
+	assert.Equal(t, time.Duration(0), l.Delay(feedC, now))
+
+	// the feed url doesn't matter when it's done
+	feedB.FeedURL = "https://example.com/"
+	l.Done(feedB.ID)
+	assert.Empty(t, l.active)
+	assert.Empty(t, l.jobHosts)
+}
+
+func TestHostLimiter_Unlimited(t *testing.T) {
+	l := newHostLimiter(HostLimitConfig{})
+	now := time.Now()
+
+	feed := &Feed{ID: uuid.Must(uuid.NewV4()), FeedURL: "https://example.com/feed"}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, time.Duration(0), l.Delay(feed, now))
+		l.Start(feed, now)
+	}
+
+	invalid := &Feed{ID: uuid.Must(uuid.NewV4()), FeedURL: "not a url"}
+	assert.Equal(t, time.Duration(0), l.Delay(invalid, now))
+}
@@ -1,7 +1,11 @@
 package scheduler
 
 import (
+	"time"
+
 	"github.com/gofrs/uuid"
+
+	"github.com/spezifisch/rueder3/backend/internal/common"
 )
 
 // Repository is the database used by the scheduler and workers
@@ -11,9 +15,16 @@ type Repository interface {
 	Feeds() ([]Feed, error)
 	GetFeed(feedID uuid.UUID) (Feed, error)
 	// RunAddFeedListener starts a blocking listener that outputs at the addedFeeds channel
-	// whenever a feed is added to the repository. It outputs at the needRehash channel
-	// whenever a feed is removed or its parameters are changed.
-	RunFeedChangeListener(addedFeeds chan<- uuid.UUID, needRehash chan<- bool) (err error)
+	// whenever a feed gets its first subscriber. It outputs at the needRehash channel
+	// whenever a feed is removed, loses its last subscriber or its parameters are changed.
+	// Users asking for a feed to be fetched right away are output at the refreshRequests channel.
+	RunFeedChangeListener(addedFeeds chan<- uuid.UUID, needRehash chan<- bool, refreshRequests chan<- RefreshRequest) (err error)
+	// CleanupOrphanedFeeds marks feeds without subscribers as orphaned and removes feeds that have been orphaned
+	// for longer than retention. Feeds with labelled articles are archived (kept with only these articles) instead.
+	CleanupOrphanedFeeds(retention time.Duration) (deleted int, archived int, err error)
+	// PruneArticles deletes articles older than maxAge or exceeding maxPerFeed, except labelled ones and the
+	// newest keepNewest articles of each feed. Pruned articles must still be reported by CheckExistingArticles.
+	PruneArticles(maxAge time.Duration, maxPerFeed int, keepNewest int) (pruned int, err error)
 
 	// -> for workers
 	// UpdateFeedInfo updates the feed with the given uuid with new data from the Feed object
@@ -22,10 +33,23 @@ type Repository interface {
 	CheckExistingArticles(feedID uuid.UUID, articleGUIDs []string) (exists []bool, err error)
 	// AddArticle adds a new article and associates it with the given feed
 	AddArticle(feedID uuid.UUID, article *Article) error
+	// LatestArticleSeq returns the highest article seq of the feed, 0 if it has no articles
+	LatestArticleSeq(feedID uuid.UUID) (seq int, err error)
 }
 
 // WorkerPool spawns workers that fetch feeds
 type WorkerPool interface {
-	// StartWorker starts a feed fetcher
+	// StartWorker starts a feed fetcher. It returns when the feeds channel is closed.
 	StartWorker(id int, feeds <-chan Feed, doneFeeds chan<- Feed)
 }
+
+// RefreshNotifier tells users the result of the refreshes they requested
+type RefreshNotifier interface {
+	// FeedRefreshed is called with the updated feed after the fetch
+	FeedRefreshed(userID uuid.UUID, feed *Feed)
+}
+
+// UserEventPublisher sends events to all connected clients of a user
+type UserEventPublisher interface {
+	PublishUserEvent(userID uuid.UUID, payload common.UserEventMessage)
+}
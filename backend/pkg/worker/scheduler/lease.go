@@ -0,0 +1,201 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/gofrs/uuid"
+)
+
+// LeaseRepository lets several worker instances share the feeds. Each instance leases the due feeds it's going to fetch
+// so the others skip them. The lease of a feed ends with UpdateFeedInfo or when it expires.
+type LeaseRepository interface {
+	Repository
+
+	// ClaimFeeds leases up to limit due feeds that aren't leased by another instance for leaseDuration
+	ClaimFeeds(limit int, leaseDuration time.Duration) ([]Feed, error)
+	// ReleaseFeeds ends the lease of feeds that weren't fetched, so other instances can take them right away
+	ReleaseFeeds(feedIDs []uuid.UUID) error
+	// RenewLeases leases the feeds for another leaseDuration from now
+	RenewLeases(feedIDs []uuid.UUID, leaseDuration time.Duration) error
+	// TakeRefreshRequests removes the requests to refresh the feed made before the given time and returns the
+	// users who made them
+	TakeRefreshRequests(feedID uuid.UUID, before time.Time) ([]uuid.UUID, error)
+}
+
+// LeaseConfig configures how feeds are leased from the repository
+type LeaseConfig struct {
+	// how long a claimed feed is reserved for this instance. it has to be longer than fetching the feed takes.
+	LeaseDuration time.Duration
+	// how often the repository is asked for due feeds while workers are idle
+	PollInterval time.Duration
+}
+
+// NewLeasingScheduler creates a scheduler that leases due feeds from the repository instead of keeping all of them
+// in memory, so several worker instances can run at the same time
+func NewLeasingScheduler(repository LeaseRepository, workerPool WorkerPool, workerCount int, retention RetentionConfig, hostLimits HostLimitConfig, leasing LeaseConfig) *Scheduler {
+	s := NewScheduler(repository, workerPool, workerCount, retention, hostLimits)
+	s.leaseRepository = repository
+	s.leasing = leasing
+	return s
+}
+
+// runLeased dispatches the feeds leased from the repository. The queue only holds the claimed feeds
+// until a worker and their host are free.
+func (s *Scheduler) runLeased(ctx context.Context) {
+	log.Info("Starting leasing job dispatcher loop")
+	s.queue = NewFeedQueue()
+	s.queuedFeeds = make(map[uuid.UUID]bool)
+	s.leasedFetchStarts = make(map[uuid.UUID]time.Time)
+	s.leasesRenewedAt = time.Now()
+
+	for ctx.Err() == nil {
+		// only claim what the idle workers can fetch, other instances might be idle too
+		if idle := s.workerCount - s.jobsInProgress - s.queue.Len(); idle > 0 {
+			s.claimFeeds(idle)
+		}
+		// claimed feeds can wait for their host longer than the lease lasts
+		s.renewLeases()
+
+		// wait until a claimed feed can be fetched, a worker is done or it's time to poll again
+		s.sleepUntilNextLeasedJob(ctx)
+		if ctx.Err() != nil || s.queue.Len() == 0 || s.queue.Peek().deadline.After(time.Now()) {
+			continue
+		}
+
+		job := s.queue.Pop()
+
+		// don't overload hosts with many feeds. the limit is per instance.
+		if delay := s.hostLimiter.Delay(&job.feed, time.Now()); delay > 0 {
+			log.WithField("feed", job.feed.ID).WithField("delay", delay).Debug("host busy, delaying feed")
+			job.deadline = time.Now().Add(delay)
+			s.queue.Push(&job)
+			continue
+		}
+
+		select {
+		case s.workerFeeds <- job.feed:
+		case <-ctx.Done():
+			s.queue.Push(&job)
+			continue
+		}
+		s.jobsInProgress++
+		s.hostLimiter.Start(&job.feed, time.Now())
+		s.leasedFetchStarts[job.feed.ID] = time.Now()
+
+		log.WithField("pop", job.feed).WithField("inProgress", s.jobsInProgress).Info("scheduler sent")
+	}
+
+	s.releaseFeeds()
+}
+
+// claimFeeds leases due feeds and queues them to be fetched right away
+func (s *Scheduler) claimFeeds(limit int) {
+	feeds, err := s.leaseRepository.ClaimFeeds(limit, s.leasing.LeaseDuration)
+	if err != nil {
+		log.WithError(err).Error("failed claiming feeds")
+		return
+	}
+
+	now := time.Now()
+	for _, feed := range feeds {
+		log.WithField("feed", feed.ID).Debug("claimed feed")
+		s.queue.Push(&FeedQueueItem{
+			feed:     feed,
+			deadline: now,
+		})
+	}
+}
+
+// renewLeases extends the leases of the queued feeds before they expire. It renews them every half lease duration,
+// so every lease is renewed in time no matter when the feed was claimed.
+func (s *Scheduler) renewLeases() {
+	if s.queue.Len() == 0 || time.Since(s.leasesRenewedAt) < s.leasing.LeaseDuration/2 {
+		return
+	}
+
+	feedIDs := s.queue.FeedIDs()
+	if err := s.leaseRepository.RenewLeases(feedIDs, s.leasing.LeaseDuration); err != nil {
+		// try again in the next round
+		log.WithError(err).Error("failed renewing leases")
+		return
+	}
+	s.leasesRenewedAt = time.Now()
+	log.WithField("count", len(feedIDs)).Debug("renewed leases")
+}
+
+// releaseFeeds gives back the claimed feeds that weren't sent to a worker
+func (s *Scheduler) releaseFeeds() {
+	if s.queue.Len() == 0 {
+		return
+	}
+
+	feedIDs := make([]uuid.UUID, 0, s.queue.Len())
+	for s.queue.Len() > 0 {
+		job := s.queue.Pop()
+		feedIDs = append(feedIDs, job.feed.ID)
+	}
+
+	if err := s.leaseRepository.ReleaseFeeds(feedIDs); err != nil {
+		log.WithError(err).Error("failed releasing feeds")
+		return
+	}
+	log.WithField("count", len(feedIDs)).Info("released claimed feeds")
+}
+
+func (s *Scheduler) sleepUntilNextLeasedJob(ctx context.Context) {
+	// count the finished jobs even if there's no need to wait
+	for len(s.workerDoneFeeds) > 0 {
+		doneFeed := <-s.workerDoneFeeds
+		s.leasedJobDone(&doneFeed)
+	}
+	s.updateMetrics()
+
+	wait := s.leasing.PollInterval
+	if s.queue.Len() > 0 {
+		if untilNextJob := time.Until(s.queue.Peek().deadline); untilNextJob < wait {
+			wait = untilNextJob
+		}
+	}
+	if wait <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case doneFeed := <-s.workerDoneFeeds:
+		s.leasedJobDone(&doneFeed)
+	case <-timer.C:
+	}
+}
+
+// leasedJobDone frees the worker of the finished feed. Its lease was already ended by the worker's UpdateFeedInfo.
+func (s *Scheduler) leasedJobDone(feed *Feed) {
+	s.jobsInProgress--
+	s.hostLimiter.Done(feed.ID)
+
+	startedAt := s.leasedFetchStarts[feed.ID]
+	delete(s.leasedFetchStarts, feed.ID)
+	s.notifyLeasedRefresh(feed, startedAt)
+}
+
+// notifyLeasedRefresh sends the result of the fetch to the users who requested a refresh before it started.
+// Later requests are answered by the next fetch.
+func (s *Scheduler) notifyLeasedRefresh(feed *Feed, startedAt time.Time) {
+	if s.refreshNotifier == nil {
+		return
+	}
+
+	userIDs, err := s.leaseRepository.TakeRefreshRequests(feed.ID, startedAt)
+	if err != nil {
+		log.WithError(err).WithField("feed", feed.ID).Error("failed getting refresh requests")
+		return
+	}
+	for _, userID := range userIDs {
+		s.refreshNotifier.FeedRefreshed(userID, feed)
+	}
+}
@@ -0,0 +1,219 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// leaseRepository hands out its due feeds and records the claims
+type leaseRepository struct {
+	Repository
+
+	mutex       sync.Mutex
+	due         []Feed
+	claimLimits []int
+	released    []uuid.UUID
+	renewed     []uuid.UUID
+	// users who requested a refresh, by feed id
+	refreshRequests map[uuid.UUID][]uuid.UUID
+	// the times given to TakeRefreshRequests
+	takenBefore []time.Time
+}
+
+func (r *leaseRepository) ClaimFeeds(limit int, leaseDuration time.Duration) (ret []Feed, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.claimLimits = append(r.claimLimits, limit)
+	if limit > len(r.due) {
+		limit = len(r.due)
+	}
+	ret, r.due = r.due[:limit], r.due[limit:]
+	return
+}
+
+func (r *leaseRepository) ReleaseFeeds(feedIDs []uuid.UUID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.released = append(r.released, feedIDs...)
+	return nil
+}
+
+func (r *leaseRepository) RenewLeases(feedIDs []uuid.UUID, leaseDuration time.Duration) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.renewed = append(r.renewed, feedIDs...)
+	return nil
+}
+
+func (r *leaseRepository) TakeRefreshRequests(feedID uuid.UUID, before time.Time) (userIDs []uuid.UUID, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.takenBefore = append(r.takenBefore, before)
+	userIDs = r.refreshRequests[feedID]
+	delete(r.refreshRequests, feedID)
+	return
+}
+
+func (r *leaseRepository) renewedFeeds() []uuid.UUID {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]uuid.UUID{}, r.renewed...)
+}
+
+// fetchingWorkerPool reports the fetched feeds
+type fetchingWorkerPool struct {
+	fetched chan uuid.UUID
+}
+
+func (p *fetchingWorkerPool) StartWorker(id int, feeds <-chan Feed, doneFeeds chan<- Feed) {
+	for feed := range feeds {
+		p.fetched <- feed.ID
+		doneFeeds <- feed
+	}
+}
+
+func newTestFeed(feedURL string) Feed {
+	return Feed{ID: uuid.Must(uuid.NewV4()), FeedURL: feedURL}
+}
+
+func TestScheduler_runLeased(t *testing.T) {
+	repo := &leaseRepository{
+		due: []Feed{
+			newTestFeed("https://a.example.com/feed"),
+			newTestFeed("https://b.example.com/feed"),
+			newTestFeed("https://c.example.com/feed"),
+		},
+	}
+	pool := &fetchingWorkerPool{fetched: make(chan uuid.UUID, 3)}
+	s := NewLeasingScheduler(repo, pool, 1, RetentionConfig{}, HostLimitConfig{}, LeaseConfig{
+		LeaseDuration: time.Minute,
+		PollInterval:  10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(ctx, time.Second)
+		close(stopped)
+	}()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-pool.fetched:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("only %d feeds fetched", i)
+		}
+	}
+	cancel()
+	<-stopped
+
+	for _, limit := range repo.claimLimits {
+		assert.Equal(t, 1, limit, "only as many feeds are claimed as there are idle workers")
+	}
+	assert.Empty(t, repo.released)
+}
+
+func TestScheduler_runLeasedReleasesOnShutdown(t *testing.T) {
+	first := newTestFeed("https://example.com/feed1")
+	second := newTestFeed("https://example.com/feed2")
+	repo := &leaseRepository{
+		due: []Feed{first, second},
+	}
+	pool := &fetchingWorkerPool{fetched: make(chan uuid.UUID, 2)}
+	// the second feed has to wait for its host
+	s := NewLeasingScheduler(repo, pool, 2, RetentionConfig{}, HostLimitConfig{MinHostInterval: time.Hour}, LeaseConfig{
+		LeaseDuration: time.Minute,
+		PollInterval:  10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(ctx, time.Second)
+		close(stopped)
+	}()
+
+	assert.Equal(t, first.ID, <-pool.fetched)
+	cancel()
+	<-stopped
+
+	assert.Equal(t, []uuid.UUID{second.ID}, repo.released, "the claimed feed that wasn't fetched is released")
+}
+
+func TestScheduler_runLeasedRenewsLeases(t *testing.T) {
+	first := newTestFeed("https://example.com/feed1")
+	second := newTestFeed("https://example.com/feed2")
+	repo := &leaseRepository{
+		due: []Feed{first, second},
+	}
+	pool := &fetchingWorkerPool{fetched: make(chan uuid.UUID, 2)}
+	// the second feed waits for its host longer than its lease lasts
+	s := NewLeasingScheduler(repo, pool, 2, RetentionConfig{}, HostLimitConfig{MinHostInterval: time.Hour}, LeaseConfig{
+		LeaseDuration: 40 * time.Millisecond,
+		PollInterval:  10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(ctx, time.Second)
+		close(stopped)
+	}()
+
+	assert.Equal(t, first.ID, <-pool.fetched)
+	assert.Eventually(t, func() bool {
+		return len(repo.renewedFeeds()) >= 2
+	}, 2*time.Second, 10*time.Millisecond, "the lease of the waiting feed is renewed repeatedly")
+	cancel()
+	<-stopped
+
+	for _, feedID := range repo.renewedFeeds() {
+		assert.Equal(t, second.ID, feedID, "only the waiting feed is renewed")
+	}
+}
+
+func TestScheduler_leasedJobDone(t *testing.T) {
+	feed := newTestFeed("https://example.com/feed")
+	userA := uuid.Must(uuid.NewV4())
+	userB := uuid.Must(uuid.NewV4())
+	repo := &leaseRepository{
+		refreshRequests: map[uuid.UUID][]uuid.UUID{feed.ID: {userA, userB}},
+	}
+	s := NewLeasingScheduler(repo, nil, 1, RetentionConfig{}, HostLimitConfig{}, LeaseConfig{})
+	notifier := &refreshNotifier{notified: make(map[uuid.UUID][]uuid.UUID)}
+	s.SetRefreshNotifier(notifier)
+
+	startedAt := time.Now()
+	s.leasedFetchStarts = map[uuid.UUID]time.Time{feed.ID: startedAt}
+	s.jobsInProgress = 1
+	s.hostLimiter.Start(&feed, startedAt)
+
+	s.leasedJobDone(&feed)
+	assert.Equal(t, 0, s.jobsInProgress)
+	assert.Equal(t, []uuid.UUID{userA, userB}, notifier.notified[feed.ID])
+	assert.Equal(t, []time.Time{startedAt}, repo.takenBefore, "only requests made before the fetch are answered")
+	assert.NotContains(t, s.leasedFetchStarts, feed.ID)
+}
+
+func TestNextFetchAt(t *testing.T) {
+	fetchedAt := time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	feed := &Feed{FetcherState: FeedFetcherState{FetchedAt: fetchedAt, FetchDelayS: 3600}}
+	assert.Equal(t, fetchedAt.Add(time.Hour), NextFetchAt(feed))
+
+	feed.FetcherState.FetchDelayS = 60
+	assert.Equal(t, fetchedAt.Add(MinimumFetchDelay), NextFetchAt(feed))
+
+	feed.FetcherState.BackoffUntil = fetchedAt.Add(6 * time.Hour)
+	assert.Equal(t, feed.FetcherState.BackoffUntil, NextFetchAt(feed))
+}
@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "rueder",
+		Subsystem: "scheduler",
+		Name:      "queue_length",
+		Help:      "Number of feeds waiting in the queue.",
+	})
+
+	metricNextDeadline = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "rueder",
+		Subsystem: "scheduler",
+		Name:      "next_deadline_seconds",
+		Help:      "Time until the next feed in the queue is due, negative if it's overdue. 0 if the queue is empty.",
+	})
+
+	metricJobsInProgress = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "rueder",
+		Subsystem: "scheduler",
+		Name:      "jobs_in_progress",
+		Help:      "Number of feeds that are currently fetched by workers.",
+	})
+)
+
+// updateMetrics reports the scheduler state. It has to be called from the scheduler loop because the queue isn't thread-safe.
+func (s *Scheduler) updateMetrics() {
+	metricJobsInProgress.Set(float64(s.jobsInProgress))
+
+	if s.queue == nil || s.queue.Len() == 0 {
+		metricQueueLength.Set(0)
+		metricNextDeadline.Set(0)
+		return
+	}
+	metricQueueLength.Set(float64(s.queue.Len()))
+	metricNextDeadline.Set(time.Until(s.queue.Peek().deadline).Seconds())
+}
@@ -52,6 +52,13 @@ type FeedFetcherState struct {
 	ETag         string `json:"etag,omitempty"`
 	LastModified string `json:"last_modified,omitempty"`
 
+	// consecutive failed fetches for the exponential backoff
+	FailureCount int `json:"failure_count,omitempty"`
+	// don't fetch before this time because of backoff or Retry-After
+	BackoffUntil time.Time `json:"backoff_until,omitempty"`
+	// the feed is gone (410) and isn't fetched anymore
+	Dead bool `json:"dead,omitempty"`
+
 	// status
 	Working     bool      `json:"working"`
 	LastSuccess time.Time `json:"last_success,omitempty"`
@@ -66,6 +73,8 @@ type Feed struct {
 	ArticleCount int       `json:"article_count,omitempty"`
 
 	FetcherState FeedFetcherState `json:"fetcher_state,omitempty"`
+	// download the linked article page instead of using the feed content
+	FetchFullArticle bool `json:"fetch_full_article,omitempty"`
 
 	FeedURL string `json:"feed_url"`
 	SiteURL string `json:"site_url,omitempty"`
@@ -74,3 +83,9 @@ type Feed struct {
 	Icon     string    `json:"icon,omitempty"`
 	Articles []Article `json:"articles,omitempty"`
 }
+
+// RefreshRequest asks to fetch a feed right away on behalf of a user
+type RefreshRequest struct {
+	FeedID uuid.UUID
+	UserID uuid.UUID
+}
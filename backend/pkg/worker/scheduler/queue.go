@@ -3,6 +3,7 @@ package scheduler
 import (
 	"time"
 
+	"github.com/gofrs/uuid"
 	"github.com/mailgun/holster/v3/collections"
 )
 
@@ -16,12 +17,15 @@ type FeedQueueItem struct {
 // A FeedQueue is a MinHeap which pops the next due deadline first
 type FeedQueue struct {
 	pq *collections.PriorityQueue
+	// heap items by feed id to find them for Reschedule
+	items map[uuid.UUID]*collections.PQItem
 }
 
 // NewFeedQueue creates a new MinHeap
 func NewFeedQueue() *FeedQueue {
 	return &FeedQueue{
-		pq: collections.NewPriorityQueue(),
+		pq:    collections.NewPriorityQueue(),
+		items: make(map[uuid.UUID]*collections.PQItem),
 	}
 }
 
@@ -34,15 +38,19 @@ func (f *FeedQueue) Push(item *FeedQueueItem) {
 		Value: item,
 		// the collections.PriorityQueue pops the lowest int value first.
 		// this is exactly what we want as we want to pop the lowest timestamp.
-		Priority: int(item.deadline.Unix()),
+		Priority: deadlinePriority(item.deadline),
 	}
 	f.pq.Push(&pqi)
+	f.items[item.feed.ID] = &pqi
 }
 
 // Pop is implemented for heap.Interface
 func (f *FeedQueue) Pop() FeedQueueItem {
 	pqi := f.pq.Pop()
 	val := pqi.Value.(*FeedQueueItem)
+	if f.items[val.feed.ID] == pqi {
+		delete(f.items, val.feed.ID)
+	}
 	return *val
 }
 
@@ -52,3 +60,27 @@ func (f *FeedQueue) Peek() FeedQueueItem {
 	val := pqi.Value.(*FeedQueueItem)
 	return *val
 }
+
+// FeedIDs returns the ids of all queued feeds
+func (f *FeedQueue) FeedIDs() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(f.items))
+	for id := range f.items {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Reschedule changes the deadline of the queued feed. It returns false if the feed isn't in the queue.
+func (f *FeedQueue) Reschedule(feedID uuid.UUID, deadline time.Time) bool {
+	pqi, ok := f.items[feedID]
+	if !ok {
+		return false
+	}
+	pqi.Value.(*FeedQueueItem).deadline = deadline
+	f.pq.Update(pqi, deadlinePriority(deadline))
+	return true
+}
+
+func deadlinePriority(deadline time.Time) int {
+	return int(deadline.Unix())
+}
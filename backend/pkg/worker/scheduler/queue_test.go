@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -116,3 +117,26 @@ func Test_Push(t *testing.T) {
 	item = pq.Pop()
 	assert.Equal(t, timeE, item.deadline)
 }
+
+func Test_Reschedule(t *testing.T) {
+	timeA, _ := time.Parse("Jan 2 2006", "Jan 1 2000")
+	timeB := timeA.Add(10 * time.Minute)
+	timeC := timeA.Add(20 * time.Minute)
+	feedB := Feed{ID: uuid.Must(uuid.NewV4())}
+	feedC := Feed{ID: uuid.Must(uuid.NewV4())}
+
+	pq := NewFeedQueue()
+	pq.Push(&FeedQueueItem{feed: feedB, deadline: timeB})
+	pq.Push(&FeedQueueItem{feed: feedC, deadline: timeC})
+
+	assert.True(t, pq.Reschedule(feedC.ID, timeA))
+	assert.False(t, pq.Reschedule(uuid.Must(uuid.NewV4()), timeA), "unknown feed")
+
+	item := pq.Pop()
+	assert.Equal(t, feedC.ID, item.feed.ID)
+	assert.Equal(t, timeA, item.deadline)
+	assert.False(t, pq.Reschedule(feedC.ID, timeA), "popped feed")
+	item = pq.Pop()
+	assert.Equal(t, feedB.ID, item.feed.ID)
+	assert.Equal(t, 0, pq.Len())
+}
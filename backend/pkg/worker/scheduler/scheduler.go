@@ -1,33 +1,71 @@
 package scheduler
 
 import (
+	"context"
 	"time"
 
 	"github.com/apex/log"
 	"github.com/gofrs/uuid"
 )
 
+// MinimumFetchDelay is the shortest time between two fetches of the same feed
+const MinimumFetchDelay = 10 * time.Minute
+
+// RetentionConfig configures the periodic cleanup. Zero values disable the respective limit.
+type RetentionConfig struct {
+	// feeds without subscribers are removed after this time
+	OrphanedFeedRetention time.Duration
+
+	// articles older than this are pruned
+	ArticleMaxAge time.Duration
+	// articles exceeding this count per feed are pruned
+	ArticleMaxPerFeed int
+	// the newest articles of each feed are always kept
+	ArticleKeepNewest int
+}
+
 // Scheduler dispatches to the workers which feeds should be fetched
 type Scheduler struct {
 	repository Repository
 	queue      *FeedQueue
+	// ids of all feeds in the queue
+	queuedFeeds map[uuid.UUID]bool
+	// ids of the feeds the workers are fetching
+	fetchingFeeds map[uuid.UUID]bool
 
 	feedRehash          chan bool
 	feedRehashRequested bool
 	feedAdded           chan uuid.UUID
+	feedRefresh         chan RefreshRequest
+	// users waiting for the result of a refresh by feed id
+	refreshRequesters map[uuid.UUID][]uuid.UUID
+	refreshNotifier   RefreshNotifier
 
 	workerPool      WorkerPool
 	workerCount     int
 	workerFeeds     chan Feed
 	workerDoneFeeds chan Feed
 	jobsInProgress  int
-
-	minimumFetchDelay time.Duration
-	retryDelay        time.Duration
+	hostLimiter     *hostLimiter
+
+	retryDelay time.Duration
+
+	// set if the feeds are leased from the repository instead of being queued in memory
+	leaseRepository LeaseRepository
+	leasing         LeaseConfig
+	// when the leases of the queued feeds were renewed last
+	leasesRenewedAt time.Time
+	// when the workers started fetching the leased feeds, by feed id
+	leasedFetchStarts map[uuid.UUID]time.Time
+
+	retention       RetentionConfig
+	cleanupInterval time.Duration
+	// closed when the cleanup goroutine has stopped
+	cleanupDone chan struct{}
 }
 
 // NewScheduler creates a new scheduler with the given count of workers
-func NewScheduler(repository Repository, workerPool WorkerPool, workerCount int) *Scheduler {
+func NewScheduler(repository Repository, workerPool WorkerPool, workerCount int, retention RetentionConfig, hostLimits HostLimitConfig) *Scheduler {
 	return &Scheduler{
 		repository: repository,
 		queue:      nil,
@@ -35,69 +73,143 @@ func NewScheduler(repository Repository, workerPool WorkerPool, workerCount int)
 		feedRehash:          make(chan bool),                     // a feed was deleted or changed
 		feedRehashRequested: false,                               // true if the signal came and until all active jobs are completed
 		feedAdded:           make(chan uuid.UUID, workerCount+1), // a new feed was added externally
+		feedRefresh:         make(chan RefreshRequest, workerCount+1),
+		refreshRequesters:   make(map[uuid.UUID][]uuid.UUID),
+		fetchingFeeds:       make(map[uuid.UUID]bool),
 
 		workerPool:      workerPool,
 		workerCount:     workerCount,
 		workerFeeds:     make(chan Feed),                // block when no worker is ready
 		workerDoneFeeds: make(chan Feed, workerCount+1), // we need 1 more queue element than workers because the scheduler can queue an additional feed while all workers are busy
 		jobsInProgress:  0,                              // counts how many workers are currently processing a feed
+		hostLimiter:     newHostLimiter(hostLimits),     // limits fetches per host
+
+		retryDelay: 30 * time.Second,
 
-		minimumFetchDelay: 10 * time.Minute, // don't fetch feeds faster than this
-		retryDelay:        30 * time.Second,
+		retention:       retention,
+		cleanupInterval: time.Hour,
 	}
 }
 
-// Run starts the workers and the scheduling loop
-func (s *Scheduler) Run() {
+// Run starts the workers and the scheduling loop. It returns when ctx is cancelled and the jobs in progress
+// are finished, but waits at most shutdownTimeout for them.
+func (s *Scheduler) Run(ctx context.Context, shutdownTimeout time.Duration) {
 	// start workers
 	log.Infof("Starting %d workers ...", s.workerCount)
 	for w := 1; w <= s.workerCount; w++ {
 		go s.workerPool.StartWorker(w, s.workerFeeds, s.workerDoneFeeds)
 	}
 
-	// start listening for newly added feeds
-	err := s.repository.RunFeedChangeListener(s.feedAdded, s.feedRehash)
-	if err != nil {
-		log.WithError(err).Error("failed setting up feedchangelistener")
-		return
+	if s.leaseRepository == nil {
+		// start listening for newly added feeds
+		err := s.repository.RunFeedChangeListener(s.feedAdded, s.feedRehash, s.feedRefresh)
+		if err != nil {
+			log.WithError(err).Error("failed setting up feedchangelistener")
+			return
+		}
+	}
+
+	// remove feeds nobody subscribes to anymore and old articles
+	s.cleanupDone = make(chan struct{})
+	go s.runCleanup(ctx)
+
+	// dispatch jobs until shutdown
+	if s.leaseRepository != nil {
+		s.runLeased(ctx)
+	} else {
+		s.runQueued(ctx)
 	}
 
+	s.shutdown(shutdownTimeout)
+}
+
+// runQueued dispatches the feeds from the in-memory queue that holds all feeds
+func (s *Scheduler) runQueued(ctx context.Context) {
 	// dispatch jobs
 	log.Info("Starting job dispatcher loop")
-	for {
+	for ctx.Err() == nil {
 		// init queue if it's not yet initialized
 		if s.queue == nil {
 			log.Info("init queue")
 			if err := s.initQueue(); err != nil {
 				log.WithError(err).WithFields(log.Fields{"wait": s.retryDelay}).Error("failed initializing FeedQueue")
-				time.Sleep(s.retryDelay)
+				select {
+				case <-ctx.Done():
+				case <-time.After(s.retryDelay):
+				}
 				continue
 			}
 		}
 
 		// wait until a feed needs to be fetched.
 		// this also processes the worker results queue and schedules rehashes
-		s.sleepUntilNextJob()
+		s.sleepUntilNextJob(ctx)
 
 		// this can be a:
 		// timeout => start sleeping again in next iteration
 		// rehash requested => wait until all jobs are done and then re-init queue in next iteration
-		if s.queue == nil || s.queue.Len() == 0 {
+		// shutdown => leave the loop
+		if ctx.Err() != nil || s.queue == nil || s.queue.Len() == 0 {
 			continue
 		}
 
 		job := s.queue.Pop()
+
+		// don't overload hosts with many feeds
+		if delay := s.hostLimiter.Delay(&job.feed, time.Now()); delay > 0 {
+			log.WithField("feed", job.feed.ID).WithField("delay", delay).Debug("host busy, delaying feed")
+			job.deadline = time.Now().Add(delay)
+			s.queue.Push(&job)
+			continue
+		}
+
 		log.WithField("pop", job.feed).Info("scheduler sends")
 
 		// send it to a worker to fetch it; blocks until a worker takes it.
 		// a rehash can get requested while we're waiting here. this is handled in the next loop iteration in sleepUntilNextJob()
-		s.workerFeeds <- job.feed
-		s.jobsInProgress++
+		select {
+		case s.workerFeeds <- job.feed:
+		case <-ctx.Done():
+			// the job is fetched again after the restart
+			continue
+		}
+		s.jobStarted(&job.feed)
 
 		log.WithField("pop", job.feed).WithField("inProgress", s.jobsInProgress).Info("scheduler sent")
 	}
 }
 
+// shutdown stops the workers after they have finished their current job and waits for the cleanup
+func (s *Scheduler) shutdown(timeout time.Duration) {
+	log.WithField("jobsInProgress", s.jobsInProgress).Info("stopping workers")
+
+	// idle workers quit immediately, busy ones after their current job
+	close(s.workerFeeds)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for s.jobsInProgress > 0 {
+		select {
+		case doneFeed := <-s.workerDoneFeeds:
+			s.jobsInProgress--
+			s.hostLimiter.Done(doneFeed.ID)
+		case <-deadline.C:
+			log.WithField("jobsInProgress", s.jobsInProgress).Warn("shutdown timeout reached, abandoning jobs in progress")
+			return
+		}
+	}
+
+	select {
+	case <-s.cleanupDone:
+	case <-deadline.C:
+		log.Warn("shutdown timeout reached, abandoning cleanup in progress")
+		return
+	}
+
+	log.Info("scheduler stopped")
+}
+
 func (s *Scheduler) initQueue() (err error) {
 	// get all feeds
 	feeds, err := s.repository.Feeds()
@@ -107,6 +219,7 @@ func (s *Scheduler) initQueue() (err error) {
 
 	// add all existing feeds to queue
 	s.queue = NewFeedQueue()
+	s.queuedFeeds = make(map[uuid.UUID]bool, len(feeds))
 	for _, feed := range feeds {
 		s.queueFeed(&feed)
 	}
@@ -125,11 +238,14 @@ func (s *Scheduler) rehashIfRequestedAndPossible() {
 	s.feedRehashRequested = false
 }
 
-func (s *Scheduler) sleepUntilNextJob() {
+func (s *Scheduler) sleepUntilNextJob(ctx context.Context) {
+	s.updateMetrics()
 	timer := s.refreshSleepTimer(nil)
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-s.feedRehash:
 			// this signals that we need to refetch all feed ids.
 			// but we need to wait until all currently active workers are done to avoid race conditions.
@@ -145,16 +261,21 @@ func (s *Scheduler) sleepUntilNextJob() {
 				return
 			}
 
-			if !s.feedRehashRequested {
+			if s.feedRehashRequested {
+				logFeed.WithField("jobsInProgress", s.jobsInProgress).Info("not adding new feed because rehash requested")
+			} else if s.queuedFeeds[newFeedID] || s.fetchingFeeds[newFeedID] {
+				// the notification can race with the queue initialization
+				logFeed.Info("not adding new feed because it's already queued")
+			} else {
 				logFeed.Info("adding new feed")
 				s.queueFeed(&addedFeed)
-			} else {
-				logFeed.WithField("jobsInProgress", s.jobsInProgress).Info("not adding new feed because rehash requested")
 			}
+		case refresh := <-s.feedRefresh:
+			s.refreshFeed(refresh)
 		case doneFeed := <-s.workerDoneFeeds:
 			logFeed := log.WithField("feed", doneFeed.ID)
 
-			s.jobsInProgress--
+			s.jobDone(&doneFeed)
 			if !s.feedRehashRequested {
 				// put fetched feeds back into the job queue with their updated deadline
 				logFeed.Info("readding feed")
@@ -175,20 +296,153 @@ func (s *Scheduler) sleepUntilNextJob() {
 		}
 
 		// reinit timer, our deadline might have changed
+		s.updateMetrics()
 		timer = s.refreshSleepTimer(timer)
 	}
 }
 
+// jobStarted records that the feed left the queue and is being fetched
+func (s *Scheduler) jobStarted(feed *Feed) {
+	delete(s.queuedFeeds, feed.ID)
+	s.fetchingFeeds[feed.ID] = true
+	s.jobsInProgress++
+	s.hostLimiter.Start(feed, time.Now())
+}
+
+// jobDone frees the worker of the fetched feed and tells the users who requested the refresh
+func (s *Scheduler) jobDone(feed *Feed) {
+	delete(s.fetchingFeeds, feed.ID)
+	s.jobsInProgress--
+	s.hostLimiter.Done(feed.ID)
+	s.notifyRefreshed(feed)
+}
+
 // queueFeed adds the given feed to the queue, to be fetched once at its deadline
 func (s *Scheduler) queueFeed(feed *Feed) {
-	delay := s.getFetchDelay(feed)
-	deadline := feed.FetcherState.FetchedAt.Add(delay) // when to fetch
+	_, refreshRequested := s.refreshRequesters[feed.ID]
+	if feed.FetcherState.Dead && !refreshRequested {
+		log.WithField("feed", feed.ID).Info("not queueing dead feed")
+		return
+	}
+
+	if time.Duration(feed.FetcherState.FetchDelayS)*time.Second < MinimumFetchDelay {
+		log.Warnf("feed with lower than minimum fetch delay: %v", feed.ID)
+	}
+	deadline := NextFetchAt(feed)
+	if refreshRequested {
+		deadline = time.Now()
+	}
 	log.WithField("feed", feed.ID).WithField("deadline", deadline).Debug("queued feed")
 
 	s.queue.Push(&FeedQueueItem{
 		feed:     *feed,
 		deadline: deadline,
 	})
+	s.queuedFeeds[feed.ID] = true
+}
+
+// SetRefreshNotifier sets where the results of refreshes requested by users are sent to.
+// The leasing scheduler gets the requests from the LeaseRepository, the other one from the feed change listener.
+func (s *Scheduler) SetRefreshNotifier(notifier RefreshNotifier) {
+	s.refreshNotifier = notifier
+}
+
+// refreshFeed moves the feed to the front of the queue and remembers who wants to know the result
+func (s *Scheduler) refreshFeed(refresh RefreshRequest) {
+	logFeed := log.WithField("feed", refresh.FeedID).WithField("userID", refresh.UserID)
+
+	requesters := s.refreshRequesters[refresh.FeedID]
+	alreadyRequested := false
+	for _, userID := range requesters {
+		if userID == refresh.UserID {
+			alreadyRequested = true
+			break
+		}
+	}
+	if !alreadyRequested {
+		s.refreshRequesters[refresh.FeedID] = append(requesters, refresh.UserID)
+	}
+
+	switch {
+	case s.feedRehashRequested || s.queue == nil:
+		// queueFeed takes care of it when the queue is rebuilt
+		logFeed.Info("refreshing feed after rehash")
+	case s.queue.Reschedule(refresh.FeedID, time.Now()):
+		logFeed.Info("refreshing feed now")
+	case s.fetchingFeeds[refresh.FeedID]:
+		// the requester gets the result of the fetch in progress
+		logFeed.Info("feed is already being fetched")
+	default:
+		// feeds that aren't queued like dead ones
+		feed, err := s.repository.GetFeed(refresh.FeedID)
+		if err != nil {
+			logFeed.WithError(err).Error("failed getting feed to refresh")
+			delete(s.refreshRequesters, refresh.FeedID)
+			return
+		}
+		logFeed.Info("queueing feed to refresh")
+		s.queueFeed(&feed)
+	}
+}
+
+// notifyRefreshed sends the result of the fetch to the users who requested it
+func (s *Scheduler) notifyRefreshed(feed *Feed) {
+	requesters, ok := s.refreshRequesters[feed.ID]
+	if !ok {
+		return
+	}
+	delete(s.refreshRequesters, feed.ID)
+
+	if s.refreshNotifier == nil {
+		return
+	}
+	for _, userID := range requesters {
+		s.refreshNotifier.FeedRefreshed(userID, feed)
+	}
+}
+
+// runCleanup periodically removes feeds without subscribers and prunes old articles until ctx is cancelled.
+// Orphaned feeds aren't queued, so this doesn't need to be synchronized with the job dispatcher.
+func (s *Scheduler) runCleanup(ctx context.Context) {
+	defer close(s.cleanupDone)
+
+	if s.retention.OrphanedFeedRetention <= 0 && s.retention.ArticleMaxAge <= 0 && s.retention.ArticleMaxPerFeed <= 0 {
+		log.Info("cleanup disabled")
+		return
+	}
+
+	ticker := time.NewTicker(s.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		s.cleanup()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) cleanup() {
+	if s.retention.OrphanedFeedRetention > 0 {
+		deleted, archived, err := s.repository.CleanupOrphanedFeeds(s.retention.OrphanedFeedRetention)
+		if err != nil {
+			log.WithError(err).Error("failed cleaning up orphaned feeds")
+		} else if deleted > 0 || archived > 0 {
+			log.WithFields(log.Fields{"deleted": deleted, "archived": archived}).Info("cleaned up orphaned feeds")
+		}
+	}
+
+	if s.retention.ArticleMaxAge > 0 || s.retention.ArticleMaxPerFeed > 0 {
+		pruned, err := s.repository.PruneArticles(s.retention.ArticleMaxAge, s.retention.ArticleMaxPerFeed, s.retention.ArticleKeepNewest)
+		if err != nil {
+			log.WithError(err).Error("failed pruning articles")
+		} else if pruned > 0 {
+			log.WithField("pruned", pruned).Info("pruned old articles")
+		}
+	}
 }
 
 // refreshSleepTimer returns a timer that triggers when the next feed is due
@@ -228,11 +482,16 @@ func (s *Scheduler) refreshSleepTimer(oldTimer *DeadlineTimer) *DeadlineTimer {
 	return timer
 }
 
-func (s Scheduler) getFetchDelay(f *Feed) time.Duration {
+// NextFetchAt returns when the feed is due according to its fetch delay and backoff
+func NextFetchAt(f *Feed) time.Time {
 	delay := time.Duration(f.FetcherState.FetchDelayS) * time.Second
-	if delay < s.minimumFetchDelay {
-		log.Warnf("feed with lower than minimum fetch delay: %v", f.ID)
-		delay = s.minimumFetchDelay
+	if delay < MinimumFetchDelay {
+		delay = MinimumFetchDelay
+	}
+
+	deadline := f.FetcherState.FetchedAt.Add(delay)
+	if f.FetcherState.BackoffUntil.After(deadline) {
+		deadline = f.FetcherState.BackoffUntil
 	}
-	return delay
+	return deadline
 }
@@ -0,0 +1,235 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// feedRepository knows all feeds but nothing else
+type feedRepository struct {
+	Repository
+}
+
+func (r *feedRepository) GetFeed(feedID uuid.UUID) (Feed, error) {
+	if feedID == uuid.Nil {
+		return Feed{}, errors.New("not found")
+	}
+	return Feed{ID: feedID}, nil
+}
+
+func TestScheduler_AddedFeedQueuedOnce(t *testing.T) {
+	s := NewScheduler(&feedRepository{}, nil, 1, RetentionConfig{}, HostLimitConfig{})
+	s.queue = NewFeedQueue()
+	s.queuedFeeds = make(map[uuid.UUID]bool)
+
+	// concurrent subscriptions can announce the same feed twice
+	feedID := uuid.Must(uuid.NewV4())
+	s.feedAdded <- feedID
+	s.feedAdded <- feedID
+
+	for len(s.feedAdded) > 0 {
+		s.sleepUntilNextJob(context.Background())
+	}
+	assert.Equal(t, 1, s.queue.Len())
+	assert.Equal(t, feedID, s.queue.Peek().feed.ID)
+	assert.True(t, s.queue.Peek().deadline.Before(time.Now()))
+}
+
+// cleanupRepository records the cleanup calls
+type cleanupRepository struct {
+	Repository
+
+	orphanedFeedRetention []time.Duration
+	prunedWith            [][3]int
+}
+
+func (r *cleanupRepository) CleanupOrphanedFeeds(retention time.Duration) (int, int, error) {
+	r.orphanedFeedRetention = append(r.orphanedFeedRetention, retention)
+	return 1, 0, nil
+}
+
+func (r *cleanupRepository) PruneArticles(maxAge time.Duration, maxPerFeed int, keepNewest int) (int, error) {
+	r.prunedWith = append(r.prunedWith, [3]int{int(maxAge.Hours()), maxPerFeed, keepNewest})
+	return 0, errors.New("mock error")
+}
+
+func TestScheduler_cleanup(t *testing.T) {
+	repo := &cleanupRepository{}
+	s := NewScheduler(repo, nil, 1, RetentionConfig{ArticleMaxAge: 48 * time.Hour, ArticleKeepNewest: 10}, HostLimitConfig{})
+	s.cleanup()
+	assert.Empty(t, repo.orphanedFeedRetention, "orphaned feed cleanup is disabled")
+	assert.Equal(t, [][3]int{{48, 0, 10}}, repo.prunedWith)
+
+	repo = &cleanupRepository{}
+	s = NewScheduler(repo, nil, 1, RetentionConfig{OrphanedFeedRetention: time.Hour}, HostLimitConfig{})
+	s.cleanup()
+	assert.Equal(t, []time.Duration{time.Hour}, repo.orphanedFeedRetention)
+	assert.Empty(t, repo.prunedWith, "article pruning is disabled")
+}
+
+func TestScheduler_queueFeed(t *testing.T) {
+	s := NewScheduler(&feedRepository{}, nil, 1, RetentionConfig{}, HostLimitConfig{})
+	s.queue = NewFeedQueue()
+	s.queuedFeeds = make(map[uuid.UUID]bool)
+
+	fetchedAt := time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC)
+	backoffUntil := fetchedAt.Add(6 * time.Hour)
+	s.queueFeed(&Feed{
+		ID:           uuid.Must(uuid.NewV4()),
+		FetcherState: FeedFetcherState{FetchedAt: fetchedAt, FetchDelayS: 3600, Dead: true},
+	})
+	assert.Equal(t, 0, s.queue.Len(), "dead feeds aren't queued")
+
+	s.queueFeed(&Feed{
+		ID:           uuid.Must(uuid.NewV4()),
+		FetcherState: FeedFetcherState{FetchedAt: fetchedAt, FetchDelayS: 3600, BackoffUntil: backoffUntil},
+	})
+	assert.Equal(t, 1, s.queue.Len())
+	assert.Equal(t, backoffUntil, s.queue.Peek().deadline)
+}
+
+// shutdownRepository has a single feed that is due and no listener
+type shutdownRepository struct {
+	Repository
+
+	feedID uuid.UUID
+}
+
+func (r *shutdownRepository) Feeds() ([]Feed, error) {
+	return []Feed{{ID: r.feedID}}, nil
+}
+
+func (r *shutdownRepository) RunFeedChangeListener(addedFeeds chan<- uuid.UUID, needRehash chan<- bool, refreshRequests chan<- RefreshRequest) error {
+	return nil
+}
+
+// slowWorkerPool takes its time fetching and reports which feeds it has finished
+type slowWorkerPool struct {
+	started  chan uuid.UUID
+	finished chan uuid.UUID
+}
+
+func (p *slowWorkerPool) StartWorker(id int, feeds <-chan Feed, doneFeeds chan<- Feed) {
+	for feed := range feeds {
+		p.started <- feed.ID
+		time.Sleep(50 * time.Millisecond)
+		p.finished <- feed.ID
+		doneFeeds <- feed
+	}
+}
+
+func TestScheduler_RunShutdown(t *testing.T) {
+	repo := &shutdownRepository{feedID: uuid.Must(uuid.NewV4())}
+	pool := &slowWorkerPool{
+		started:  make(chan uuid.UUID, 1),
+		finished: make(chan uuid.UUID, 1),
+	}
+	s := NewScheduler(repo, pool, 2, RetentionConfig{}, HostLimitConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(ctx, time.Second)
+		close(stopped)
+	}()
+
+	// shut down while the feed is being fetched
+	assert.Equal(t, repo.feedID, <-pool.started)
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("scheduler didn't stop")
+	}
+	select {
+	case feedID := <-pool.finished:
+		assert.Equal(t, repo.feedID, feedID, "the job in progress is finished before Run returns")
+	default:
+		t.Fatal("job in progress wasn't finished")
+	}
+	assert.Equal(t, 0, s.jobsInProgress)
+}
+
+// refreshNotifier records the notifications
+type refreshNotifier struct {
+	notified map[uuid.UUID][]uuid.UUID
+}
+
+func (n *refreshNotifier) FeedRefreshed(userID uuid.UUID, feed *Feed) {
+	n.notified[feed.ID] = append(n.notified[feed.ID], userID)
+}
+
+func TestScheduler_refreshFeed(t *testing.T) {
+	s := NewScheduler(&feedRepository{}, nil, 1, RetentionConfig{}, HostLimitConfig{})
+	notifier := &refreshNotifier{notified: make(map[uuid.UUID][]uuid.UUID)}
+	s.SetRefreshNotifier(notifier)
+	s.queue = NewFeedQueue()
+	s.queuedFeeds = make(map[uuid.UUID]bool)
+
+	// a feed that was just fetched and one that isn't queued
+	feed := Feed{ID: uuid.Must(uuid.NewV4()), FetcherState: FeedFetcherState{FetchedAt: time.Now(), FetchDelayS: 3600}}
+	s.queueFeed(&feed)
+	deadFeedID := uuid.Must(uuid.NewV4())
+
+	userA := uuid.Must(uuid.NewV4())
+	userB := uuid.Must(uuid.NewV4())
+	s.refreshFeed(RefreshRequest{FeedID: feed.ID, UserID: userA})
+	s.refreshFeed(RefreshRequest{FeedID: feed.ID, UserID: userA})
+	s.refreshFeed(RefreshRequest{FeedID: feed.ID, UserID: userB})
+	s.refreshFeed(RefreshRequest{FeedID: deadFeedID, UserID: userA})
+
+	assert.Equal(t, 2, s.queue.Len())
+	job := s.queue.Pop()
+	assert.WithinDuration(t, time.Now(), job.deadline, time.Second, "feed moved to the front")
+	job = s.queue.Pop()
+	assert.WithinDuration(t, time.Now(), job.deadline, time.Second, "unqueued feed is queued")
+
+	s.notifyRefreshed(&feed)
+	assert.Equal(t, []uuid.UUID{userA, userB}, notifier.notified[feed.ID])
+	assert.NotContains(t, s.refreshRequesters, feed.ID)
+
+	// the next fetch is scheduled normally again
+	s.queueFeed(&feed)
+	assert.True(t, s.queue.Peek().deadline.After(time.Now().Add(time.Minute)))
+}
+
+func TestScheduler_refreshDeadFeed(t *testing.T) {
+	s := NewScheduler(&feedRepository{}, nil, 1, RetentionConfig{}, HostLimitConfig{})
+	notifier := &refreshNotifier{notified: make(map[uuid.UUID][]uuid.UUID)}
+	s.SetRefreshNotifier(notifier)
+	s.queue = NewFeedQueue()
+	s.queuedFeeds = make(map[uuid.UUID]bool)
+
+	feed := Feed{ID: uuid.Must(uuid.NewV4())}
+	s.queueFeed(&feed)
+	job := s.queue.Pop()
+	s.jobStarted(&job.feed)
+
+	// the fetch in progress kills the feed
+	userA := uuid.Must(uuid.NewV4())
+	s.refreshFeed(RefreshRequest{FeedID: feed.ID, UserID: userA})
+	assert.Equal(t, 0, s.queue.Len(), "the requester waits for the fetch in progress")
+
+	feed.FetcherState.Dead = true
+	s.jobDone(&feed)
+	s.queueFeed(&feed)
+	assert.Equal(t, []uuid.UUID{userA}, notifier.notified[feed.ID])
+	assert.Equal(t, 0, s.queue.Len(), "dead feeds aren't queued")
+	assert.Empty(t, s.queuedFeeds)
+	assert.Empty(t, s.fetchingFeeds)
+
+	// refreshing the dead feed fetches it again
+	userB := uuid.Must(uuid.NewV4())
+	s.refreshFeed(RefreshRequest{FeedID: feed.ID, UserID: userB})
+	if assert.Equal(t, 1, s.queue.Len()) {
+		assert.Equal(t, feed.ID, s.queue.Peek().feed.ID)
+		assert.WithinDuration(t, time.Now(), s.queue.Peek().deadline, time.Second)
+	}
+	assert.Equal(t, []uuid.UUID{userB}, s.refreshRequesters[feed.ID])
+}
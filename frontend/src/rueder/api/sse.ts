@@ -3,4 +3,10 @@
 export enum SSEMessageType {
     Raw = "raw", // special internal type, doesn't exist in backend
     FolderUpdate = "folder_update", // folder/feed list order or content was changed
+    StateUpdate = "state_update", // read state of a feed or folder was changed
+    LabelUpdate = "label_update", // label or its article assignments were changed
+    FeedRefreshed = "feed_refreshed", // a refresh requested by the user is done, data: feed_id, working, message
+    NewArticles = "new_articles", // new articles were added to a subscribed feed, data: feed_id, count, max_seq
+    FeedError = "feed_error", // fetching a subscribed feed started failing, data: feed_id, message
+    Logout = "logout", // the account was deleted
 }
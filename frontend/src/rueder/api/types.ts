@@ -133,6 +133,7 @@ export class Feed {
 }
 
 export class FetcherState {
+    dead?: boolean
     working: boolean
     last_success?: string
     last_error?: string